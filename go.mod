@@ -1,18 +1,25 @@
 module github.com/iac-io/myiac
 
-go 1.13
+go 1.16
 
 require (
-	cloud.google.com/go v0.38.0
+	cloud.google.com/go v0.54.0
+	cloud.google.com/go/storage v1.6.0
 	github.com/cloudflare/cloudflare-go v0.13.6
+	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/rs/zerolog v1.18.0
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	github.com/stretchr/testify v1.6.1
 	github.com/urfave/cli v1.22.4
-	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb
+	github.com/xeipuuv/gojsonschema v1.2.0
+	golang.org/x/net v0.0.0-20211209124913-491a49abca63
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	google.golang.org/api v0.21.0
-	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55
+	google.golang.org/genproto v0.0.0-20200305110556-506484158171
 	gopkg.in/ini.v1 v1.61.0
-	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v2 v2.4.0
+	k8s.io/api v0.21.14
+	k8s.io/apimachinery v0.21.14
+	k8s.io/client-go v0.21.14
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,222 @@
+// Package backup snapshots the Kubernetes resources of an environment (deployments, services, config
+// maps and secrets) as YAML into a tarball in object storage, and restores them into a cluster.
+// Secrets are encrypted with the project KMS key inside the tarball
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iac-io/myiac/internal/encryption"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+)
+
+const (
+	backupsPrefix   = "backups"
+	encryptedSuffix = ".enc"
+)
+
+// systemNamespaces left out unless asked for explicitly, the cluster manages their resources
+var systemNamespaces = map[string]bool{"kube-system": true, "kube-public": true, "kube-node-lease": true}
+
+// Service backs up the resources of the cluster of an environment into 'bucketName' and restores them
+type Service struct {
+	kubeClient *kubernetes.Client
+	storage    gcp.ObjectStorageCache
+	bucketName string
+	encrypter  encryption.Encrypter
+	now        func() time.Time
+}
+
+// NewService a Service keeping the backups in 'bucketName', with secrets encrypted by 'encrypter'
+func NewService(kubeClient *kubernetes.Client, storage gcp.ObjectStorageCache, bucketName string,
+	encrypter encryption.Encrypter) *Service {
+	return &Service{kubeClient: kubeClient, storage: storage, bucketName: bucketName, encrypter: encrypter,
+		now: time.Now}
+}
+
+// Create backs up the resources of 'namespaces' (every one but the system ones when empty), returning
+// the key of the backup
+func (s *Service) Create(ctx context.Context, env string, namespaces []string) (string, error) {
+	if len(namespaces) == 0 {
+		all, err := s.kubeClient.ListNamespaces(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, namespace := range all {
+			if !systemNamespaces[namespace] {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	count := 0
+	for _, namespace := range namespaces {
+		resources, err := exportNamespace(ctx, s.kubeClient, namespace)
+		if err != nil {
+			return "", err
+		}
+		for _, res := range resources {
+			name, content := resourcePath(res), res.Content
+			if res.Kind == KindSecrets {
+				encrypted, err := s.encrypter.Encrypt(string(content))
+				if err != nil {
+					return "", fmt.Errorf("error encrypting secret %s/%s: %v", res.Namespace, res.Name, err)
+				}
+				name, content = name+encryptedSuffix, []byte(encrypted)
+			}
+
+			header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: s.now()}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return "", fmt.Errorf("error archiving %s: %v", name, err)
+			}
+			if _, err := tarWriter.Write(content); err != nil {
+				return "", fmt.Errorf("error archiving %s: %v", name, err)
+			}
+			count++
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return "", fmt.Errorf("error writing backup: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", fmt.Errorf("error writing backup: %v", err)
+	}
+
+	key := fmt.Sprintf("%s/%s/%s.tar.gz", backupsPrefix, env, s.now().UTC().Format("20060102150405"))
+	if err := s.storage.Write(nil, s.bucketName, key, archive.String()); err != nil {
+		return "", fmt.Errorf("error saving backup %s: %v", key, err)
+	}
+	log.Printf("Backed up %d resources of %d namespaces to %s/%s\n", count, len(namespaces), s.bucketName, key)
+	return key, nil
+}
+
+// List the keys of the backups of the environment, oldest first
+func (s *Service) List(env string) ([]string, error) {
+	keys, err := s.storage.List(nil, s.bucketName, fmt.Sprintf("%s/%s/", backupsPrefix, env))
+	if err != nil {
+		return nil, fmt.Errorf("error listing backups of %s: %v", env, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Latest the key of the last backup of the environment
+func (s *Service) Latest(env string) (string, error) {
+	keys, err := s.List(env)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no backups of %s in %s", env, s.bucketName)
+	}
+	return keys[len(keys)-1], nil
+}
+
+// Restore applies the resources of the backup 'key' (only the ones of 'namespaces' when set),
+// creating their namespaces. Existing resources with the same names are replaced
+func (s *Service) Restore(ctx context.Context, key string, namespaces []string) (int, error) {
+	resources, err := s.read(key)
+	if err != nil {
+		return 0, err
+	}
+
+	selected := make(map[string]bool)
+	for _, namespace := range namespaces {
+		selected[namespace] = true
+	}
+	ensured := make(map[string]bool)
+	count := 0
+	for _, kind := range Kinds {
+		for _, res := range resources {
+			if res.Kind != kind || (len(selected) > 0 && !selected[res.Namespace]) {
+				continue
+			}
+			if !ensured[res.Namespace] {
+				if err := s.kubeClient.EnsureNamespace(ctx, res.Namespace); err != nil {
+					return count, err
+				}
+				ensured[res.Namespace] = true
+			}
+			if err := restoreResource(ctx, s.kubeClient, res); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	log.Printf("Restored %d resources of %d namespaces from %s/%s\n", count, len(ensured), s.bucketName, key)
+	return count, nil
+}
+
+// read the resources in the backup, with the secrets decrypted
+func (s *Service) read(key string) ([]resource, error) {
+	content, err := s.storage.Read(nil, s.bucketName, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup %s: %v", key, err)
+	}
+
+	gzipReader, err := gzip.NewReader(strings.NewReader(fmt.Sprintf("%v", content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %v", key, err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	var resources []resource
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup %s: %v", key, err)
+		}
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from backup %s: %v", header.Name, key, err)
+		}
+
+		name := header.Name
+		if strings.HasSuffix(name, encryptedSuffix) {
+			decrypted, err := s.encrypter.Decrypt(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("error decrypting %s: %v", name, err)
+			}
+			name, data = strings.TrimSuffix(name, encryptedSuffix), []byte(decrypted)
+		}
+
+		res, err := parseResourcePath(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup %s: %v", key, err)
+		}
+		res.Content = data
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// resourcePath <namespace>/<kind>/<name>.yaml
+func resourcePath(res resource) string {
+	return path.Join(res.Namespace, res.Kind, res.Name+".yaml")
+}
+
+func parseResourcePath(name string) (resource, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".yaml") {
+		return resource{}, fmt.Errorf("unexpected file %s, expected <namespace>/<kind>/<name>.yaml", name)
+	}
+	return resource{Namespace: parts[0], Kind: parts[1], Name: strings.TrimSuffix(parts[2], ".yaml")}, nil
+}
@@ -0,0 +1,198 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const bucketName = "moneycol-myiac-project"
+
+// prefixEncrypter a fake encrypter marking the cipherText with a prefix
+type prefixEncrypter struct{}
+
+func (pe prefixEncrypter) Encrypt(plainText string) (string, error) {
+	return "encrypted:" + plainText, nil
+}
+
+func (pe prefixEncrypter) Decrypt(cipherText string) (string, error) {
+	return strings.TrimPrefix(cipherText, "encrypted:"), nil
+}
+
+func meta(namespace, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Namespace: namespace, Name: name, UID: "a2b9c1", ResourceVersion: "1234",
+		Labels: map[string]string{"app": name}}
+}
+
+func devCluster() []runtime.Object {
+	replicas := int32(2)
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}},
+		&appsv1.Deployment{ObjectMeta: meta("apps", "collections-api"),
+			Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 2}},
+		&corev1.Service{ObjectMeta: meta("apps", "collections-api"),
+			Spec: corev1.ServiceSpec{ClusterIP: "10.0.4.12", Ports: []corev1.ServicePort{{Port: 80}}}},
+		&corev1.ConfigMap{ObjectMeta: meta("apps", "collections-config"), Data: map[string]string{"env": "dev"}},
+		&corev1.ConfigMap{ObjectMeta: meta("apps", "kube-root-ca.crt")},
+		&corev1.Secret{ObjectMeta: meta("apps", "db"), Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{"password": []byte("s3cr3t")}},
+		&corev1.Secret{ObjectMeta: meta("apps", "default-token-x8f2k"), Type: corev1.SecretTypeServiceAccountToken},
+		&corev1.Service{ObjectMeta: meta("default", "kubernetes")},
+		&corev1.ConfigMap{ObjectMeta: meta("kube-system", "kube-dns")},
+	}
+}
+
+func newTestService(t *testing.T, kubeClient *kubernetes.Client) (*Service, string) {
+	storageDir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatalf("error creating storage dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(storageDir) })
+
+	service := NewService(kubeClient, gcp.NewFileObjectStorageCache(storageDir), bucketName, prefixEncrypter{})
+	service.now = func() time.Time { return time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC) }
+	return service, storageDir
+}
+
+func TestBackupLeavesOutSystemAndClusterManagedResources(t *testing.T) {
+	service, _ := newTestService(t, kubernetes.NewClientFromClientset(fake.NewSimpleClientset(devCluster()...)))
+
+	key, err := service.Create(context.Background(), "dev", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "backups/dev/20261019083000.tar.gz", key)
+	resources, err := service.read(key)
+	assert.Nil(t, err)
+	var paths []string
+	for _, res := range resources {
+		paths = append(paths, resourcePath(res))
+	}
+	assert.ElementsMatch(t, []string{
+		"apps/configmaps/collections-config.yaml",
+		"apps/secrets/db.yaml",
+		"apps/services/collections-api.yaml",
+		"apps/deployments/collections-api.yaml",
+	}, paths)
+}
+
+func TestBackupIsCleanedAndSecretsEncrypted(t *testing.T) {
+	service, storageDir := newTestService(t, kubernetes.NewClientFromClientset(fake.NewSimpleClientset(devCluster()...)))
+
+	key, err := service.Create(context.Background(), "dev", []string{"apps"})
+	assert.Nil(t, err)
+
+	resources, _ := service.read(key)
+	for _, res := range resources {
+		content := string(res.Content)
+		assert.NotContains(t, content, "uid:")
+		assert.NotContains(t, content, "resourceVersion:")
+		assert.NotContains(t, content, "10.0.4.12")
+		assert.NotContains(t, content, "readyReplicas")
+	}
+
+	files := archiveFiles(t, storageDir, key)
+	assert.NotContains(t, files, "apps/secrets/db.yaml")
+	assert.True(t, strings.HasPrefix(files["apps/secrets/db.yaml.enc"], "encrypted:"))
+	assert.False(t, strings.HasPrefix(files["apps/configmaps/collections-config.yaml"], "encrypted:"))
+}
+
+func TestRestoreIntoNewCluster(t *testing.T) {
+	service, storageDir := newTestService(t, kubernetes.NewClientFromClientset(fake.NewSimpleClientset(devCluster()...)))
+	key, err := service.Create(context.Background(), "dev", nil)
+	assert.Nil(t, err)
+
+	newCluster := fake.NewSimpleClientset()
+	restoreService := NewService(kubernetes.NewClientFromClientset(newCluster),
+		gcp.NewFileObjectStorageCache(storageDir), bucketName, prefixEncrypter{})
+	latest, err := restoreService.Latest("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, key, latest)
+
+	count, err := restoreService.Restore(context.Background(), latest, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, count)
+	ctx := context.Background()
+	_, err = newCluster.CoreV1().Namespaces().Get(ctx, "apps", metav1.GetOptions{})
+	assert.Nil(t, err)
+	deployment, err := newCluster.AppsV1().Deployments("apps").Get(ctx, "collections-api", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+	assert.Equal(t, map[string]string{"app": "collections-api"}, deployment.Labels)
+	secret, err := newCluster.CoreV1().Secrets("apps").Get(ctx, "db", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("s3cr3t"), secret.Data["password"])
+	configMap, err := newCluster.CoreV1().ConfigMaps("apps").Get(ctx, "collections-config", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", configMap.Data["env"])
+}
+
+func TestRestoreOnlySelectedNamespaces(t *testing.T) {
+	objects := append(devCluster(),
+		&corev1.ConfigMap{ObjectMeta: meta("monitoring", "grafana")},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}})
+	service, _ := newTestService(t, kubernetes.NewClientFromClientset(fake.NewSimpleClientset(objects...)))
+	key, err := service.Create(context.Background(), "dev", nil)
+	assert.Nil(t, err)
+
+	newCluster := fake.NewSimpleClientset()
+	service.kubeClient = kubernetes.NewClientFromClientset(newCluster)
+	count, err := service.Restore(context.Background(), key, []string{"monitoring"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	_, err = newCluster.CoreV1().Namespaces().Get(context.Background(), "apps", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestLatestWithoutBackupsFails(t *testing.T) {
+	service, _ := newTestService(t, kubernetes.NewClientFromClientset(fake.NewSimpleClientset()))
+
+	_, err := service.Latest("dev")
+
+	assert.NotNil(t, err)
+}
+
+// archiveFiles the files of the backup 'key' as stored, with secrets still encrypted
+func archiveFiles(t *testing.T, storageDir string, key string) map[string]string {
+	content, err := gcp.NewFileObjectStorageCache(storageDir).Read(nil, bucketName, key)
+	if err != nil {
+		t.Fatalf("error reading backup %v", err)
+	}
+	gzipReader, err := gzip.NewReader(strings.NewReader(content.(string)))
+	if err != nil {
+		t.Fatalf("invalid backup %v", err)
+	}
+
+	files := make(map[string]string)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("invalid backup %v", err)
+		}
+		data, _ := ioutil.ReadAll(tarReader)
+		files[header.Name] = string(data)
+	}
+}
@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iac-io/myiac/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	KindDeployments = "deployments"
+	KindServices    = "services"
+	KindConfigMaps  = "configmaps"
+	KindSecrets     = "secrets"
+
+	helmReleaseSecretType = "helm.sh/release.v1"
+)
+
+// Kinds the kinds backed up, in the order they're restored: the config and secrets the deployments use first
+var Kinds = []string{KindConfigMaps, KindSecrets, KindServices, KindDeployments}
+
+// annotations set by the API server or kubectl, they don't belong to a new cluster
+var serverAnnotations = []string{"deployment.kubernetes.io/revision", "kubectl.kubernetes.io/last-applied-configuration"}
+
+// resource a resource of a namespace serialized as YAML
+type resource struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Content   []byte
+}
+
+// exportNamespace the resources of 'namespace' as YAML, without the ones the cluster creates on its
+// own (service account tokens, root CA config maps, the API service) or that are owned by others
+func exportNamespace(ctx context.Context, kubeClient *kubernetes.Client, namespace string) ([]resource, error) {
+	var objects []metav1.Object
+
+	configMaps, err := kubeClient.ListConfigMaps(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range configMaps {
+		configMap := &configMaps[i]
+		if configMap.Name != "kube-root-ca.crt" {
+			configMap.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+			objects = append(objects, configMap)
+		}
+	}
+
+	secrets, err := kubeClient.ListSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range secrets {
+		secret := &secrets[i]
+		if secret.Type != corev1.SecretTypeServiceAccountToken && secret.Type != helmReleaseSecretType {
+			secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+			objects = append(objects, secret)
+		}
+	}
+
+	services, err := kubeClient.ListServices(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range services {
+		service := &services[i]
+		if namespace == metav1.NamespaceDefault && service.Name == "kubernetes" {
+			continue
+		}
+		service.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}
+		// a new cluster allocates its own, headless services keep None
+		if service.Spec.ClusterIP != corev1.ClusterIPNone {
+			service.Spec.ClusterIP, service.Spec.ClusterIPs = "", nil
+		}
+		service.Status = corev1.ServiceStatus{}
+		objects = append(objects, service)
+	}
+
+	deployments, err := kubeClient.ListDeployments(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments {
+		deployment := &deployments[i]
+		deployment.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+		deployment.Status = appsv1.DeploymentStatus{}
+		objects = append(objects, deployment)
+	}
+
+	var resources []resource
+	for _, object := range objects {
+		if len(object.GetOwnerReferences()) > 0 {
+			continue
+		}
+		cleanObjectMeta(object)
+		content, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing %s/%s: %v", namespace, object.GetName(), err)
+		}
+		resources = append(resources, resource{Namespace: namespace, Kind: kindOf(object), Name: object.GetName(),
+			Content: content})
+	}
+	return resources, nil
+}
+
+func kindOf(object metav1.Object) string {
+	switch object.(type) {
+	case *corev1.ConfigMap:
+		return KindConfigMaps
+	case *corev1.Secret:
+		return KindSecrets
+	case *corev1.Service:
+		return KindServices
+	default:
+		return KindDeployments
+	}
+}
+
+// cleanObjectMeta keeps the name, labels and annotations, the rest (uid, resource version,
+// timestamps, managed fields...) is set by the cluster
+func cleanObjectMeta(object metav1.Object) {
+	annotations := object.GetAnnotations()
+	for _, annotation := range serverAnnotations {
+		delete(annotations, annotation)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	object.SetUID("")
+	object.SetResourceVersion("")
+	object.SetGeneration(0)
+	object.SetSelfLink("")
+	object.SetCreationTimestamp(metav1.Time{})
+	object.SetManagedFields(nil)
+	object.SetAnnotations(annotations)
+}
+
+// restoreResource applies a backed up resource into 'namespace'
+func restoreResource(ctx context.Context, kubeClient *kubernetes.Client, res resource) error {
+	switch res.Kind {
+	case KindConfigMaps:
+		var configMap corev1.ConfigMap
+		if err := yaml.Unmarshal(res.Content, &configMap); err != nil {
+			return fmt.Errorf("invalid config map %s/%s: %v", res.Namespace, res.Name, err)
+		}
+		configMap.Namespace = res.Namespace
+		return kubeClient.ApplyConfigMap(ctx, &configMap)
+	case KindSecrets:
+		var secret corev1.Secret
+		if err := yaml.Unmarshal(res.Content, &secret); err != nil {
+			return fmt.Errorf("invalid secret %s/%s: %v", res.Namespace, res.Name, err)
+		}
+		secret.Namespace = res.Namespace
+		return kubeClient.ApplySecret(ctx, &secret)
+	case KindServices:
+		var service corev1.Service
+		if err := yaml.Unmarshal(res.Content, &service); err != nil {
+			return fmt.Errorf("invalid service %s/%s: %v", res.Namespace, res.Name, err)
+		}
+		service.Namespace = res.Namespace
+		return kubeClient.ApplyService(ctx, &service)
+	case KindDeployments:
+		var deployment appsv1.Deployment
+		if err := yaml.Unmarshal(res.Content, &deployment); err != nil {
+			return fmt.Errorf("invalid deployment %s/%s: %v", res.Namespace, res.Name, err)
+		}
+		deployment.Namespace = res.Namespace
+		return kubeClient.ApplyDeployment(ctx, &deployment)
+	default:
+		return fmt.Errorf("unknown kind %s of %s/%s", res.Kind, res.Namespace, res.Name)
+	}
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/backup"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/urfave/cli"
+)
+
+// backupCmd snapshots of the resources of the cluster of an environment, kept in the project bucket
+//
+// myiac backup create --project moneycol --env dev [--namespaces default,monitoring]
+// myiac backup list --project moneycol --env dev
+// myiac backup restore --project moneycol --env dev [--backup backups/dev/20261019083000.tar.gz]
+func backupCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	namespacesFlag := &cli.StringSliceFlag{Name: "namespaces",
+		Usage: "Namespaces to include, comma separated or repeated (default all but the kube-* ones)"}
+	storageDirFlag := &cli.StringFlag{Name: "storage-dir",
+		Usage: "Keep the backups in this local directory instead of the project bucket"}
+
+	return cli.Command{
+		Name:  "backup",
+		Usage: "Back up the deployments, services, config maps and secrets of a cluster and restore them",
+		Subcommands: []cli.Command{
+			{
+				Name:  "create",
+				Usage: "Export the resources of the current cluster into a new backup",
+				Flags: []cli.Flag{projectFlag, environmentFlag, namespacesFlag, storageDirFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					backupService, err := newBackupService(c, project, true)
+					if err != nil {
+						return err
+					}
+					key, err := backupService.Create(context.Background(), env, backupNamespaces(c))
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Backup of %s/%s saved as %s\n", project, env, key)
+					return nil
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "List the backups of an environment, oldest first",
+				Flags: []cli.Flag{projectFlag, environmentFlag, storageDirFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					backupService, err := newBackupService(c, project, false)
+					if err != nil {
+						return err
+					}
+					keys, err := backupService.List(env)
+					if err != nil {
+						return err
+					}
+					for _, key := range keys {
+						fmt.Println(key)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "restore",
+				Usage: "Apply the resources of a backup to the current cluster",
+				Flags: []cli.Flag{projectFlag, environmentFlag, namespacesFlag, storageDirFlag,
+					&cli.StringFlag{Name: "backup", Usage: "Key of the backup to restore (default the latest)"},
+				},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					backupService, err := newBackupService(c, project, true)
+					if err != nil {
+						return err
+					}
+					key := c.String("backup")
+					if key == "" {
+						if key, err = backupService.Latest(env); err != nil {
+							return err
+						}
+					}
+
+					return withClusterLock(project, env, "backup restore", func() error {
+						count, err := backupService.Restore(context.Background(), key, backupNamespaces(c))
+						if err != nil {
+							return err
+						}
+						fmt.Printf("Restored %d resources of %s into %s/%s\n", count, key, project, env)
+						return nil
+					})
+				},
+			},
+		},
+	}
+}
+
+// newBackupService a backup service on the project bucket, or on --storage-dir when set. Listing
+// backups doesn't need the cluster
+func newBackupService(c *cli.Context, project string, withCluster bool) (*backup.Service, error) {
+	var kubeClient *kubernetes.Client
+	if withCluster {
+		var err error
+		if kubeClient, err = kubernetes.NewDefaultClient(); err != nil {
+			return nil, err
+		}
+	}
+
+	bucketName := gcp.ProjectBucketName(project)
+	var storage gcp.ObjectStorageCache
+	if storageDir := c.String("storage-dir"); storageDir != "" {
+		storage = gcp.NewFileObjectStorageCache(storageDir)
+	} else {
+		if err := gcp.EnsureGCSBucket(project, bucketName); err != nil {
+			return nil, fmt.Errorf("error preparing backup bucket: %v", err)
+		}
+		storage = gcp.NewDefaultObjectStorageCache()
+	}
+	return backup.NewService(kubeClient, storage, bucketName, projectKmsEncrypter(project)), nil
+}
+
+// backupNamespaces the namespaces of the --namespaces flags, split by commas
+func backupNamespaces(c *cli.Context) []string {
+	var namespaces []string
+	for _, value := range c.StringSlice("namespaces") {
+		for _, namespace := range strings.Split(value, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	return namespaces
+}
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -8,11 +10,16 @@ import (
 	"strings"
 
 	"github.com/iac-io/myiac/internal/cluster"
+	"github.com/iac-io/myiac/internal/commandline"
 	"github.com/iac-io/myiac/internal/deploy"
 	"github.com/iac-io/myiac/internal/docker"
 	"github.com/iac-io/myiac/internal/encryption"
 	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/naming"
+	"github.com/iac-io/myiac/internal/preferences"
 	props "github.com/iac-io/myiac/internal/properties"
+	"github.com/iac-io/myiac/internal/util"
 	"github.com/urfave/cli"
 )
 
@@ -37,26 +44,43 @@ func BuildCli() {
 	keyPath := &cli.StringFlag{Name: "keyPath", Usage: "SA key path"}
 	tfConfigPath := &cli.StringFlag{Name: "tfConfigPath", Usage: "Terraform Configuration Directory Path"}
 	zoneFlag := &cli.StringFlag{Name: "zone", Usage: "Cluster Zone  (example: europe-west2-b)"}
+	regionFlag := &cli.StringFlag{Name: "region", Usage: "Cluster Region of regional clusters (example: europe-west2)"}
 	poolNameFlag := &cli.StringFlag{Name: "pool-name", Usage: "Pool Name"}
 	poolSizeFlag := &cli.StringFlag{Name: "pool-size", Usage: "New Pool Size"}
-	setupEnvironment := setupEnvironmentCmd(providerFlag, projectFlag, environmentFlag, keyPath, dryRunFlag, zoneFlag, clusterPrefix)
+	setupEnvironment := setupEnvironmentCmd(providerFlag, projectFlag, environmentFlag, keyPath, dryRunFlag, zoneFlag,
+		regionFlag, clusterPrefix)
 	dockerSetup := dockerSetupCmd(projectFlag, environmentFlag)
 	dockerBuild := dockerBuildCmd(projectFlag)
 
 	createClusterCmd := createClusterCmd(projectFlag, environmentFlag, dryRunFlag, providerFlag, keyPath,
-		tfConfigPath, zoneFlag, clusterPrefix)
-	installHelmCmd := installHelmCmd(projectFlag, environmentFlag)
+		tfConfigPath, zoneFlag, regionFlag, clusterPrefix)
+	installHelmCmd := installHelmCmd(projectFlag, environmentFlag, clusterPrefix)
 	destroyClusterCmd := destroyClusterCmd(projectFlag, environmentFlag, providerFlag, keyPath, tfConfigPath)
 
 	deployApp := deployAppSetup(projectFlag, environmentFlag, propertiesFlag)
-	resizeClusterCmd := resizeClusterCmd(projectFlag, environmentFlag)
+	resizeClusterCmd := resizeClusterCmd(projectFlag, environmentFlag, clusterPrefix)
 	resizePoolCmd := resizePoolCmd(providerFlag, projectFlag, environmentFlag, poolNameFlag, poolSizeFlag, zoneFlag,
-		keyPath, dryRunFlag)
+		regionFlag, clusterPrefix, keyPath, dryRunFlag)
 	createSecretCmd := createSecretCmd()
 	cryptCmd := cryptCmd(projectFlag)
 	createCertCmd := createCertCmd()
 
 	updateDnsFromClusterIps := updateDnsFromClusterIpsCmd()
+	lockCmd := lockCmd(projectFlag, environmentFlag)
+	validateCmd := validateCmd(projectFlag, environmentFlag, propertiesFlag)
+	historyCmd := historyCmd(projectFlag, environmentFlag)
+	promoteCmd := promoteCmd(projectFlag, environmentFlag)
+	abortCmd := abortCmd(projectFlag, environmentFlag)
+	releasesCmd := releasesCmd(projectFlag, environmentFlag)
+	tfCmd := tfCmd(projectFlag, environmentFlag, tfConfigPath)
+	driftCmd := driftCmd(projectFlag, environmentFlag, keyPath, tfConfigPath, zoneFlag, regionFlag, clusterPrefix)
+	doctorCmd := doctorCmd(keyPath)
+	scheduleCmd := scheduleCmd(projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix)
+	nodepoolCmd := nodepoolCmd(projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix, poolNameFlag)
+	namesCmd := namesCmd(projectFlag, environmentFlag, clusterPrefix)
+	nodesCmd := nodesCmd()
+	backupCmd := backupCmd(projectFlag, environmentFlag)
+	costCmd := costCmd(projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix)
 
 	app.Commands = []cli.Command{
 		setupEnvironment,
@@ -72,7 +96,31 @@ func BuildCli() {
 		createCertCmd,
 		resizePoolCmd,
 		updateDnsFromClusterIps,
+		lockCmd,
+		validateCmd,
+		historyCmd,
+		promoteCmd,
+		abortCmd,
+		releasesCmd,
+		tfCmd,
+		driftCmd,
+		doctorCmd,
+		scheduleCmd,
+		nodepoolCmd,
+		namesCmd,
+		nodesCmd,
+		backupCmd,
+		costCmd,
 	}
+	app.Flags = []cli.Flag{
+		&cli.BoolFlag{Name: "skip-preflight", Usage: "Don't check the binaries a command needs before running it"},
+	}
+	// kubectl, helm and the Kubernetes API calls use the kubeconfig of the active cluster
+	app.Before = func(c *cli.Context) error {
+		cluster.UseActiveKubeconfig(preferences.DefaultConfig())
+		return nil
+	}
+	withPreflight(app.Commands)
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -114,11 +162,7 @@ func cryptCmd(projectFlag *cli.StringFlag) cli.Command {
 
 			gcp.SetupEnvironment(project)
 
-			keyRingName := fmt.Sprintf("%s-keyring", project)
-			keyName := fmt.Sprintf("%s-infra-key", project)
-			locationId := "global"
-			kmsEncrypter := gcp.NewKmsEncrypter(project, locationId, keyRingName, keyName)
-			encrypter := encryption.NewEncrypter(kmsEncrypter)
+			encrypter := encryption.NewEncrypter(projectKmsEncrypter(project))
 
 			if mode != "encrypt" && mode != "decrypt" {
 				return cli.NewExitError("mode can only be 'encrypt' or 'decrypt'", -1)
@@ -138,8 +182,8 @@ func cryptCmd(projectFlag *cli.StringFlag) cli.Command {
 }
 
 func resizePoolCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag,
-	poolNameFlag *cli.StringFlag, poolSizeFlag *cli.StringFlag, zoneFlag *cli.StringFlag,
-	keyPath *cli.StringFlag, dryRunFlag *cli.BoolFlag) cli.Command {
+	poolNameFlag *cli.StringFlag, poolSizeFlag *cli.StringFlag, zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag,
+	clusterPrefix *cli.StringFlag, keyPath *cli.StringFlag, dryRunFlag *cli.BoolFlag) cli.Command {
 
 	return cli.Command{
 		Name:  "resizePool",
@@ -151,6 +195,8 @@ func resizePoolCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFlag, en
 			poolNameFlag,
 			poolSizeFlag,
 			zoneFlag,
+			regionFlag,
+			clusterPrefix,
 			keyPath,
 			dryRunFlag,
 		},
@@ -161,7 +207,6 @@ func resizePoolCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFlag, en
 			_ = validateStringFlagPresence("provider", c)
 			_ = validateStringFlagPresence("project", c)
 			_ = validateStringFlagPresence("env", c)
-			_ = validateStringFlagPresence("zone", c)
 			_ = validateStringFlagPresence("keyPath", c)
 			_ = validateStringFlagPresence("pool-name", c)
 			_ = validateStringFlagPresence("pool-size", c)
@@ -169,27 +214,32 @@ func resizePoolCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFlag, en
 			provider := c.String("provider")
 			project := c.String("project")
 			env := c.String("env")
-			zone := c.String("zone")
 			key := c.String("keyPath")
 			poolName := c.String("pool-name")
 			poolSize := c.String("pool-size")
 			dryrRun := c.Bool("dry-run")
 
-			//TODO: read from project manifest
-			//log.Printf("project: %s", project)
-			//log.Printf("env: %s", env)
-			//log.Printf("zone: %s", zone)
-			//log.Printf("resizong Node Pool: %s to %s nodes", poolName, poolSize)
-			cluster.SetupProvider(provider, zone, project+"-"+env, project, key, dryrRun)
-			gcp.ResizePool(project, env, poolName, poolSize, zone)
-			//gcp.ResizeCluster(project, zone, env, nodePoolsSize)
-			return nil
+			location, err := flagLocation(c)
+			if err != nil {
+				return err
+			}
+			clusterName, location, err := clusterLocation(project, env, c.String("prefix"), location)
+			if err != nil {
+				return err
+			}
+
+			if err := cluster.SetupProvider(provider, location, clusterName, project, env, key, dryrRun); err != nil {
+				return err
+			}
+			return withEnvironmentLock(project, env, "resizePool", func() error {
+				return gcp.ResizePool(commandline.NewEmpty(), clusterName, poolName, poolSize, gcp.Location(location))
+			})
 		},
 	}
 	//
 }
 
-func resizeClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+func resizeClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
 	nodePoolsSizeFlag := &cli.StringFlag{Name: "nodePoolsSize",
 		Usage: "Target size of all node pools"}
 	return cli.Command{
@@ -199,6 +249,7 @@ func resizeClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFl
 			projectFlag,
 			environmentFlag,
 			nodePoolsSizeFlag,
+			clusterPrefix,
 		},
 		Action: func(c *cli.Context) error {
 			fmt.Printf("Validating flags for resizeCluster\n")
@@ -210,11 +261,14 @@ func resizeClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFl
 			nodePoolsSize := c.Int("nodePoolsSize")
 			gcp.SetupEnvironment(project)
 
-			//TODO: read from project manifest
-			zone := "europe-west1-b"
+			location, err := manifestLocation(project, env, "europe-west1-b")
+			if err != nil {
+				return err
+			}
 
-			gcp.ResizeCluster(project, zone, env, nodePoolsSize)
-			return nil
+			return withEnvironmentLock(project, env, "resizeCluster", func() error {
+				return gcp.ResizeCluster(commandline.NewEmpty(), project, location, env, c.String("prefix"), nodePoolsSize)
+			})
 		},
 	}
 }
@@ -292,6 +346,11 @@ func deployAppSetup(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag
 	appNameFlag := &cli.StringFlag{Name: "app, a",
 		Usage: "The app to deploy. A helm chart with the same name must exist in the CHARTS_LOCATION"}
 	dryRunFlag := &cli.BoolFlag{Name: "dryRun", Usage: "Executes the command in dryRun mode"}
+	strategyFlag := &cli.StringFlag{Name: "strategy", Value: deploy.StrategyRolling,
+		Usage: "How to roll out the new version: rolling, canary (alongside the stable release) or bluegreen"}
+	weightFlag := &cli.IntFlag{Name: "weight", Value: 10, Usage: "% of traffic routed to the canary release"}
+	hostFlag := &cli.StringFlag{Name: "host", Usage: "Host routed between stable and candidate releases (canary/bluegreen)"}
+	servicePortFlag := &cli.IntFlag{Name: "service-port", Value: 80, Usage: "Port of the app services (canary/bluegreen)"}
 	return cli.Command{
 		Name:  "deploy",
 		Usage: "Deploy an app (defined as a Helm chart from a Docker image) into a Kubernetes cluster in a given environment",
@@ -301,6 +360,10 @@ func deployAppSetup(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag
 			appNameFlag,
 			dryRunFlag,
 			propertiesFlag,
+			strategyFlag,
+			weightFlag,
+			hostFlag,
+			servicePortFlag,
 		},
 		Action: func(c *cli.Context) error {
 			fmt.Printf("Deploying with flags\n")
@@ -320,19 +383,57 @@ func deployAppSetup(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag
 			propertiesMap := readPropertiesToMap(c.String("properties"))
 			dryRun := c.Bool("dryRun")
 
-			deployer := deploy.NewDeployer()
-			deployer.Deploy(appToDeploy, env, propertiesMap, dryRun)
-			return nil
+			strategy := c.String("strategy")
+			if strategy != deploy.StrategyRolling {
+				options := deploy.RolloutOptions{
+					Strategy:    strategy,
+					Weight:      c.Int("weight"),
+					Host:        c.String("host"),
+					ServicePort: c.Int("service-port"),
+					DryRun:      dryRun,
+				}
+				return startRollout(project, env, appToDeploy, propertiesMap, options)
+			}
+
+			projectManifest, err := manifest.Load(project)
+			if err != nil {
+				return err
+			}
+
+			deployer := deploy.NewProjectDeployer(projectManifest, projectKmsEncrypter(project))
+			return withClusterLock(project, env, "deploy "+appToDeploy, func() error {
+				request := deploy.DeploymentRequest{
+					AppName:     appToDeploy,
+					Environment: env,
+					Properties:  propertiesMap,
+					DryRun:      dryRun,
+				}
+				if dryRun {
+					_, err := deployer.Deploy(context.Background(), request)
+					return err
+				}
+
+				isHelmApp := projectManifest.DeployerFor(appToDeploy) == manifest.DeployerHelm
+				record := startDeploymentRecord(project, appToDeploy, env, propertiesMap, isHelmApp)
+				result, err := deployer.Deploy(context.Background(), request)
+				finishDeploymentRecord(project, record, err)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("Deployed %s: %s\n", appToDeploy, result)
+				return nil
+			})
 		},
 	}
 }
 
 func createClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag,
-	dryRunFlag *cli.BoolFlag, providerFlag *cli.StringFlag, keyPath *cli.StringFlag, tfConfigPath *cli.StringFlag, zoneFlag *cli.StringFlag,  clusterPrefix *cli.StringFlag) cli.Command {
+	dryRunFlag *cli.BoolFlag, providerFlag *cli.StringFlag, keyPath *cli.StringFlag, tfConfigPath *cli.StringFlag, zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
 	return cli.Command{
 		Name:  "createCluster",
 		Usage: "Create a Kubernetes cluster through Terraform",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			projectFlag,
 			environmentFlag,
 			dryRunFlag,
@@ -340,16 +441,16 @@ func createClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFl
 			keyPath,
 			tfConfigPath,
 			zoneFlag,
+			regionFlag,
 			clusterPrefix,
-		},
+			&cli.BoolFlag{Name: "approve", Usage: "Apply the plan without asking for confirmation"},
+		}, clusterSettingsFlags()...),
 		Action: func(c *cli.Context) error {
 			fmt.Printf("Validating flags for createCluster\n")
 			_ = validateBaseFlags(c)
 			_ = validateStringFlagPresence("provider", c)
 			_ = validateStringFlagPresence("env", c)
 			_ = validateStringFlagPresence("keyPath", c)
-			_ = validateStringFlagPresence("zone", c)
-			_ = validateStringFlagPresence("prefix", c)
 			fmt.Printf("createCluster running with flags\n")
 
 			project := c.String("project")
@@ -358,23 +459,38 @@ func createClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFl
 			provider := c.String("provider")
 			key := c.String("keyPath")
 			tfConfigPath := c.String("tfConfigPath")
-			zone := c.String("zone")
-			prefix := c.String("prefix")
-			clusterName := prefix + "-" + project + "-" + env
+
+			settings, err := clusterSettings(c, project, env)
+			if err != nil {
+				return err
+			}
+			if err := requireClusterLocation(settings); err != nil {
+				return err
+			}
 
 			if provider == "gcp" {
 				//Setup ENV Variable with the json credentials
 				gcp.SetKeyEnvVar(key)
 			}
 
-			//TODO: pass-in variables
-			err := cluster.CreateCluster(project, env, dryrun, tfConfigPath)
+			var outputs *cluster.ClusterOutputs
+			err = withEnvironmentLock(project, env, "createCluster", func() error {
+				outputs, err = cluster.CreateCluster(settings, dryrun, tfConfigPath, planApprover(c.Bool("approve")))
+				return err
+			})
+			if errors.Is(err, cluster.ErrPlanNotApproved) {
+				return cli.NewExitError("Plan not approved, nothing was applied", 1)
+			}
 			if err != nil {
-				log.Fatalf("Could not create cluster in project: %v. Error: %v", project, err)
+				return fmt.Errorf("could not create cluster in project %s: %v", project, err)
 			}
-			cluster.SetupProvider(provider, zone, clusterName, project, key, dryrun)
 
-			return nil
+			clusterName := naming.MustFor(project).ClusterName(project, env, settings.Prefix)
+			location := settings.Location()
+			if outputs != nil {
+				clusterName, location = outputs.ClusterName, outputs.ClusterLocation
+			}
+			return cluster.SetupProvider(provider, location, clusterName, project, env, key, dryrun)
 
 		},
 	}
@@ -385,13 +501,16 @@ func destroyClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringF
 	return cli.Command{
 		Name:  "destroyCluster",
 		Usage: "Destroy an existing Kubernetes cluster created previously through Terraform",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			projectFlag,
 			environmentFlag,
 			providerFlag,
 			keyPath,
 			tfConfigPath,
-		},
+			&cli.BoolFlag{Name: "purge-state", Usage: "Back up the terraform state bucket and delete it"},
+			&cli.StringFlag{Name: "state-backup-dir", Value: util.GetHomeDir() + "/.myiac/state-backups",
+				Usage: "Directory of the state backup written with --purge-state"},
+		}, clusterSettingsFlags()...),
 		Action: func(c *cli.Context) error {
 			fmt.Printf("Validating flags for destroyCluster\n")
 			_ = validateBaseFlags(c)
@@ -411,19 +530,26 @@ func destroyClusterCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringF
 				gcp.SetKeyEnvVar(keyPath)
 			}
 
-			cluster.DestroyCluster(project, env, tfConfigPath)
-			return nil
+			settings, err := clusterSettings(c, project, env)
+			if err != nil {
+				return err
+			}
+
+			return withEnvironmentLock(project, env, "destroyCluster", func() error {
+				return cluster.DestroyCluster(settings, tfConfigPath, c.Bool("purge-state"), c.String("state-backup-dir"))
+			})
 		},
 	}
 }
 
-func installHelmCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+func installHelmCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
 	return cli.Command{
 		Name:  "installHelm",
 		Usage: "Install helm (tiller) in a Kubernetes cluster",
 		Flags: []cli.Flag{
 			projectFlag,
 			environmentFlag,
+			clusterPrefix,
 		},
 		Action: func(c *cli.Context) error {
 			fmt.Printf("Validating flags for install Helm\n")
@@ -433,9 +559,11 @@ func installHelmCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag
 			gcp.SetupEnvironment(project)
 			env := c.String("env")
 
-			//TODO: read from project manifest
-			zone := "europe-west1-b"
-			gcp.SetupKubernetes(project, zone, env)
+			location, err := manifestLocation(project, env, "europe-west1-b")
+			if err != nil {
+				return err
+			}
+			gcp.SetupKubernetes(project, location, env, c.String("prefix"))
 
 			//TODO: Need to botsrap this be full blown go solution. Disabling for now.
 			//cluster.InstallHelm()
@@ -446,7 +574,8 @@ func installHelmCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag
 }
 
 func setupEnvironmentCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag,
-	keyPath *cli.StringFlag, dryRunFlag *cli.BoolFlag, zone *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
+	keyPath *cli.StringFlag, dryRunFlag *cli.BoolFlag, zone *cli.StringFlag, region *cli.StringFlag,
+	clusterPrefix *cli.StringFlag) cli.Command {
 
 	return cli.Command{
 		Name:  "setupEnvironment",
@@ -458,6 +587,7 @@ func setupEnvironmentCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFl
 			keyPath,
 			dryRunFlag,
 			zone,
+			region,
 			clusterPrefix,
 		},
 		Action: func(c *cli.Context) error {
@@ -467,35 +597,40 @@ func setupEnvironmentCmd(providerFlag *cli.StringFlag, projectFlag *cli.StringFl
 			_ = validateStringFlagPresence("env", c)
 			_ = validateStringFlagPresence("project", c)
 			_ = validateStringFlagPresence("keyPath", c)
-			_ = validateStringFlagPresence("zone", c)
-			_ = validateStringFlagPresence("prefix", c)
 
 			providerValue := c.String("provider")
 			project := c.String("project")
 			env := c.String("env")
 			keyLocation := c.String("keyPath")
 			dryrun := c.Bool("dry-run")
-			zone := c.String("zone")
 			prefix := c.String("prefix")
-			clusterName := c.String("clusterName")
 
-            if clusterName == "" {
-                if prefix != "" {
-                   clusterName = prefix + "-" + project + "-" + env
-                } else {
-                   clusterName = project + "-" + env
-                }
-            }
-
-			cluster.SetupProvider(providerValue, zone, clusterName, project, keyLocation, dryrun)
+			location, err := flagLocation(c)
+			if err != nil {
+				return err
+			}
+			clusterName, location, err := clusterLocation(project, env, prefix, location)
+			if err != nil {
+				return err
+			}
+			if location == "" {
+				return fmt.Errorf("the cluster zone or region is required")
+			}
 
-			return nil
+			return cluster.SetupProvider(providerValue, location, clusterName, project, env, keyLocation, dryrun)
 		},
 	}
 }
 
 // --- Aux functions ---
 
+// projectKmsEncrypter the KMS key used for the project secrets ('crypt' command, encrypted values files)
+func projectKmsEncrypter(project string) encryption.Encrypter {
+	convention := naming.MustFor(project)
+	locationId := "global"
+	return gcp.NewKmsEncrypter(project, locationId, convention.KeyRingName(project), convention.KeyName(project))
+}
+
 func validateBaseFlags(ctx *cli.Context) error {
 	validateStringFlagPresence("project", ctx)
 	return nil
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/cost"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/util"
+	"github.com/urfave/cli"
+)
+
+// costCmd the estimated monthly cost of the cluster of an environment
+//
+// myiac cost --project moneycol --env dev [--pricing pricing.yaml] [--output json]
+func costCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, keyPath *cli.StringFlag,
+	zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
+	return cli.Command{
+		Name:  "cost",
+		Usage: "Estimate the monthly cost of the cluster and the savings of scale down windows or preemptible nodes",
+		Flags: []cli.Flag{projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix,
+			&cli.StringFlag{Name: "pricing", Usage: "Pricing file overriding the built-in prices (default ~/.myiac/pricing.yaml when present)"},
+			&cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: table or json"},
+		},
+		Action: func(c *cli.Context) error {
+			output := c.String("output")
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %s, use table or json", output)
+			}
+			if output == "json" {
+				commandline.ShowProgressOnStderr()
+			}
+			pricing, err := costPricing(c.String("pricing"))
+			if err != nil {
+				return err
+			}
+			project, env, clusterName, location, err := nodepoolLocation(c)
+			if err != nil {
+				return err
+			}
+			windows, err := scheduleWindows(project, env)
+			if err != nil {
+				return err
+			}
+
+			cmdRunner := commandline.NewEmpty()
+			liveNodePools, err := gcp.ListNodePools(cmdRunner, project, clusterName, location)
+			if err != nil {
+				return err
+			}
+			var nodePools []cost.NodePool
+			for _, nodePool := range liveNodePools {
+				nodesPerZone, err := gcp.NodePoolSize(cmdRunner, project, clusterName, location, nodePool.Name)
+				if err != nil {
+					return err
+				}
+				nodePools = append(nodePools, cost.NodePoolOf(nodePool, nodesPerZone))
+			}
+			addresses, err := gcp.ListAddresses(cmdRunner, project)
+			if err != nil {
+				return err
+			}
+
+			estimate, err := cost.EstimateCluster(pricing, nodePools, addresses, location.Region(), windows)
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				return printCostEstimateJson(estimate)
+			}
+			return printCostEstimate(clusterName, estimate)
+		},
+	}
+}
+
+// costPricing the built-in prices, overridden by 'pricingPath' or else by ~/.myiac/pricing.yaml
+func costPricing(pricingPath string) (*cost.Pricing, error) {
+	if pricingPath == "" {
+		defaultPath := filepath.Join(util.GetHomeDir(), ".myiac", "pricing.yaml")
+		if !util.FileExists(defaultPath) {
+			return cost.DefaultPricing(), nil
+		}
+		pricingPath = defaultPath
+	}
+	return cost.LoadPricing(pricingPath)
+}
+
+func printCostEstimateJson(estimate *cost.Estimate) error {
+	estimateJson, err := json.MarshalIndent(struct {
+		*cost.Estimate
+		Total              float64 `json:"total"`
+		ScheduleSavings    float64 `json:"scheduleSavings"`
+		PreemptibleSavings float64 `json:"preemptibleSavings"`
+	}{estimate, estimate.Total(), estimate.ScheduleSavings(), estimate.PreemptibleSavings()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing cost estimate: %v", err)
+	}
+	fmt.Println(string(estimateJson))
+	return nil
+}
+
+func printCostEstimate(clusterName string, estimate *cost.Estimate) error {
+	fmt.Printf("Monthly cost of %s, pricing %s (%s, %.0f hours a month)\n\n", clusterName, estimate.PricingVersion,
+		estimate.Currency, cost.HoursPerMonth)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NODE POOL\tMACHINE TYPE\tNODES\tPREEMPTIBLE\tDISK\tCOMPUTE\tDISK COST\tSCHEDULE SAVES\tPREEMPTIBLE SAVES")
+	for _, pool := range estimate.Pools {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%dGB %s\t%.2f\t%.2f\t%.2f\t%.2f\n", pool.Name, pool.MachineType,
+			pool.Nodes(), pool.Preemptible, pool.DiskSizeGb, pool.DiskType, pool.Compute, pool.Disk,
+			pool.ScheduleSavings, pool.PreemptibleSavings)
+	}
+	_, _ = fmt.Fprintf(w, "static IPs\t\t%d\t\t\t%.2f\t\t\t\n", estimate.StaticIps, estimate.StaticIpsCost)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	total := estimate.Total()
+	fmt.Printf("\nTotal: %.2f %s/month\n", total, estimate.Currency)
+	if estimate.ProjectStaticIps > 0 {
+		fmt.Printf("Not included: %d global or other region static IPs of the project, %.2f %s/month\n",
+			estimate.ProjectStaticIps, estimate.ProjectStaticIpsCost, estimate.Currency)
+	}
+	fmt.Printf("Scheduled scale downs save %.2f, %.2f %s/month with them\n", estimate.ScheduleSavings(),
+		total-estimate.ScheduleSavings(), estimate.Currency)
+	fmt.Printf("Preemptible nodes would save a further %.2f %s/month\n", estimate.PreemptibleSavings(), estimate.Currency)
+	return nil
+}
@@ -45,7 +45,9 @@ func createCertCmd() cli.Command {
 
 			log.Printf("Creating certificate for %s from %s - %s \n", domainName, certPath, keyPath)
 
-			cluster.ProviderSetup()
+			if err := cluster.ProviderSetup(); err != nil {
+				return err
+			}
 
 			secretManager := secret.CreateKubernetesSecretManager("default")
 			certificate := ssl.NewCertificate(domainName, certPath, keyPath)
@@ -38,7 +38,9 @@ func createSecretCmd() cli.Command {
 			// for literal secrets
 			literal := c.String("literal")
 
-			cluster.ProviderSetup()
+			if err := cluster.ProviderSetup(); err != nil {
+				return err
+			}
 
 			if len(saEmail) > 0 {
 				_ = createSecretForServiceAccount(saEmail, secretName, recreateKey)
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/cluster"
+	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/doctor"
+	"github.com/iac-io/myiac/internal/preferences"
+	"github.com/urfave/cli"
+)
+
+// commandBinaries the binaries each command shells out to, checked by the preflight before running it.
+// The Kubernetes API calls authenticate through gcloud, as the kubeconfigs of GKE clusters do. Commands
+// missing here are checked with every required binary
+var commandBinaries = map[string][]string{
+	"setupEnvironment":        {"gcloud", "kubectl"},
+	"dockerSetup":             {"gcloud", "docker"},
+	"dockerBuild":             {"docker"},
+	"deploy":                  {"helm", "kubectl"},
+	"createCluster":           {"terraform", "gcloud", "kubectl"},
+	"destroyCluster":          {"terraform"},
+	"installHelm":             {"helm"},
+	"resizeCluster":           {"gcloud"},
+	"resizePool":              {"gcloud"},
+	"promote":                 {"helm", "kubectl"},
+	"abort":                   {"helm", "kubectl"},
+	"releases":                {"helm"},
+	"tf":                      {"terraform"},
+	"drift":                   {"terraform", "gcloud"},
+	"schedule":                {"gcloud", "kubectl"},
+	"nodepool":                {"gcloud"},
+	"cost":                    {"gcloud"},
+	"validate":                {"helm"},
+	"nodes":                   {"gcloud"},
+	"backup":                  {"gcloud"},
+	"createSecret":            {"gcloud"},
+	"createCert":              {"gcloud"},
+	"updateDnsWithClusterIps": {"gcloud"},
+	// the state bucket and KMS are used through their APIs
+	"history": {},
+	"lock":    {},
+	"crypt":   {},
+	"names":   {},
+	"doctor":  {},
+}
+
+// doctorCmd checks the toolchain and credentials, reporting what's wrong and how to fix it
+//
+// myiac doctor [--keyPath /home/app/account.json]
+func doctorCmd(keyPath *cli.StringFlag) cli.Command {
+	return cli.Command{
+		Name:  "doctor",
+		Usage: "Check the tools and credentials myiac needs",
+		Flags: []cli.Flag{keyPath},
+		Action: func(c *cli.Context) error {
+			prefs := preferences.DefaultConfig()
+			keyLocation := c.String("keyPath")
+			if keyLocation == "" {
+				keyLocation = prefs.Get("keyLocation")
+			}
+
+			results := doctor.New().Run(doctor.Options{
+				KeyLocation:     keyLocation,
+				ExpectedContext: cluster.ActiveKubeContext(prefs),
+				ChartsPath:      deploy.ChartsPath(),
+			})
+			if err := printDoctorResults(results); err != nil {
+				return err
+			}
+			if doctor.HasFailures(results) {
+				return cli.NewExitError("Some checks failed", 1)
+			}
+			return nil
+		},
+	}
+}
+
+// withPreflight checks the binaries a command needs are present before running it, unless
+// --skip-preflight is given. Subcommands are checked with the binaries of their parent command
+func withPreflight(commands []cli.Command) {
+	for i := range commands {
+		binaries, found := commandBinaries[commands[i].Name]
+		if !found {
+			binaries = requiredBinaries()
+		}
+		if len(binaries) == 0 {
+			continue
+		}
+		wrapWithPreflight(&commands[i], binaries)
+	}
+}
+
+// requiredBinaries the names of the binaries every command may need, the optional ones left out
+func requiredBinaries() []string {
+	var binaries []string
+	for _, binary := range doctor.Binaries {
+		if !binary.Optional {
+			binaries = append(binaries, binary.Name)
+		}
+	}
+	return binaries
+}
+
+func wrapWithPreflight(command *cli.Command, binaries []string) {
+	for i := range command.Subcommands {
+		wrapWithPreflight(&command.Subcommands[i], binaries)
+	}
+
+	action, ok := command.Action.(func(*cli.Context) error)
+	if !ok {
+		return
+	}
+	command.Action = func(c *cli.Context) error {
+		if !c.GlobalBool("skip-preflight") {
+			if err := preflight(binaries); err != nil {
+				return err
+			}
+		}
+		return action(c)
+	}
+}
+
+func preflight(binaries []string) error {
+	results := doctor.New().Preflight(binaries)
+	if !doctor.HasFailures(results) {
+		return nil
+	}
+	for _, result := range results {
+		if result.Status == doctor.StatusFail {
+			fmt.Printf("%s: %s, %s\n", result.Check, result.Message, result.Hint)
+		}
+	}
+	return cli.NewExitError("Preflight checks failed, run 'myiac doctor' for details", 1)
+}
+
+func printDoctorResults(results []doctor.Result) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHECK\tSTATUS\tDETAILS")
+	for _, result := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", result.Check, result.Status, result.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Hint != "" && result.Status != doctor.StatusPass {
+			fmt.Printf("- %s: %s\n", result.Check, result.Hint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/iac-io/myiac/internal/cluster"
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/urfave/cli"
+)
+
+// exit code when drift is found, the same terraform uses for plans with changes
+const driftExitCode = 2
+
+// driftCmd reports the differences between the config of an environment and what is running.
+// Exits with 0 when there's no drift, 2 when there is and 1 on errors, to run it as a scheduled job
+//
+// myiac drift --project moneycol --env dev --keyPath /home/app/account.json [--output json]
+func driftCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, keyPath *cli.StringFlag,
+	tfConfigPath *cli.StringFlag, zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag,
+	clusterPrefix *cli.StringFlag) cli.Command {
+	outputFlag := &cli.StringFlag{Name: "output, o", Value: "text", Usage: "Report format: text or json"}
+
+	return cli.Command{
+		Name:  "drift",
+		Usage: "Detect drift between the terraform config of an environment and its infrastructure",
+		Flags: append([]cli.Flag{
+			projectFlag,
+			environmentFlag,
+			keyPath,
+			tfConfigPath,
+			zoneFlag,
+			regionFlag,
+			clusterPrefix,
+			outputFlag,
+		}, clusterSettingsFlags()...),
+		Action: func(c *cli.Context) error {
+			project := validateStringFlagPresence("project", c)
+			env := validateStringFlagPresence("env", c)
+			output := c.String("output")
+			if output != "text" && output != "json" {
+				return cli.NewExitError(fmt.Sprintf("unknown output format %s, use text or json", output), 1)
+			}
+			if output == "json" {
+				commandline.ShowProgressOnStderr()
+			}
+			if c.IsSet("keyPath") {
+				gcp.SetKeyEnvVar(c.String("keyPath"))
+			}
+
+			settings, err := clusterSettings(c, project, env)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			report, err := cluster.DetectDrift(settings, c.String("tfConfigPath"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if output == "json" {
+				err = printDriftJson(report)
+			} else {
+				printDrift(report)
+			}
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if report.HasDrift() {
+				return cli.NewExitError("", driftExitCode)
+			}
+			return nil
+		},
+	}
+}
+
+func printDriftJson(report *cluster.DriftReport) error {
+	reportJson, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing drift report: %v", err)
+	}
+	fmt.Println(string(reportJson))
+	return nil
+}
+
+func printDrift(report *cluster.DriftReport) {
+	if !report.HasDrift() {
+		fmt.Printf("No drift found in %s/%s\n", report.Project, report.Environment)
+		return
+	}
+
+	fmt.Printf("Drift found in %s/%s\n", report.Project, report.Environment)
+	for _, resource := range report.Resources {
+		fmt.Printf("  %s: %s\n", resource.Address, resource.Action)
+	}
+	for _, nodePool := range report.NodePools {
+		fmt.Printf("  %s\n", nodePool)
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/history"
+	"github.com/urfave/cli"
+)
+
+// historyCmd lists the deployments recorded for an environment
+//
+// myiac history --project moneycol --env dev [--app moneycol-server] [--limit 20]
+func historyCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	appNameFlag := &cli.StringFlag{Name: "app, a", Usage: "Only show deployments of this app"}
+	limitFlag := &cli.IntFlag{Name: "limit", Value: 20, Usage: "Maximum number of deployments to show (0 for all)"}
+
+	return cli.Command{
+		Name:  "history",
+		Usage: "Show the deployment history of an environment",
+		Flags: []cli.Flag{
+			projectFlag,
+			environmentFlag,
+			appNameFlag,
+			limitFlag,
+		},
+		Action: func(c *cli.Context) error {
+			project := validateStringFlagPresence("project", c)
+			env := validateStringFlagPresence("env", c)
+
+			records, err := history.NewDefaultStore(project).Query(env, c.String("app"), c.Int("limit"))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "STARTED\tAPP\tCHART\tIMAGE TAG\tVALUES\tCOMMIT\tUSER\tRESULT\tDURATION")
+			for _, r := range records {
+				valuesHash := r.ValuesHash
+				if len(valuesHash) > 12 {
+					valuesHash = valuesHash[:12]
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.StartedAt.Format(time.RFC3339),
+					r.App, r.ChartVersion, r.ImageTag, valuesHash, r.GitCommit, r.User, r.Result, r.Duration)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// startDeploymentRecord saves the 'started' record of a deployment. History is best effort,
+// failing to save it doesn't stop the deployment. A deployment that exits halfway stays as 'started'.
+// Only helm charts are described (chart version, image tag...), 'describe' is false for other apps
+func startDeploymentRecord(project string, app string, env string, propertiesMap map[string]string,
+	describe bool) *history.Record {
+	record := history.NewRecord(project, app, env)
+
+	if describe {
+		describeDeployment(record, propertiesMap)
+	}
+
+	if err := history.NewDefaultStore(project).Save(record); err != nil {
+		log.Printf("[WARN] could not save deployment record: %v", err)
+	}
+	return record
+}
+
+func finishDeploymentRecord(project string, record *history.Record, deployErr error) {
+	record.Finish(deployErr)
+	if err := history.NewDefaultStore(project).Save(record); err != nil {
+		log.Printf("[WARN] could not save deployment record: %v", err)
+	}
+}
+
+func describeDeployment(record *history.Record, propertiesMap map[string]string) {
+	description, err := deploy.Describe(record.App, propertiesMap)
+	if err != nil {
+		log.Printf("[WARN] could not describe deployment of %s: %v", record.App, err)
+		return
+	}
+
+	record.ChartVersion = description.ChartVersion
+	record.ImageTag = description.ImageTag
+	record.ValuesHash = description.ValuesHash
+	record.GitCommit = description.GitCommit
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/iac-io/myiac/internal/cluster"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/iac-io/myiac/internal/lock"
+	"github.com/iac-io/myiac/internal/preferences"
+	"github.com/urfave/cli"
+)
+
+// lockCmd manual control of the environment deployment lock
+//
+// myiac lock status --project moneycol --env dev
+// myiac lock release --project moneycol --env dev [--force]
+func lockCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	forceFlag := &cli.BoolFlag{Name: "force", Usage: "Release the lock even if it's owned by someone else"}
+
+	return cli.Command{
+		Name:  "lock",
+		Usage: "Inspect or release the lock that prevents concurrent mutating commands on an environment",
+		Subcommands: []cli.Command{
+			{
+				Name:  "status",
+				Usage: "Show who holds the lock for an environment",
+				Flags: []cli.Flag{projectFlag, environmentFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					current, err := lock.NewDefaultLocker(project, env).Status()
+					if err != nil {
+						return err
+					}
+
+					if current == nil {
+						fmt.Printf("%s/%s is not locked\n", project, env)
+					} else {
+						fmt.Printf("%s\n", current)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "release",
+				Usage: "Release the lock for an environment",
+				Flags: []cli.Flag{projectFlag, environmentFlag, forceFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+					return lock.NewDefaultLocker(project, env).Release(c.Bool("force"))
+				},
+			},
+		},
+	}
+}
+
+// withClusterLock runs a change to the cluster of the project environment like withEnvironmentLock,
+// once checked the kubeconfig in use points to that cluster and not to the one of another environment
+func withClusterLock(project string, env string, operation string, action func() error) error {
+	kubeContext, err := kubernetes.CurrentContext()
+	if err != nil {
+		return err
+	}
+	if err := cluster.VerifyKubeContext(preferences.DefaultConfig(), kubeContext, project, env); err != nil {
+		return err
+	}
+	return withEnvironmentLock(project, env, operation, action)
+}
+
+// withEnvironmentLock runs 'action' holding the lock of the project environment, so no other
+// mutating command can run against it at the same time. The lock is renewed while 'action' runs,
+// which must return its errors rather than exit so the lock is released
+func withEnvironmentLock(project string, env string, operation string, action func() error) error {
+	if err := gcp.EnsureGCSBucket(project, gcp.ProjectBucketName(project)); err != nil {
+		return fmt.Errorf("error preparing lock bucket: %v", err)
+	}
+
+	locker := lock.NewDefaultLocker(project, env)
+	if _, err := locker.Acquire(operation); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := locker.Release(false); err != nil {
+			log.Printf("[WARN] could not release lock for %s/%s: %v", project, env, err)
+		}
+	}()
+
+	stopRenewal := renewLockPeriodically(locker, lock.DefaultTTL/3)
+	defer stopRenewal()
+
+	return action()
+}
+
+// renewLockPeriodically renews the lock held by 'locker' every 'period' until the returned function is called
+func renewLockPeriodically(locker lock.Locker, period time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := locker.Renew(); err != nil {
+					log.Printf("[WARN] could not renew lock: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
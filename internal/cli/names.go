@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/naming"
+	"github.com/urfave/cli"
+)
+
+// namesCmd the names of the resources of an environment, as every command resolves them
+//
+// myiac names --project moneycol --env dev [--prefix blue] [--app moneycol-server]
+func namesCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
+	return cli.Command{
+		Name:  "names",
+		Usage: "Print the names of the cluster, buckets, keys, releases and namespace of an environment",
+		Flags: []cli.Flag{projectFlag, environmentFlag, clusterPrefix,
+			&cli.StringFlag{Name: "app", Usage: "App whose release name is printed"},
+		},
+		Action: func(c *cli.Context) error {
+			project := validateStringFlagPresence("project", c)
+			env := validateStringFlagPresence("env", c)
+			app := c.String("app")
+			if app == "" {
+				app = "<app>"
+			}
+
+			convention, err := naming.For(project)
+			if err != nil {
+				return err
+			}
+			names := map[string]string{
+				naming.ResourceCluster:       convention.ClusterName(project, env, c.String("prefix")),
+				naming.ResourceStateBucket:   convention.StateBucketName(project, env),
+				naming.ResourceProjectBucket: convention.ProjectBucketName(project),
+				naming.ResourceKeyRing:       convention.KeyRingName(project),
+				naming.ResourceKey:           convention.KeyName(project),
+				naming.ResourceRelease:       convention.ReleaseName(project, env, app),
+				naming.ResourceNamespace:     convention.NamespaceName(project, env),
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "RESOURCE\tNAME\tTEMPLATE")
+			for _, resource := range naming.Resources {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", resource, names[resource], convention.Template(resource))
+			}
+			return w.Flush()
+		},
+	}
+}
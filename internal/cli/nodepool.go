@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/urfave/cli"
+)
+
+// nodepoolCmd lifecycle of the node pools of the cluster of an environment
+//
+// myiac nodepool list --project moneycol --env dev [--output json]
+// myiac nodepool create --project moneycol --env dev --pool-name batch-pool --spot --label workload=batch --taint dedicated=batch:NoSchedule --max-nodes 3
+// myiac nodepool autoscale --project moneycol --env dev --pool-name batch-pool --min-nodes 1 --max-nodes 5
+// myiac nodepool delete --project moneycol --env dev --pool-name batch-pool
+func nodepoolCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, keyPath *cli.StringFlag,
+	zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag, clusterPrefix *cli.StringFlag,
+	poolNameFlag *cli.StringFlag) cli.Command {
+	minNodesFlag := &cli.IntFlag{Name: "min-nodes", Usage: "Minimum number of nodes when autoscaling"}
+	maxNodesFlag := &cli.IntFlag{Name: "max-nodes", Usage: "Maximum number of nodes when autoscaling, enables it"}
+	locationFlags := []cli.Flag{projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix}
+
+	return cli.Command{
+		Name:  "nodepool",
+		Usage: "Create, delete, list and autoscale the node pools of the cluster of an environment",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the node pools of the cluster",
+				Flags: append(locationFlags,
+					&cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: table or json"}),
+				Action: func(c *cli.Context) error {
+					output := c.String("output")
+					if output != "table" && output != "json" {
+						return fmt.Errorf("unknown output format %s, use table or json", output)
+					}
+					if output == "json" {
+						commandline.ShowProgressOnStderr()
+					}
+					project, _, clusterName, location, err := nodepoolLocation(c)
+					if err != nil {
+						return err
+					}
+
+					nodePools, err := gcp.ListNodePools(commandline.NewEmpty(), project, clusterName, location)
+					if err != nil {
+						return err
+					}
+					if output == "json" {
+						return printNodePoolsJson(nodePools)
+					}
+					return printNodePools(nodePools)
+				},
+			},
+			{
+				Name:  "create",
+				Usage: "Create a node pool and wait for it to be running",
+				Flags: append(locationFlags, poolNameFlag, minNodesFlag, maxNodesFlag,
+					&cli.StringFlag{Name: "machine-type", Usage: "Machine type of the nodes (example: e2-standard-2)"},
+					&cli.IntFlag{Name: "num-nodes", Value: 1, Usage: "Initial number of nodes"},
+					&cli.BoolFlag{Name: "preemptible", Usage: "Use preemptible nodes"},
+					&cli.BoolFlag{Name: "spot", Usage: "Use spot nodes"},
+					&cli.StringSliceFlag{Name: "label", Usage: "Node label as key=value, can be repeated"},
+					&cli.StringSliceFlag{Name: "taint", Usage: "Node taint as key=value:effect, can be repeated"},
+				),
+				Action: func(c *cli.Context) error {
+					labels, err := gcp.ParseLabels(c.StringSlice("label"))
+					if err != nil {
+						return err
+					}
+					spec := gcp.NodePoolSpec{
+						Name:        validateStringFlagPresence("pool-name", c),
+						MachineType: c.String("machine-type"),
+						NodeCount:   c.Int("num-nodes"),
+						Preemptible: c.Bool("preemptible"),
+						Spot:        c.Bool("spot"),
+						Labels:      labels,
+						Taints:      c.StringSlice("taint"),
+						MinNodes:    c.Int("min-nodes"),
+						MaxNodes:    c.Int("max-nodes"),
+					}
+					if err := spec.Validate(); err != nil {
+						return err
+					}
+
+					project, env, clusterName, location, err := nodepoolLocation(c)
+					if err != nil {
+						return err
+					}
+					return withEnvironmentLock(project, env, "nodepool create", func() error {
+						return gcp.CreateNodePool(commandline.NewEmpty(), project, clusterName, location, spec)
+					})
+				},
+			},
+			{
+				Name:  "delete",
+				Usage: "Delete a node pool and wait for its nodes to be removed",
+				Flags: append(locationFlags, poolNameFlag),
+				Action: func(c *cli.Context) error {
+					poolName := validateStringFlagPresence("pool-name", c)
+					project, env, clusterName, location, err := nodepoolLocation(c)
+					if err != nil {
+						return err
+					}
+					return withEnvironmentLock(project, env, "nodepool delete", func() error {
+						return gcp.DeleteNodePool(commandline.NewEmpty(), project, clusterName, location, poolName)
+					})
+				},
+			},
+			{
+				Name:  "autoscale",
+				Usage: "Set the autoscaling limits of a node pool, --max-nodes 0 disables autoscaling",
+				Flags: append(locationFlags, poolNameFlag, minNodesFlag, maxNodesFlag),
+				Action: func(c *cli.Context) error {
+					poolName := validateStringFlagPresence("pool-name", c)
+					if !c.IsSet("max-nodes") {
+						return fmt.Errorf("--max-nodes is required, 0 disables autoscaling")
+					}
+					project, env, clusterName, location, err := nodepoolLocation(c)
+					if err != nil {
+						return err
+					}
+					return withEnvironmentLock(project, env, "nodepool autoscale", func() error {
+						return gcp.SetNodePoolAutoscaling(commandline.NewEmpty(), project, clusterName, location, poolName,
+							c.Int("min-nodes"), c.Int("max-nodes"))
+					})
+				},
+			},
+		},
+	}
+}
+
+// nodepoolLocation the project, environment, cluster name and location of the command
+func nodepoolLocation(c *cli.Context) (string, string, string, gcp.Location, error) {
+	project := validateStringFlagPresence("project", c)
+	env := validateStringFlagPresence("env", c)
+	if c.IsSet("keyPath") {
+		gcp.SetKeyEnvVar(c.String("keyPath"))
+	}
+
+	location, err := flagLocation(c)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	clusterName, location, err := clusterLocation(project, env, c.String("prefix"), location)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	return project, env, clusterName, gcp.Location(location), nil
+}
+
+func printNodePoolsJson(nodePools []gcp.NodePool) error {
+	nodePoolsJson, err := json.MarshalIndent(nodePools, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing node pools: %v", err)
+	}
+	fmt.Println(string(nodePoolsJson))
+	return nil
+}
+
+func printNodePools(nodePools []gcp.NodePool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tMACHINE TYPE\tINITIAL NODES\tAUTOSCALING\tPROVISIONING\tLABELS\tTAINTS\tSTATUS")
+	for _, pool := range nodePools {
+		autoscaling := "off"
+		if pool.Autoscaling.Enabled {
+			autoscaling = fmt.Sprintf("%d-%d", pool.Autoscaling.MinNodeCount, pool.Autoscaling.MaxNodeCount)
+		}
+		provisioning := "standard"
+		if pool.Config.Spot {
+			provisioning = "spot"
+		} else if pool.Config.Preemptible {
+			provisioning = "preemptible"
+		}
+
+		var labels []string
+		for key, value := range pool.Config.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		var taints []string
+		for _, taint := range pool.Config.Taints {
+			taints = append(taints, fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect))
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", pool.Name, pool.Config.MachineType,
+			pool.InitialNodeCount, autoscaling, provisioning, orNone(strings.Join(labels, ",")),
+			orNone(strings.Join(taints, ",")), pool.Status)
+	}
+	return w.Flush()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
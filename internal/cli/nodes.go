@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/urfave/cli"
+)
+
+// nodesCmd the nodes of the cluster of the current kubeconfig context
+//
+// myiac nodes ips --type external [--family ipv4] [--output json]
+func nodesCmd() cli.Command {
+	return cli.Command{
+		Name:  "nodes",
+		Usage: "Inspect the nodes of the current cluster",
+		Subcommands: []cli.Command{
+			{
+				Name:  "ips",
+				Usage: "List the addresses of the nodes that are Ready and not cordoned",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "type", Value: "external", Usage: "Address type: external, internal or hostname"},
+					&cli.StringFlag{Name: "family", Usage: "IP family: ipv4 or ipv6, both when not set"},
+					&cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: table or json"},
+				},
+				Action: func(c *cli.Context) error {
+					output := c.String("output")
+					if output != "table" && output != "json" {
+						return fmt.Errorf("unknown output format %s, use table or json", output)
+					}
+					if output == "json" {
+						commandline.ShowProgressOnStderr()
+					}
+					addressType, err := kubernetes.ParseAddressType(c.String("type"))
+					if err != nil {
+						return err
+					}
+					family := c.String("family")
+					if err := kubernetes.ValidateFamily(family); err != nil {
+						return err
+					}
+
+					kubeClient, err := kubernetes.NewDefaultClient()
+					if err != nil {
+						return err
+					}
+					addresses, err := kubeClient.NodeAddresses(context.Background(), addressType, family)
+					if err != nil {
+						return err
+					}
+					if output == "json" {
+						return printNodeAddressesJson(addresses)
+					}
+					return printNodeAddresses(addresses)
+				},
+			},
+		},
+	}
+}
+
+func printNodeAddressesJson(addresses []kubernetes.NodeAddress) error {
+	if addresses == nil {
+		addresses = []kubernetes.NodeAddress{}
+	}
+	addressesJson, err := json.MarshalIndent(addresses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing node addresses: %v", err)
+	}
+	fmt.Println(string(addressesJson))
+	return nil
+}
+
+func printNodeAddresses(addresses []kubernetes.NodeAddress) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NODE\tTYPE\tADDRESS\tFAMILY")
+	for _, address := range addresses {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", address.Node, address.Type, address.Address, orNone(address.Family))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/urfave/cli"
+)
+
+// releasesCmd maintenance of the helm releases of an environment
+//
+// myiac releases repair --project moneycol --env dev [--policy rollback|uninstall] [--dryRun]
+func releasesCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	policyFlag := &cli.StringFlag{Name: "policy", Value: deploy.RepairPolicyRollback,
+		Usage: "What to do with stuck releases: rollback (to the last good revision, uninstall if none) or uninstall"}
+	dryRunFlag := &cli.BoolFlag{Name: "dryRun", Usage: "Only report what would be done"}
+
+	return cli.Command{
+		Name:  "releases",
+		Usage: "Manage the helm releases of an environment",
+		Subcommands: []cli.Command{
+			{
+				Name:  "repair",
+				Usage: "Roll back or uninstall failed and pending releases, which block further deployments",
+				Flags: []cli.Flag{projectFlag, environmentFlag, policyFlag, dryRunFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+					gcp.SetupEnvironment(project)
+
+					return withClusterLock(project, env, "releases repair", func() error {
+						actions, err := deploy.RepairReleases(c.String("policy"), c.Bool("dryRun"))
+						if err != nil {
+							return err
+						}
+						return printRepairActions(actions, c.Bool("dryRun"))
+					})
+				},
+			},
+		},
+	}
+}
+
+func printRepairActions(actions []deploy.RepairAction, dryRun bool) error {
+	if len(actions) == 0 {
+		fmt.Println("No stuck releases found")
+		return nil
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RELEASE\tSTATUS\tACTION\tRESULT")
+	for _, action := range actions {
+		result := "done"
+		if dryRun {
+			result = "dry run"
+		}
+		if action.Err != nil {
+			result = action.Err.Error()
+			failed++
+		}
+
+		actionDescription := action.Action
+		if action.Revision > 0 {
+			actionDescription = fmt.Sprintf("%s to revision %d", action.Action, action.Revision)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action.Release, action.Status, actionDescription, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d stuck releases could not be repaired", failed), 1)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/urfave/cli"
+)
+
+// promoteCmd sends all the traffic to the candidate of a canary/bluegreen rollout
+//
+// myiac promote --project moneycol --env dev --app moneycol-server
+func promoteCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	return rolloutCmd("promote", "Promote the canary/bluegreen release of an app to stable, removing the candidate",
+		projectFlag, environmentFlag, deploy.RolloutManager.Promote)
+}
+
+// abortCmd sends all the traffic back to the stable release and removes the candidate
+//
+// myiac abort --project moneycol --env dev --app moneycol-server
+func abortCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag) cli.Command {
+	return rolloutCmd("abort", "Abort the canary/bluegreen rollout of an app, keeping the stable release",
+		projectFlag, environmentFlag, deploy.RolloutManager.Abort)
+}
+
+func rolloutCmd(name string, usage string, projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag,
+	action func(deploy.RolloutManager, context.Context, string) error) cli.Command {
+	appNameFlag := &cli.StringFlag{Name: "app, a", Usage: "The app being rolled out"}
+
+	return cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: []cli.Flag{
+			projectFlag,
+			environmentFlag,
+			appNameFlag,
+		},
+		Action: func(c *cli.Context) error {
+			project := validateStringFlagPresence("project", c)
+			env := validateStringFlagPresence("env", c)
+			app := validateStringFlagPresence("app", c)
+			gcp.SetupEnvironment(project)
+
+			rolloutManager := deploy.NewRolloutManager(projectKmsEncrypter(project))
+			return withClusterLock(project, env, name+" "+app, func() error {
+				return action(rolloutManager, context.Background(), app)
+			})
+		},
+	}
+}
+
+func startRollout(project string, env string, app string, propertiesMap map[string]string,
+	options deploy.RolloutOptions) error {
+	rolloutManager := deploy.NewRolloutManager(projectKmsEncrypter(project))
+	return withClusterLock(project, env, options.Strategy+" "+app, func() error {
+		if options.DryRun {
+			return rolloutManager.Start(context.Background(), app, env, propertiesMap, options)
+		}
+		record := startDeploymentRecord(project, app, env, propertiesMap, true)
+		err := rolloutManager.Start(context.Background(), app, env, propertiesMap, options)
+		finishDeploymentRecord(project, record, err)
+		return err
+	})
+}
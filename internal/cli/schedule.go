@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/naming"
+	"github.com/iac-io/myiac/internal/schedule"
+	"github.com/iac-io/myiac/internal/secret"
+	"github.com/urfave/cli"
+)
+
+// scheduleCmd off-hours scale down and scale up of the node pools of an environment, following the
+// schedule windows of the project manifest
+//
+// myiac schedule apply --project moneycol --env dev --image eu.gcr.io/moneycol/myiac:latest --keyPath /home/app/account.json
+// myiac schedule status --project moneycol --env dev
+// myiac schedule run --project moneycol --env dev --cluster moneycol-dev --zone europe-west2-b --node-pool default-pool --action scale-down
+func scheduleCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, keyPath *cli.StringFlag,
+	zoneFlag *cli.StringFlag, regionFlag *cli.StringFlag, clusterPrefix *cli.StringFlag) cli.Command {
+	return cli.Command{
+		Name:  "schedule",
+		Usage: "Scale node pools down during off-hours and back up afterwards",
+		Subcommands: []cli.Command{
+			{
+				Name:  "apply",
+				Usage: "Deploy the CronJobs resizing the node pools at the times of the schedule windows",
+				Flags: []cli.Flag{projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag, clusterPrefix,
+					&cli.StringFlag{Name: "image", Usage: "myiac image the CronJobs run (default eu.gcr.io/<project>/myiac:latest)"},
+					&cli.StringFlag{Name: "runner-pool", Usage: "Node pool the CronJobs run on, one that is never scaled to zero"},
+				},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					windows, err := scheduleWindows(project, env)
+					if err != nil {
+						return err
+					}
+					target, err := scheduleTarget(c, project, env)
+					if err != nil {
+						return err
+					}
+
+					image := c.String("image")
+					if image == "" {
+						image = fmt.Sprintf("%s/%s/myiac:latest", GCRPrefix, project)
+					}
+					runnerPool := c.String("runner-pool")
+					if runnerPool == "" && len(windows) > 0 {
+						if runnerPool, err = scheduleRunnerPool(target, windows); err != nil {
+							return err
+						}
+					}
+
+					return withClusterLock(project, env, "schedule apply", func() error {
+						kubeClient, err := kubernetes.NewDefaultClient()
+						if err != nil {
+							return err
+						}
+						if err := kubeClient.EnsureNamespace(context.Background(), target.Namespace); err != nil {
+							return err
+						}
+						if key := c.String("keyPath"); key != "" {
+							secret.CreateKubernetesSecretManager(target.Namespace).CreateFileSecret(schedule.KeySecretName, key)
+						} else if len(windows) > 0 {
+							// the CronJobs authenticate with the key of the secret, they'd never start without it
+							keySecret, err := kubeClient.GetSecret(context.Background(), target.Namespace, schedule.KeySecretName)
+							if err != nil {
+								return err
+							}
+							if keySecret == nil {
+								return fmt.Errorf("secret %s not found in namespace %s, use --keyPath to create it with "+
+									"the service account key the CronJobs run with", schedule.KeySecretName, target.Namespace)
+							}
+						}
+						options := schedule.CronJobOptions{Image: image, RunnerPool: runnerPool}
+						if err := schedule.ApplyCronJobs(commandline.NewEmpty(), target, windows, options); err != nil {
+							return err
+						}
+						fmt.Printf("Schedule of %s/%s applied with %d windows\n", project, env, len(windows))
+						return nil
+					})
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "Show the next scheduled actions and the node pools currently scaled down",
+				Flags: []cli.Flag{projectFlag, environmentFlag},
+				Action: func(c *cli.Context) error {
+					project := validateStringFlagPresence("project", c)
+					env := validateStringFlagPresence("env", c)
+
+					windows, err := scheduleWindows(project, env)
+					if err != nil {
+						return err
+					}
+					actions, err := schedule.NextActions(windows, time.Now().UTC())
+					if err != nil {
+						return err
+					}
+
+					scaler := schedule.NewDefaultScaler(schedule.Target{Project: project, Environment: env})
+					return printScheduleStatus(scaler, windows, actions)
+				},
+			},
+			{
+				Name:  "run",
+				Usage: "Scale a node pool down or up now, run by the schedule CronJobs",
+				Flags: []cli.Flag{projectFlag, environmentFlag, keyPath, zoneFlag, regionFlag,
+					&cli.StringFlag{Name: "cluster", Usage: "Cluster name"},
+					&cli.StringFlag{Name: "node-pool", Usage: "Node pool to resize"},
+					&cli.StringFlag{Name: "action", Usage: "scale-down or scale-up"},
+					&cli.IntFlag{Name: "size", Usage: "Size to scale down to"},
+				},
+				Action: func(c *cli.Context) error {
+					location, err := flagLocation(c)
+					if err != nil {
+						return err
+					}
+					if location == "" {
+						return fmt.Errorf("the cluster zone or region is required")
+					}
+					target := schedule.Target{
+						Project:     validateStringFlagPresence("project", c),
+						Environment: validateStringFlagPresence("env", c),
+						ClusterName: validateStringFlagPresence("cluster", c),
+						Location:    gcp.Location(location),
+					}
+					nodePool := validateStringFlagPresence("node-pool", c)
+					action := validateStringFlagPresence("action", c)
+
+					if key := c.String("keyPath"); key != "" {
+						gcp.SetKeyEnvVar(key)
+						auth, err := gcp.NewServiceAccountAuth(key)
+						if err != nil {
+							return err
+						}
+						auth.Authenticate()
+					}
+
+					scaler := schedule.NewDefaultScaler(target)
+					switch action {
+					case schedule.ActionScaleDown:
+						return scaler.ScaleDown(nodePool, c.Int("size"))
+					case schedule.ActionScaleUp:
+						return scaler.ScaleUp(nodePool)
+					default:
+						return fmt.Errorf("unknown action %s, use %s or %s", action, schedule.ActionScaleDown,
+							schedule.ActionScaleUp)
+					}
+				},
+			},
+		},
+	}
+}
+
+func scheduleWindows(project string, env string) ([]manifest.ScheduleWindow, error) {
+	projectManifest, err := manifest.Load(project)
+	if err != nil {
+		return nil, err
+	}
+	windows := projectManifest.ScheduleFor(env)
+	if err := schedule.ValidateWindows(windows); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+func scheduleTarget(c *cli.Context, project string, env string) (schedule.Target, error) {
+	location, err := flagLocation(c)
+	if err != nil {
+		return schedule.Target{}, err
+	}
+	clusterName, location, err := clusterLocation(project, env, c.String("prefix"), location)
+	if err != nil {
+		return schedule.Target{}, err
+	}
+	return schedule.Target{Project: project, Environment: env, ClusterName: clusterName,
+		Location: gcp.Location(location), Namespace: naming.MustFor(project).NamespaceName(project, env)}, nil
+}
+
+// scheduleRunnerPool a live node pool of the cluster the scale up jobs can run on
+func scheduleRunnerPool(target schedule.Target, windows []manifest.ScheduleWindow) (string, error) {
+	nodePools, err := gcp.ListNodePools(commandline.NewEmpty(), target.Project, target.ClusterName, target.Location)
+	if err != nil {
+		return "", err
+	}
+
+	var poolNames []string
+	for _, nodePool := range nodePools {
+		poolNames = append(poolNames, nodePool.Name)
+	}
+	runnerPool, err := schedule.RunnerPoolFor(windows, poolNames)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Schedule CronJobs will run on node pool %s\n", runnerPool)
+	return runnerPool, nil
+}
+
+func printScheduleStatus(scaler *schedule.Scaler, windows []manifest.ScheduleWindow, actions []schedule.Action) error {
+	if len(windows) == 0 {
+		fmt.Println("No schedule windows in the project manifest")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME (UTC)\tNODE POOL\tACTION")
+	for _, action := range actions {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", action.Time.Format("Mon 2006-01-02 15:04"), action.NodePool, action)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, window := range windows {
+		scaledDown, err := scaler.ScaledDown(window.NodePool)
+		if err != nil {
+			log.Printf("[WARN] could not read the original size of %s: %v", window.NodePool, err)
+			continue
+		}
+		if scaledDown != nil {
+			fmt.Printf("%s is scaled down to %d nodes since %s, it will be restored to %d\n", window.NodePool,
+				scaledDown.ScaledDownTo, scaledDown.ScaledDownAt.Format(time.RFC3339), scaledDown.OriginalSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/cluster"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/naming"
+	"github.com/iac-io/myiac/internal/preferences"
+	"github.com/iac-io/myiac/internal/terraform"
+	"github.com/urfave/cli"
+)
+
+// clusterSettingsFlags flags overriding the cluster settings of the project manifest
+func clusterSettingsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "machine-type", Usage: "Machine type of the applications node pool (example: n1-standard-2)"},
+		&cli.IntFlag{Name: "node-count", Usage: "Initial (and minimum) number of nodes of the applications node pool"},
+		&cli.IntFlag{Name: "max-node-count", Usage: "Maximum number of nodes of the applications node pool"},
+		&cli.BoolFlag{Name: "preemptible", Usage: "Use preemptible nodes (--preemptible=false for regular ones)"},
+		&cli.StringSliceFlag{Name: "var", Usage: "Terraform variable override as key=value, can be repeated"},
+	}
+}
+
+// clusterSettings the cluster settings of the project manifest for the environment, overridden by flags
+func clusterSettings(c *cli.Context, project string, env string) (cluster.ClusterSettings, error) {
+	projectManifest, err := manifest.Load(project)
+	if err != nil {
+		return cluster.ClusterSettings{}, err
+	}
+
+	if _, err := flagLocation(c); err != nil {
+		return cluster.ClusterSettings{}, err
+	}
+	flagSettings := manifest.Cluster{
+		Zone:         c.String("zone"),
+		Region:       c.String("region"),
+		Prefix:       c.String("prefix"),
+		MachineType:  c.String("machine-type"),
+		NodeCount:    c.Int("node-count"),
+		MaxNodeCount: c.Int("max-node-count"),
+	}
+	if c.IsSet("preemptible") {
+		preemptible := c.Bool("preemptible")
+		flagSettings.Preemptible = &preemptible
+	}
+
+	settings := cluster.ClusterSettings{
+		Project:      project,
+		Environment:  env,
+		Cluster:      projectManifest.ClusterFor(env).Merge(flagSettings),
+		VarOverrides: c.StringSlice("var"),
+	}
+	if _, err := settings.VarArgs(); err != nil {
+		return cluster.ClusterSettings{}, err
+	}
+	return settings, nil
+}
+
+func requireClusterLocation(settings cluster.ClusterSettings) error {
+	if settings.Location() == "" {
+		return fmt.Errorf("cluster location is required, set it with --zone, --region or in the project manifest")
+	}
+	return nil
+}
+
+// flagLocation the cluster location given with --zone or --region, empty when there's none
+func flagLocation(c *cli.Context) (string, error) {
+	zone, region := c.String("zone"), c.String("region")
+	if zone != "" && region != "" {
+		return "", fmt.Errorf("use either --zone (zonal clusters) or --region (regional clusters), not both")
+	}
+	if region != "" {
+		return region, nil
+	}
+	return zone, nil
+}
+
+// clusterLocation the name and location (zone or region) of the cluster of a project environment:
+// the terraform outputs saved by createCluster, or the name of the naming convention when there are
+// none (with the prefix of the project manifest when 'prefix' is empty). A location given explicitly
+// takes precedence
+func clusterLocation(project string, env string, prefix string, location string) (string, string, error) {
+	if outputs := cluster.SavedClusterOutputs(preferences.DefaultConfig(), project, env); outputs != nil {
+		if location == "" {
+			location = outputs.ClusterLocation
+		}
+		log.Printf("Using cluster %s (%s) from terraform outputs\n", outputs.ClusterName, location)
+		return outputs.ClusterName, location, nil
+	}
+
+	if location == "" {
+		return "", "", fmt.Errorf("no terraform outputs saved for %s/%s, the cluster zone or region is required",
+			project, env)
+	}
+
+	convention, err := naming.For(project)
+	if err != nil {
+		return "", "", err
+	}
+	return convention.ClusterName(project, env, prefix), location, nil
+}
+
+// manifestLocation the cluster location of the environment in the project manifest, 'defaultLocation'
+// when it has none
+func manifestLocation(project string, env string, defaultLocation string) (gcp.Location, error) {
+	projectManifest, err := manifest.Load(project)
+	if err != nil {
+		return "", err
+	}
+	if location := projectManifest.ClusterFor(env).Location(); location != "" {
+		return gcp.Location(location), nil
+	}
+	return gcp.Location(defaultLocation), nil
+}
+
+// planApprover approves every plan with --approve, otherwise asks for confirmation. Without an
+// answer (i.e. no terminal) the plan isn't approved
+func planApprover(approve bool) cluster.PlanApprover {
+	return func(summary *cluster.PlanSummary) bool {
+		if approve {
+			return true
+		}
+
+		question := "Apply this plan? Only 'yes' will be accepted: "
+		if summary.HasDestructiveChanges() {
+			question = "This plan DESTROYS or REPLACES resources. Apply it? Only 'yes' will be accepted: "
+		}
+		fmt.Print(question)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		return strings.TrimSpace(answer) == "yes"
+	}
+}
+
+// tfCmd read only inspection of the terraform state of an environment, no lock is taken
+//
+// myiac tf state list --project moneycol --env dev [--tfConfigPath path]
+// myiac tf state show --project moneycol --env dev 'google_container_cluster.cluster'
+// myiac tf state lock --project moneycol --env dev
+//
+// and scaffolding of the default cluster config, to edit it and use it with --tfConfigPath
+//
+// myiac tf init-config [--dir terraform/cluster] [--force]
+func tfCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, tfConfigPath *cli.StringFlag) cli.Command {
+	flags := []cli.Flag{projectFlag, environmentFlag, tfConfigPath}
+
+	return cli.Command{
+		Name:  "tf",
+		Usage: "Inspect the terraform state of an environment and scaffold terraform configs",
+		Subcommands: []cli.Command{
+			{
+				Name:  "init-config",
+				Usage: "Write an editable copy of the default cluster config",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "dir", Value: "terraform/cluster", Usage: "Directory to write the config to"},
+					&cli.BoolFlag{Name: "force", Usage: "Overwrite existing files"},
+				},
+				Action: func(c *cli.Context) error {
+					dir := c.String("dir")
+					if err := terraform.WriteClusterModule(dir, c.Bool("force")); err != nil {
+						return cli.NewExitError(fmt.Sprintf("%v, use --force to overwrite it", err), 1)
+					}
+					fmt.Printf("Cluster config (version %s) written to %s, use it with --tfConfigPath %s\n",
+						terraform.ClusterModuleVersion(), dir, dir)
+					return nil
+				},
+			},
+			{
+				Name:  "state",
+				Usage: "Read the remote state of the cluster of an environment",
+				Subcommands: []cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the resources in the state",
+						Flags: flags,
+						Action: func(c *cli.Context) error {
+							tfPath, backend, err := stateBackend(c)
+							if err != nil {
+								return err
+							}
+							addresses, err := cluster.StateList(tfPath, backend)
+							if err != nil {
+								return err
+							}
+							for _, address := range addresses {
+								fmt.Println(address)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "Show the attributes of a resource in the state",
+						ArgsUsage: "<address>",
+						Flags:     flags,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf("the address of the resource to show is required")
+							}
+							tfPath, backend, err := stateBackend(c)
+							if err != nil {
+								return err
+							}
+							resource, err := cluster.StateShow(tfPath, backend, c.Args().First())
+							if err != nil {
+								return err
+							}
+							fmt.Println(resource)
+							return nil
+						},
+					},
+					{
+						Name:  "lock",
+						Usage: "Show who holds the state lock, if anyone",
+						Flags: flags,
+						Action: func(c *cli.Context) error {
+							_, backend, err := stateBackend(c)
+							if err != nil {
+								return err
+							}
+							lock, err := cluster.CurrentStateLock(gcp.NewDefaultObjectStorageCache(), backend)
+							if err != nil {
+								return err
+							}
+							if lock == nil {
+								fmt.Printf("State %s is not locked\n", backend)
+								return nil
+							}
+							fmt.Println(lock)
+							return nil
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// stateBackend the terraform config dir and the backend of the environment in the flags
+func stateBackend(c *cli.Context) (string, cluster.TerraformBackend, error) {
+	project := validateStringFlagPresence("project", c)
+	env := validateStringFlagPresence("env", c)
+	gcp.SetupEnvironment(project)
+
+	tfPath, _, err := cluster.ValidateTFVars(c.String("tfConfigPath"))
+	if err != nil {
+		return "", cluster.TerraformBackend{}, err
+	}
+	return tfPath, cluster.BackendFor(project, env), nil
+}
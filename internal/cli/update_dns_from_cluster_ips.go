@@ -8,6 +8,8 @@ import (
 	"github.com/iac-io/myiac/internal/cluster"
 	"github.com/iac-io/myiac/internal/deploy"
 	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	"github.com/iac-io/myiac/internal/preferences"
 	"github.com/urfave/cli"
 )
 
@@ -40,22 +42,34 @@ func updateDnsFromClusterIpsCmd() cli.Command {
 			dnsProvider := c.String("dnsProvider")
 			domainName := c.String("domain")
 
-			cluster.ProviderSetup()
+			// the cluster set up by createCluster/setupEnvironment
+			projectId, env := cluster.ActiveClusterProjectEnv(preferences.DefaultConfig())
+			if projectId == "" || env == "" {
+				return fmt.Errorf("no active cluster, run 'myiac setupEnvironment' for the project and environment first")
+			}
+
+			if err := cluster.ProviderSetup(); err != nil {
+				return err
+			}
 
-			_, err := gcp.NewDNSChangeRequest(dnsProvider, domainName, "moneycol")
+			_, err := gcp.NewDNSChangeRequest(dnsProvider, domainName, projectId)
 
 			if err != nil {
 				return fmt.Errorf("error: invalid DNS change request %s", err)
 			}
 
-			projectId := "moneycol"
-			env := "dev"
-
 			deployer := deploy.NewDeployer()
-			dnsService, _ := gcp.NewDNSService(dnsProvider, "moneycol")
-			gkeService := cluster.NewGkeClusterService(deployer, dnsService, domainName, projectId, env)
+			dnsService, err := gcp.NewDNSService(dnsProvider, projectId)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := kubernetes.NewDefaultClient()
+			if err != nil {
+				return err
+			}
+			gkeService := cluster.NewGkeClusterService(deployer, dnsService, kubeClient, domainName, projectId, env)
 
-			dnsEntries := services.NewServiceProps("moneycol").DnsEntries
+			dnsEntries := services.NewServiceProps(projectId).DnsEntries
 			err = gkeService.UpdateDnsFromClusterIps(dnsEntries)
 
 			if err != nil {
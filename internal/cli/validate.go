@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/urfave/cli"
+)
+
+// validateCmd runs the pre-deploy checks of an app without deploying it
+//
+// myiac validate --project moneycol --app moneycol-server --env prod --properties image.tag=0.1.0-abcd
+func validateCmd(projectFlag *cli.StringFlag, environmentFlag *cli.StringFlag, propertiesFlag *cli.StringFlag) cli.Command {
+	appNameFlag := &cli.StringFlag{Name: "app, a",
+		Usage: "The app to validate. A helm chart with the same name must exist in the CHARTS_LOCATION"}
+
+	return cli.Command{
+		Name:  "validate",
+		Usage: "Lint the chart of an app or render its manifests and validate them for an environment without deploying",
+		Flags: []cli.Flag{
+			projectFlag,
+			environmentFlag,
+			appNameFlag,
+			propertiesFlag,
+		},
+		Action: func(c *cli.Context) error {
+			fmt.Printf("Validating flags for validate\n")
+			project := validateStringFlagPresence("project", c)
+			appName := validateStringFlagPresence("app", c)
+			env := validateStringFlagPresence("env", c)
+			propertiesMap := readPropertiesToMap(c.String("properties"))
+
+			projectManifest, err := manifest.Load(project)
+			if err != nil {
+				return err
+			}
+
+			report := deploy.Validate(projectManifest, projectKmsEncrypter(project), appName, env, propertiesMap)
+			report.Print()
+
+			if report.HasErrors() {
+				return cli.NewExitError(fmt.Sprintf("validation of %s for %s failed", appName, env), 1)
+			}
+			return nil
+		},
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/iac-io/myiac/internal/commandline"
 	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/preferences"
+	"github.com/iac-io/myiac/internal/terraform"
 	"github.com/iac-io/myiac/internal/util"
 )
 
@@ -24,77 +26,150 @@ func InstallHelm() {
 	}
 }
 
-func InitTerraform(tf string, project string, env string) {
+// InitTerraform creates the state bucket of the environment if not present and initializes terraform
+// with its backend, reconfiguring it when the config was initialized for another environment
+func InitTerraform(tf string, project string, env string) error {
 	// Create Bucket if not present in the system
 	err := gcp.CreateGCSBucket(project, env)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	// Check if terraform initialized
-	if _, err := os.Stat(tf + "/.terraform"); os.IsNotExist(err) {
-		fmt.Printf("Terraform not Initialized: in %v: Intializong now...", tf+"/.terraform\n")
-		argsArray := util.StringTemplateToArgsArray("%s", "init")
-		cmd := commandline.NewWithWorkingDir("terraform", argsArray, tf)
-		cmd.Run()
-	}
-}
 
-func PlanTerraform(tp string, tf string) {
-	argsArray := util.StringTemplateToArgsArray("%s %s", "plan", "-var-file="+tf)
-	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tp)
-	cmd.Run()
-	log.Printf("Terraform PLAN for %v finished", tf)
+	return EnsureBackend(tf, BackendFor(project, env))
 }
 
-func ApplyTerraform(tp string, tf string) {
-	argsArray := util.StringTemplateToArgsArray("%s %s %s", "apply", "-var-file="+tf, "-auto-approve")
-	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tp)
-	cmd.Run()
-	log.Printf("Terraform APPLY for %v finished", tf)
-}
+// CreateCluster plans the terraform config with the cluster settings, written as an auto loaded
+// variables file, and their -var overrides. The plan summary is shown and, once approved, exactly
+// that plan is applied: the terraform outputs are then saved as the active cluster context and
+// returned (nil in dry run, where the plan is only shown)
+func CreateCluster(settings ClusterSettings, dryrun bool, tfConfigPath string, approve PlanApprover) (*ClusterOutputs, error) {
+	tfvarsPath, _, err := ValidateTFVars(tfConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	varArgs, err := prepareTfVars(tfvarsPath, settings)
+	if err != nil {
+		return nil, err
+	}
 
-//TODO: pass variables into the TF template/clustervars
-func CreateCluster(project string, env string, dryrun bool, tfConfigPath string) error {
-	tfvarsPath, tfvarsFile := ValidateTFVars(tfConfigPath)
-	InitTerraform(tfConfigPath, project, env)
+	if err := InitTerraform(tfvarsPath, settings.Project, settings.Environment); err != nil {
+		return nil, err
+	}
+	summary, err := PlanTerraform(tfvarsPath, varArgs...)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print(summary)
 	if dryrun {
-		PlanTerraform(tfvarsPath, tfvarsFile)
-	} else {
-		ApplyTerraform(tfvarsPath, tfvarsFile)
+		return nil, nil
 	}
 
-	return nil
+	if summary.HasChanges() {
+		if !approve(summary) {
+			return nil, ErrPlanNotApproved
+		}
+		reportStateLock(BackendFor(settings.Project, settings.Environment))
+		if err := ApplyTerraform(tfvarsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	outputs, err := ReadClusterOutputs(tfvarsPath)
+	if err != nil {
+		return nil, err
+	}
+	SaveClusterOutputs(preferences.DefaultConfig(), settings.Project, settings.Environment, outputs)
+	return outputs, nil
 }
 
-func DestroyCluster(project string, env string, tfConfigPath string) {
-	tfvarsPath, tfvarsFile := ValidateTFVars(tfConfigPath)
-	InitTerraform(tfvarsPath, project, env)
+// DestroyCluster destroys the terraform resources of the environment. With 'purgeState' the state
+// bucket is archived into 'stateBackupDir' and deleted, otherwise what was left behind is reported
+func DestroyCluster(settings ClusterSettings, tfConfigPath string, purgeState bool, stateBackupDir string) error {
+	tfvarsPath, _, err := ValidateTFVars(tfConfigPath)
+	if err != nil {
+		return err
+	}
+	varArgs, err := prepareTfVars(tfvarsPath, settings)
+	if err != nil {
+		return err
+	}
+
+	if err := InitTerraform(tfvarsPath, settings.Project, settings.Environment); err != nil {
+		return err
+	}
+	reportStateLock(BackendFor(settings.Project, settings.Environment))
 	log.Println("Waiting 5 seconds before destroying cluster...")
 	time.Sleep(5 * time.Second)
-	argsArray := util.StringTemplateToArgsArray("%s %s %s", "destroy", "-var-file="+tfvarsFile, "-auto-approve")
+	argsArray := append([]string{"destroy", "-auto-approve"}, varArgs...)
 	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tfvarsPath)
-	cmd.Run()
+	if _, err := cmd.RunWithError(); err != nil {
+		return fmt.Errorf("error destroying cluster: %v", err)
+	}
 	log.Println("Kubernetes cluster deleted through Terraform")
-	//TODO: Need to have option (flag) to be able to delete bucket after cluster is destroyed
-	//Code below is ready but GCS cannot remove not empty buckets
-	//err := gcp.DeleteGCSBucket(project, env)
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
+
+	backend := BackendFor(settings.Project, settings.Environment)
+	storage := gcp.NewDefaultObjectStorageCache()
+	if !purgeState {
+		return reportStateLeftBehind(storage, backend)
+	}
+
+	if _, err := BackupState(storage, backend, stateBackupDir); err != nil {
+		return fmt.Errorf("state bucket not deleted, backup failed: %v", err)
+	}
+	return gcp.DeleteGCSBucket(settings.Project, settings.Environment)
+}
+
+func reportStateLeftBehind(storage gcp.ObjectStorageCache, backend TerraformBackend) error {
+	keys, err := StateObjects(storage, backend)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("State bucket %s was left behind with %d objects:\n", backend.Bucket, len(keys))
+	for _, key := range keys {
+		fmt.Printf("  %s\n", key)
+	}
+	fmt.Println("Use --purge-state to back it up and delete it")
+	return nil
+}
+
+func prepareTfVars(tfvarsPath string, settings ClusterSettings) ([]string, error) {
+	varArgs, err := settings.VarArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeTfVars(tfvarsPath, settings); err != nil {
+		return nil, err
+	}
+	return varArgs, nil
 }
 
 // --- Aux functions ---
 
-func ValidateTFVars(tfPath string) (string, string) {
-	if _, err := os.Stat(tfPath); os.IsNotExist(err) {
+// ValidateTFVars the terraform config dir and its variables file: 'tfPath' when it exists, otherwise
+// the default cluster module embedded in the binary, extracted to the myiac cache
+func ValidateTFVars(tfPath string) (string, string, error) {
+	if _, err := os.Stat(tfPath); tfPath == "" || os.IsNotExist(err) {
 		log.Println("Running Terraform against default configuration")
-		tfvarsPath := util.CurrentExecutableDir() + "/internal/terraform/cluster"
+		tfvarsPath, err := terraform.ExtractClusterModule(getCachePath())
+		if err != nil {
+			return "", "", fmt.Errorf("error extracting default terraform configuration: %v", err)
+		}
 		tfvarsFile := tfvarsPath + "/terraform.tfvars"
-		return tfvarsPath, tfvarsFile
+		return tfvarsPath, tfvarsFile, nil
 	} else {
 		log.Printf("Runnig Terraform with %v configuration", tfPath)
 		tfvarsPath := tfPath
 		tfvarsFile := tfvarsPath + "/terraform.tfvars"
-		return tfvarsPath, tfvarsFile
+		return tfvarsPath, tfvarsFile, nil
+	}
+}
+
+func getCachePath() string {
+	cachePath := os.Getenv("MYIAC_CACHE_PATH")
+	if cachePath != "" {
+		return cachePath
 	}
+	return util.GetHomeDir() + "/.myiac/cache"
 }
@@ -1,17 +1,36 @@
 package cluster
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/iac-io/myiac/internal/util"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestValidateTFVars(t *testing.T) {
-	got, got1 := ValidateTFVars("/home/app/terraform")
-	if got != util.CurrentExecutableDir()+"/internal/terraform/cluster" {
-		t.Errorf("ValidateTFVars() got = %v, want %v", got, util.CurrentExecutableDir()+"/internal/terraform/cluster")
-	}
-	if got1 != util.CurrentExecutableDir()+"/internal/terraform/cluster/terraform.tfvars" {
-		t.Errorf("ValidateTFVars() got1 = %v, want %v", got1, util.CurrentExecutableDir()+"/internal/terraform/cluster")
-	}
+	cachePath, _ := ioutil.TempDir("", "cache")
+	defer os.RemoveAll(cachePath)
+	_ = os.Setenv("MYIAC_CACHE_PATH", cachePath)
+	defer os.Unsetenv("MYIAC_CACHE_PATH")
+
+	got, got1, err := ValidateTFVars("/home/app/terraform")
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(got, filepath.Join(cachePath, "terraform", "cluster")))
+	assert.Equal(t, got+"/terraform.tfvars", got1)
+	assert.FileExists(t, filepath.Join(got, "cluster.tf"))
+}
+
+func TestValidateTFVarsWithConfigPath(t *testing.T) {
+	tfPath, _ := ioutil.TempDir("", "tfconfig")
+	defer os.RemoveAll(tfPath)
+
+	got, got1, err := ValidateTFVars(tfPath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, tfPath, got)
+	assert.Equal(t, tfPath+"/terraform.tfvars", got1)
 }
@@ -0,0 +1,201 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+)
+
+// driftPlanFile kept apart from the plan createCluster applies
+const driftPlanFile = "myiac-drift.tfplan"
+
+// exit code of 'terraform plan -detailed-exitcode' when the plan has changes
+const planExitCodeChanges = 2
+
+// NodePoolDrift a setting of a live node pool that differs from the expected one
+type NodePoolDrift struct {
+	Pool     string `json:"pool"`
+	Setting  string `json:"setting"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (npd NodePoolDrift) String() string {
+	return fmt.Sprintf("node pool %s: %s is %s, expected %s", npd.Pool, npd.Setting, npd.Actual, npd.Expected)
+}
+
+// DriftedResource a resource terraform would change to match the config
+type DriftedResource struct {
+	Address string `json:"address"`
+	Action  string `json:"action"`
+}
+
+// DriftReport the differences between the config of an environment and what is running
+type DriftReport struct {
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	Cluster     string            `json:"cluster"`
+	Resources   []DriftedResource `json:"resources"`
+	NodePools   []NodePoolDrift   `json:"nodePools"`
+}
+
+// HasDrift if anything differs
+func (dr *DriftReport) HasDrift() bool {
+	return len(dr.Resources) > 0 || len(dr.NodePools) > 0
+}
+
+// DetectDrift plans the terraform config of the environment against its current state, without
+// taking the state lock, and compares its live node pools with the cluster settings
+func DetectDrift(settings ClusterSettings, tfConfigPath string) (*DriftReport, error) {
+	tfvarsPath, _, err := ValidateTFVars(tfConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	varArgs, err := prepareTfVars(tfvarsPath, settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := EnsureBackend(tfvarsPath, BackendFor(settings.Project, settings.Environment)); err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{Project: settings.Project, Environment: settings.Environment}
+	cmdRunner := commandline.NewWithWorkingDir("terraform", nil, tfvarsPath)
+	if report.Resources, err = terraformDrift(cmdRunner, varArgs); err != nil {
+		return nil, err
+	}
+
+	outputs, err := ReadClusterOutputs(tfvarsPath)
+	if err != nil {
+		// nothing applied yet, the whole cluster shows up as drifted resources
+		return report, nil
+	}
+	report.Cluster = outputs.ClusterName
+
+	gcloudRunner := commandline.NewEmpty()
+	location := gcp.Location(outputs.ClusterLocation)
+	livePools, err := gcp.ListNodePools(gcloudRunner, settings.Project, outputs.ClusterName, location)
+	if err != nil {
+		return nil, err
+	}
+	liveSizes := make(map[string]int)
+	for _, pool := range livePools {
+		size, err := gcp.NodePoolSize(gcloudRunner, settings.Project, outputs.ClusterName, location, pool.Name)
+		if err != nil {
+			return nil, err
+		}
+		liveSizes[pool.Name] = size
+	}
+	report.NodePools = CompareNodePools(settings.Cluster, outputs.NodePools, livePools, liveSizes)
+	return report, nil
+}
+
+// terraformDrift the resources a refreshed plan would change. -detailed-exitcode exits with 0 when
+// there are no changes, 2 when there are and 1 on errors
+func terraformDrift(cmdRunner commandline.CommandRunner, varArgs []string) ([]DriftedResource, error) {
+	argsArray := append([]string{"plan", "-detailed-exitcode", "-lock=false", "-input=false",
+		"-out=" + driftPlanFile}, varArgs...)
+	cmdRunner.Setup("terraform", argsArray)
+	_, err := cmdRunner.RunWithError()
+	if err == nil {
+		return nil, nil
+	}
+
+	var cmdErr *commandline.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != planExitCodeChanges {
+		return nil, fmt.Errorf("error planning terraform: %v", err)
+	}
+
+	cmdRunner.Setup("terraform", []string{"show", "-json", driftPlanFile})
+	cmdRunner.SetSuppressOutput(true)
+	output, err := cmdRunner.RunWithError()
+	cmdRunner.SetSuppressOutput(false)
+	if err != nil {
+		return nil, fmt.Errorf("error reading terraform plan: %v", err)
+	}
+
+	summary, err := ParsePlanSummary([]byte(output.Output))
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []DriftedResource
+	for _, change := range summary.Changes {
+		drifted = append(drifted, DriftedResource{Address: change.Address, Action: change.Action})
+	}
+	return drifted, nil
+}
+
+// CompareNodePools the differences between the live node pools and the expected ones: the pools in
+// the terraform outputs, sized after the cluster settings. Settings left unset aren't compared.
+//
+// 'liveSizes' the nodes per zone of each live pool. They drift when out of the node count of the settings,
+// or of the range up to the max node count when the pool is autoscaled
+func CompareNodePools(expected manifest.Cluster, expectedPools []string, livePools []gcp.NodePool,
+	liveSizes map[string]int) []NodePoolDrift {
+	var drifts []NodePoolDrift
+	live := make(map[string]gcp.NodePool)
+	for _, pool := range livePools {
+		live[pool.Name] = pool
+	}
+
+	for _, poolName := range expectedPools {
+		pool, found := live[poolName]
+		if !found {
+			drifts = append(drifts, NodePoolDrift{Pool: poolName, Setting: "existence", Expected: "present", Actual: "missing"})
+			continue
+		}
+		delete(live, poolName)
+
+		addDrift := func(setting string, expectedValue string, actualValue string) {
+			if expectedValue != actualValue {
+				drifts = append(drifts, NodePoolDrift{Pool: poolName, Setting: setting, Expected: expectedValue, Actual: actualValue})
+			}
+		}
+		if expected.MachineType != "" {
+			addDrift("machineType", expected.MachineType, pool.Config.MachineType)
+		}
+		if expected.NodeCount != 0 {
+			addDrift("minNodeCount", strconv.Itoa(expected.NodeCount), strconv.Itoa(pool.Autoscaling.MinNodeCount))
+		}
+		if expected.MaxNodeCount != 0 {
+			addDrift("maxNodeCount", strconv.Itoa(expected.MaxNodeCount), strconv.Itoa(pool.Autoscaling.MaxNodeCount))
+		}
+		if expected.Preemptible != nil {
+			addDrift("preemptible", strconv.FormatBool(*expected.Preemptible), strconv.FormatBool(pool.Config.Preemptible))
+		}
+		if size, known := liveSizes[poolName]; known && expected.NodeCount != 0 {
+			if nodeCount, inRange := expectedNodeCount(expected, pool, size); !inRange {
+				drifts = append(drifts, NodePoolDrift{Pool: poolName, Setting: "nodeCount", Expected: nodeCount,
+					Actual: strconv.Itoa(size)})
+			}
+		}
+	}
+
+	for _, pool := range livePools {
+		if _, unexpected := live[pool.Name]; unexpected {
+			drifts = append(drifts, NodePoolDrift{Pool: pool.Name, Setting: "existence", Expected: "absent", Actual: "present"})
+		}
+	}
+	return drifts
+}
+
+// expectedNodeCount the node count the pool should have as text, and if 'size' is within it. Autoscaled
+// pools can grow up to their max node count, the expected one or else the live one
+func expectedNodeCount(expected manifest.Cluster, pool gcp.NodePool, size int) (string, bool) {
+	maxNodes := expected.NodeCount
+	if pool.Autoscaling.Enabled {
+		maxNodes = pool.Autoscaling.MaxNodeCount
+		if expected.MaxNodeCount != 0 {
+			maxNodes = expected.MaxNodeCount
+		}
+	}
+	if maxNodes <= expected.NodeCount {
+		return strconv.Itoa(expected.NodeCount), size == expected.NodeCount
+	}
+	return fmt.Sprintf("%d-%d", expected.NodeCount, maxNodes), size >= expected.NodeCount && size <= maxNodes
+}
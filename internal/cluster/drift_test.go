@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const driftPlanCmdLine = "terraform plan -detailed-exitcode -lock=false -input=false -out=myiac-drift.tfplan"
+
+func TestNoDriftWhenPlanHasNoChanges(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+
+	drifted, err := terraformDrift(runner, nil)
+
+	assert.Nil(t, err)
+	assert.Empty(t, drifted)
+	assert.Equal(t, []string{driftPlanCmdLine}, runner.GetCmdLines())
+}
+
+func TestDriftedResourcesAreReadFromPlan(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommandError(driftPlanCmdLine+" -var k8s_node_pool_version=1.18", 2)
+	runner.FakeCommand("terraform show -json myiac-drift.tfplan", planJson)
+
+	drifted, err := terraformDrift(runner, []string{"-var", "k8s_node_pool_version=1.18"})
+
+	assert.Nil(t, err)
+	assert.Len(t, drifted, 4)
+	assert.Equal(t, DriftedResource{Address: "google_container_cluster.cluster", Action: ActionUpdate}, drifted[0])
+}
+
+func TestPlanErrorIsNotDrift(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommandError(driftPlanCmdLine, 1)
+
+	_, err := terraformDrift(runner, nil)
+
+	assert.NotNil(t, err)
+}
+
+func TestNodePoolsAreComparedWithSettings(t *testing.T) {
+	preemptible := true
+	expected := manifest.Cluster{MachineType: "n1-standard-2", NodeCount: 1, MaxNodeCount: 3, Preemptible: &preemptible}
+	pool := gcp.NodePool{Name: "default-pool"}
+	pool.Config.MachineType = "n1-standard-2"
+	pool.Config.Preemptible = true
+	pool.Autoscaling.Enabled = true
+	pool.Autoscaling.MinNodeCount = 1
+	pool.Autoscaling.MaxNodeCount = 5
+	manualPool := gcp.NodePool{Name: "manual-pool"}
+
+	drifts := CompareNodePools(expected, []string{"default-pool", "batch-pool"}, []gcp.NodePool{pool, manualPool},
+		map[string]int{"default-pool": 2, "manual-pool": 1})
+
+	assert.Equal(t, []NodePoolDrift{
+		{Pool: "default-pool", Setting: "maxNodeCount", Expected: "3", Actual: "5"},
+		{Pool: "batch-pool", Setting: "existence", Expected: "present", Actual: "missing"},
+		{Pool: "manual-pool", Setting: "existence", Expected: "absent", Actual: "present"},
+	}, drifts)
+}
+
+func TestUnsetSettingsAreNotCompared(t *testing.T) {
+	pool := gcp.NodePool{Name: "default-pool"}
+	pool.Config.MachineType = "e2-small"
+
+	drifts := CompareNodePools(manifest.Cluster{}, []string{"default-pool"}, []gcp.NodePool{pool},
+		map[string]int{"default-pool": 7})
+
+	assert.Empty(t, drifts)
+}
+
+func TestNodeCountIsComparedWithSettings(t *testing.T) {
+	// the max node count isn't set, autoscaled pools can grow up to their live one
+	expected := manifest.Cluster{NodeCount: 2}
+	fixedPool := gcp.NodePool{Name: "fixed-pool"}
+	fixedPool.Autoscaling.MinNodeCount = 2
+	autoscaledPool := gcp.NodePool{Name: "autoscaled-pool"}
+	autoscaledPool.Autoscaling.Enabled = true
+	autoscaledPool.Autoscaling.MinNodeCount = 2
+	autoscaledPool.Autoscaling.MaxNodeCount = 4
+	pools := []gcp.NodePool{fixedPool, autoscaledPool}
+	poolNames := []string{"fixed-pool", "autoscaled-pool"}
+
+	assert.Empty(t, CompareNodePools(expected, poolNames, pools, map[string]int{"fixed-pool": 2, "autoscaled-pool": 4}))
+
+	drifts := CompareNodePools(expected, poolNames, pools, map[string]int{"fixed-pool": 3, "autoscaled-pool": 1})
+
+	assert.Equal(t, []NodePoolDrift{
+		{Pool: "fixed-pool", Setting: "nodeCount", Expected: "2", Actual: "3"},
+		{Pool: "autoscaled-pool", Setting: "nodeCount", Expected: "2-4", Actual: "1"},
+	}, drifts)
+}
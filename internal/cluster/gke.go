@@ -1,11 +1,13 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
-	"github.com/iac-io/myiac/internal/commandline"
 	"github.com/iac-io/myiac/internal/deploy"
 	"github.com/iac-io/myiac/internal/gcp"
-	"github.com/iac-io/myiac/internal/util"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"log"
 	"strings"
 )
@@ -21,17 +23,19 @@ type GkeClusterActions interface {
 type gkeClusterService struct {
 	deployer   deploy.Deployer
 	dnsService gcp.DNSService
+	kubeClient *kubernetes.Client
 	gcpProject string
 	environ    string
 	domainName string
 }
 
 //TODO: group domainName, gcpProject, env in one object
-func NewGkeClusterService(deployer deploy.Deployer, dnsService gcp.DNSService, domainName string,
-	gcpProject string, env string) GkeClusterActions {
+func NewGkeClusterService(deployer deploy.Deployer, dnsService gcp.DNSService, kubeClient *kubernetes.Client,
+	domainName string, gcpProject string, env string) GkeClusterActions {
 	return &gkeClusterService{
 		deployer:   deployer,
 		dnsService: dnsService,
+		kubeClient: kubeClient,
 		gcpProject: gcpProject,
 		environ:    env,
 		domainName: domainName,
@@ -52,7 +56,10 @@ func NewGkeClusterService(deployer deploy.Deployer, dnsService gcp.DNSService, d
 func (gcs gkeClusterService) UpdateDnsFromClusterIps(subdomains []string) error {
 
 	log.Printf("setting up service to expose single-public IP for this cluster...")
-	ip := FindIngressControllerNode()
+	ip, err := FindIngressControllerNode(context.Background(), gcs.kubeClient)
+	if err != nil {
+		return fmt.Errorf("error finding the ingress controller node: %v", err)
+	}
 
 	log.Printf("Ingress Controller Node IP is %s", ip)
 
@@ -65,7 +72,7 @@ func (gcs gkeClusterService) UpdateDnsFromClusterIps(subdomains []string) error
 	}
 
 	log.Printf("Updating dns entries %v to IP %s", subdomainsToUpdate, ip)
-	err := gcs.dnsService.UpsertDNSEntries(subdomains, ip)
+	err = gcs.dnsService.UpsertDNSEntries(subdomains, ip)
 
 	if err != nil {
 		return fmt.Errorf("error upserting dns entries for %s: %s", subdomainsToUpdate, err)
@@ -75,31 +82,28 @@ func (gcs gkeClusterService) UpdateDnsFromClusterIps(subdomains []string) error
 	return nil
 }
 
-func FindIngressControllerNode() string {
-	cmd11 := commandline.New("kubectl",
-		[]string{"get", "pods", "-l", "app=traefik", "--template",
-		"'{{range .items}}{{.metadata.name}}{{end}}'", "--field-selector", "status.phase=Running"})
-	output1 := cmd11.Run()
-	podName := strings.TrimSpace(output1.Output)
-	podName = strings.ReplaceAll(podName, "'","")
-	cmd2 := fmt.Sprintf("kubectl get pod %s --template '{{.spec.nodeName}}'", podName)
-	cmd22 := commandline.NewCommandLine(cmd2)
-	output2 := cmd22.Run()
-	nodeName := strings.TrimSpace(output2.Output)
-	nodeName = strings.ReplaceAll(nodeName, "'", "")
-	cmd3 := fmt.Sprintf("kubectl get node %s -o json", nodeName)
-	cmd33 := commandline.NewCommandLine(cmd3)
-	output3 := cmd33.Run()
-	nodeJson := util.Parse(output3.Output)
-	return findNodeIp(nodeJson)
-}
+// FindIngressControllerNode the IP of the node running the ingress controller (traefik)
+func FindIngressControllerNode(ctx context.Context, kubeClient *kubernetes.Client) (string, error) {
+	pods, err := kubeClient.RunningPods(ctx, metav1.NamespaceAll, "app=traefik")
+	if err != nil {
+		return "", err
+	}
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no running ingress controller (app=traefik) pods found")
+	}
 
-func findNodeIp(nodeJson map[string]interface{}) string {
-	indexOfAddress := 1
-	status := util.GetJsonObject(nodeJson, "status")
-	addresses := util.GetJsonArray(status, "addresses")
+	node, err := kubeClient.GetNode(ctx, pods[0].Spec.NodeName)
+	if err != nil {
+		return "", err
+	}
+	return findNodeIp(*node)
+}
 
-	//TODO: find by type Internal/External
-	ip := util.GetStringValue(addresses[indexOfAddress], "address")
-	return ip
+// findNodeIp the external IPv4 address of the node, the one the DNS entries point to
+func findNodeIp(node corev1.Node) (string, error) {
+	addresses := kubernetes.AddressesOf(node, corev1.NodeExternalIP, kubernetes.FamilyIPv4)
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("node %s has no external IPv4 address", node.Name)
+	}
+	return addresses[0].Address, nil
 }
@@ -1,10 +1,14 @@
 package cluster
 
 import (
-	"fmt"
+	"context"
 	"github.com/iac-io/myiac/internal/deploy"
+	"github.com/iac-io/myiac/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	"testing"
 )
 
@@ -13,8 +17,9 @@ type fakeDeployer struct {
 	mock.Mock
 }
 
-func (fd *fakeDeployer) Deploy(appName string, environment string, propertiesMap map[string]string, dryRun bool) {
-	fd.Called(appName, environment, propertiesMap, dryRun)
+func (fd *fakeDeployer) Deploy(ctx context.Context, request deploy.DeploymentRequest) (deploy.DeployResult, error) {
+	args := fd.Called(ctx, request)
+	return args.Get(0).(deploy.DeployResult), args.Error(1)
 }
 
 type fakeDnsService struct {
@@ -31,26 +36,48 @@ func (fds *fakeDnsService) UpsertDNSEntries(dnsEntries []string, ipAddress strin
 	return args.Error(0)
 }
 
+func traefikCluster() *kubernetes.Client {
+	return kubernetes.NewClientFromClientset(fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "traefik-7d9f", Namespace: "default", Labels: map[string]string{"app": "traefik"}},
+			Spec:       corev1.PodSpec{NodeName: "gke-moneycol-dev-pool-1"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gke-moneycol-dev-pool-1"},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.154.0.2"},
+				{Type: corev1.NodeExternalIP, Address: "35.195.98.142"},
+			}},
+		},
+	))
+}
+
 func TestGetAllPublicIPs(t *testing.T) {
-	t.Skip("This test calls real kubectl commands")
 	subdomains := []string{"collections"}
 	ip := "35.195.98.142"
-	deployer := deploy.NewDeployer()
+	deployer := new(fakeDeployer)
 	dnsService := new(fakeDnsService)
-	dnsService.On("UpsertDNSEntry").Return(nil)
 	dnsService.On("UpsertDNSEntries", subdomains, ip).Return(nil)
 
-	gke := NewGkeClusterService(deployer, dnsService, "moneycol.net", "moneycol", "dev")
+	gke := NewGkeClusterService(deployer, dnsService, traefikCluster(), "moneycol.net", "moneycol", "dev")
 
 	err := gke.UpdateDnsFromClusterIps(subdomains)
 
 	assert.Nil(t, err)
+	dnsService.AssertExpectations(t)
 }
 
 // go test -run TestFindIngressIp ./...
 func TestFindIngressIp(t *testing.T) {
-	t.Skip("This test calls real kubectl commands")
-	ip := FindIngressControllerNode()
-	fmt.Printf("IP %s",ip)
-	assert.NotNil(t, ip)
-}
\ No newline at end of file
+	ip, err := FindIngressControllerNode(context.Background(), traefikCluster())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "35.195.98.142", ip)
+}
+
+func TestFindIngressIpWithoutIngressController(t *testing.T) {
+	_, err := FindIngressControllerNode(context.Background(), kubernetes.NewClientFromClientset(fake.NewSimpleClientset()))
+
+	assert.NotNil(t, err)
+}
@@ -1,53 +1,46 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
-	"github.com/iac-io/myiac/internal/commandline"
-	"github.com/iac-io/myiac/internal/util"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const externalIpsKeyName = "externalIps"
 
-func GetInternalIpsForNodes() []string {
-	json := executeGetIpsCmd()
-	ips := getAllIps(json, true)
+// GetInternalIpsForNodes the internal IPv4 addresses of the nodes that are Ready and not cordoned
+func GetInternalIpsForNodes(ctx context.Context, kubeClient *kubernetes.Client) ([]string, error) {
+	ips, err := nodeIps(ctx, kubeClient, corev1.NodeInternalIP)
+	if err != nil {
+		return nil, err
+	}
 	fmt.Printf("Internal IPs for nodes in cluster are: %v\n", ips)
-	return ips
+	return ips, nil
 }
 
-func GetAllPublicIps() []string {
-	json := executeGetIpsCmd()
-	ips := getAllIps(json, false)
+// GetAllPublicIps the external IPv4 addresses of the nodes that are Ready and not cordoned
+func GetAllPublicIps(ctx context.Context, kubeClient *kubernetes.Client) ([]string, error) {
+	ips, err := nodeIps(ctx, kubeClient, corev1.NodeExternalIP)
+	if err != nil {
+		return nil, err
+	}
 	fmt.Printf("Public IPs for nodes in cluster are: %v\n", ips)
-	return ips
-}
-
-func executeGetIpsCmd() map[string]interface{} {
-	argsArray := []string{"get", "nodes", "-o", "json"}
-	cmd := commandline.New("kubectl", argsArray)
-	cmd.SetSuppressOutput(true)
-	cmdResult := cmd.Run()
-	cmdOutput := cmdResult.Output
-	json := util.Parse(cmdOutput)
-	return json
+	return ips, nil
 }
 
-func getAllIps(json map[string]interface{}, internal bool) []string {
-	indexOfAddress := 1
-	if internal {
-		indexOfAddress = 0
+func nodeIps(ctx context.Context, kubeClient *kubernetes.Client, addressType corev1.NodeAddressType) ([]string, error) {
+	addresses, err := kubeClient.NodeAddresses(ctx, addressType, kubernetes.FamilyIPv4)
+	if err != nil {
+		return nil, err
 	}
-	allNodes := util.GetJsonArray(json, "items")
 	var ips []string
-	for _, node := range allNodes {
-		status := util.GetJsonObject(node, "status")
-		addresses := util.GetJsonArray(status, "addresses")
-		ip := util.GetStringValue(addresses[indexOfAddress], "address")
-		ips = append(ips, ip)
+	for _, address := range addresses {
+		ips = append(ips, address.Address)
 	}
-	return ips
+	return ips, nil
 }
 
 func getNodesInternalIpsAsHelmParams(internalIps []string) map[string]string {
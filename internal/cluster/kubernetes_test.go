@@ -1,9 +1,14 @@
 package cluster
 
 import (
+	"context"
 	"testing"
 
+	"github.com/iac-io/myiac/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestGetIpsAsHelmParams(t *testing.T) {
@@ -23,3 +28,33 @@ func mapHasKey(aMap map[string]string, key string) bool {
 	}
 	return false
 }
+
+func TestGetAllIpsOfReadyNodes(t *testing.T) {
+	ready := []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+	kubeClient := kubernetes.NewClientFromClientset(fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gke-moneycol-dev-pool-1"},
+			Status: corev1.NodeStatus{Conditions: ready, Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "gke-moneycol-dev-pool-1"},
+				{Type: corev1.NodeInternalIP, Address: "10.154.0.2"},
+				{Type: corev1.NodeExternalIP, Address: "35.195.98.142"},
+			}},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gke-moneycol-dev-pool-2"},
+			Spec:       corev1.NodeSpec{Unschedulable: true},
+			Status: corev1.NodeStatus{Conditions: ready, Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.154.0.3"},
+				{Type: corev1.NodeExternalIP, Address: "35.195.98.143"},
+			}},
+		},
+	))
+
+	internalIps, err := GetInternalIpsForNodes(context.Background(), kubeClient)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.154.0.2"}, internalIps)
+
+	publicIps, err := GetAllPublicIps(context.Background(), kubeClient)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"35.195.98.142"}, publicIps)
+}
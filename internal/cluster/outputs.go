@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/preferences"
+)
+
+const (
+	prefsClusterProject  = "gke.project"
+	prefsClusterEnv      = "gke.environment"
+	prefsClusterName     = "gke.clusterName"
+	prefsClusterLocation = "gke.clusterZone" // named before regional clusters, it holds the zone or region
+	prefsClusterEndpoint = "gke.clusterEndpoint"
+	prefsNodePools       = "gke.nodePools"
+)
+
+// ClusterOutputs the outputs of the cluster terraform config, the real names of what was created
+type ClusterOutputs struct {
+	ClusterName     string
+	ClusterLocation string // the zone, or the region of a regional cluster
+	ClusterEndpoint string
+	NodePools       []string
+}
+
+type terraformOutput struct {
+	Value interface{} `json:"value"`
+}
+
+// ReadClusterOutputs runs 'terraform output -json' in the terraform config dir
+func ReadClusterOutputs(tfConfigPath string) (*ClusterOutputs, error) {
+	cmd := commandline.NewWithWorkingDir("terraform", []string{"output", "-json"}, tfConfigPath)
+	cmd.SetSuppressOutput(true)
+	output, err := cmd.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error reading terraform outputs: %v", err)
+	}
+	return ParseClusterOutputs(output.Output)
+}
+
+// ParseClusterOutputs reads the cluster outputs from the 'terraform output -json' format
+func ParseClusterOutputs(outputJson string) (*ClusterOutputs, error) {
+	var outputs map[string]terraformOutput
+	if err := json.Unmarshal([]byte(outputJson), &outputs); err != nil {
+		return nil, fmt.Errorf("error parsing terraform outputs: %v", err)
+	}
+
+	stringOutput := func(name string) string {
+		value, _ := outputs[name].Value.(string)
+		return value
+	}
+
+	clusterOutputs := &ClusterOutputs{
+		ClusterName:     stringOutput("cluster_name"),
+		ClusterLocation: stringOutput("cluster_zone"),
+		ClusterEndpoint: stringOutput("cluster_endpoint"),
+	}
+	if nodePools, ok := outputs["node_pools"].Value.([]interface{}); ok {
+		for _, nodePool := range nodePools {
+			clusterOutputs.NodePools = append(clusterOutputs.NodePools, fmt.Sprintf("%v", nodePool))
+		}
+	}
+
+	if clusterOutputs.ClusterName == "" {
+		return nil, fmt.Errorf("terraform outputs don't include the cluster name")
+	}
+	return clusterOutputs, nil
+}
+
+// SaveClusterOutputs stores the outputs as the active cluster context of 'project' and 'env'
+func SaveClusterOutputs(prefs preferences.Preferences, project string, env string, outputs *ClusterOutputs) {
+	prefs.SetMultiple(map[string]string{
+		prefsClusterProject:  project,
+		prefsClusterEnv:      env,
+		prefsClusterName:     outputs.ClusterName,
+		prefsClusterLocation: outputs.ClusterLocation,
+		prefsClusterEndpoint: outputs.ClusterEndpoint,
+		prefsNodePools:       strings.Join(outputs.NodePools, ","),
+	})
+	log.Printf("Saved cluster %s (%s) as active context of %s/%s\n", outputs.ClusterName, outputs.ClusterLocation,
+		project, env)
+}
+
+// SavedClusterOutputs the outputs saved for 'project' and 'env', nil when the active context
+// is a different cluster (or there's none)
+func SavedClusterOutputs(prefs preferences.Preferences, project string, env string) *ClusterOutputs {
+	if prefs.Get(prefsClusterProject) != project || prefs.Get(prefsClusterEnv) != env ||
+		prefs.Get(prefsClusterName) == "" {
+		return nil
+	}
+
+	outputs := &ClusterOutputs{
+		ClusterName:     prefs.Get(prefsClusterName),
+		ClusterLocation: prefs.Get(prefsClusterLocation),
+		ClusterEndpoint: prefs.Get(prefsClusterEndpoint),
+	}
+	if nodePools := prefs.Get(prefsNodePools); nodePools != "" {
+		outputs.NodePools = strings.Split(nodePools, ",")
+	}
+	return outputs
+}
+
+// ActiveClusterProjectEnv the project and environment of the active cluster context
+func ActiveClusterProjectEnv(prefs preferences.Preferences) (string, string) {
+	return prefs.Get(prefsClusterProject), prefs.Get(prefsClusterEnv)
+}
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/preferences"
+	"github.com/stretchr/testify/assert"
+)
+
+const terraformOutputJson = `{
+  "cluster_endpoint": {"sensitive": false, "type": "string", "value": "35.197.220.11"},
+  "cluster_name": {"sensitive": false, "type": "string", "value": "blue-moneycol-dev"},
+  "cluster_zone": {"sensitive": false, "type": "string", "value": "europe-west2-b"},
+  "node_pools": {"sensitive": false, "type": ["tuple", ["string"]], "value": ["default-pool"]}
+}`
+
+func testPreferences(t *testing.T) preferences.Preferences {
+	dir, err := ioutil.TempDir("", "prefs")
+	if err != nil {
+		t.Fatalf("error creating prefs dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return preferences.NewConfig(filepath.Join(dir, "prefs"))
+}
+
+func TestParsesClusterOutputs(t *testing.T) {
+	outputs, err := ParseClusterOutputs(terraformOutputJson)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &ClusterOutputs{
+		ClusterName:     "blue-moneycol-dev",
+		ClusterLocation: "europe-west2-b",
+		ClusterEndpoint: "35.197.220.11",
+		NodePools:       []string{"default-pool"},
+	}, outputs)
+}
+
+func TestOutputsWithoutClusterNameAreRejected(t *testing.T) {
+	_, err := ParseClusterOutputs(`{}`)
+
+	assert.NotNil(t, err)
+}
+
+func TestSavedOutputsOnlyApplyToTheirEnvironment(t *testing.T) {
+	prefs := testPreferences(t)
+	outputs, _ := ParseClusterOutputs(terraformOutputJson)
+
+	SaveClusterOutputs(prefs, "moneycol", "dev", outputs)
+
+	assert.Equal(t, outputs, SavedClusterOutputs(prefs, "moneycol", "dev"))
+	assert.Nil(t, SavedClusterOutputs(prefs, "moneycol", "prod"))
+}
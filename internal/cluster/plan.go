@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+)
+
+// planFile the plan written by 'terraform plan -out' in the config dir, the exact one that gets applied
+const planFile = "myiac.tfplan"
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionReplace = "replace"
+)
+
+// ErrPlanNotApproved the plan was reviewed and not approved, nothing was applied
+var ErrPlanNotApproved = errors.New("terraform plan not approved")
+
+// PlanApprover decides if a plan gets applied after reviewing its summary
+type PlanApprover func(summary *PlanSummary) bool
+
+// ResourceChange a change to a resource in a plan
+type ResourceChange struct {
+	Address string
+	Action  string
+}
+
+// IsDestructive a delete or a replace, the resource (and whatever runs on it) goes away
+func (rc ResourceChange) IsDestructive() bool {
+	return rc.Action == ActionDelete || rc.Action == ActionReplace
+}
+
+func (rc ResourceChange) String() string {
+	symbols := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-", ActionReplace: "-/+"}
+	change := fmt.Sprintf("%3s %s (%s)", symbols[rc.Action], rc.Address, rc.Action)
+	if rc.IsDestructive() {
+		change = "!! " + strings.TrimSpace(change) + " !!"
+	}
+	return change
+}
+
+// PlanSummary the resource changes of a plan, read from 'terraform show -json'
+type PlanSummary struct {
+	Changes []ResourceChange
+}
+
+// Count the number of changes with an action
+func (ps *PlanSummary) Count(action string) int {
+	count := 0
+	for _, change := range ps.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// HasChanges if applying the plan changes anything at all
+func (ps *PlanSummary) HasChanges() bool {
+	return len(ps.Changes) > 0
+}
+
+// HasDestructiveChanges if any resource is deleted or replaced
+func (ps *PlanSummary) HasDestructiveChanges() bool {
+	for _, change := range ps.Changes {
+		if change.IsDestructive() {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps *PlanSummary) String() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "Plan: %d to create, %d to update, %d to destroy, %d to replace\n",
+		ps.Count(ActionCreate), ps.Count(ActionUpdate), ps.Count(ActionDelete), ps.Count(ActionReplace))
+	for _, change := range ps.Changes {
+		_, _ = fmt.Fprintf(&sb, "%s\n", change)
+	}
+	if ps.HasDestructiveChanges() {
+		sb.WriteString("WARNING: this plan destroys or replaces resources\n")
+	}
+	return sb.String()
+}
+
+// ParsePlanSummary the summary of a plan in the 'terraform show -json' format. Resources without
+// changes (no-op) and data sources reads are left out
+func ParsePlanSummary(planJson []byte) (*PlanSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(planJson, &plan); err != nil {
+		return nil, fmt.Errorf("error parsing terraform plan: %v", err)
+	}
+
+	summary := &PlanSummary{}
+	for _, resourceChange := range plan.ResourceChanges {
+		action := planAction(resourceChange.Change.Actions)
+		if action == "" {
+			continue
+		}
+		summary.Changes = append(summary.Changes, ResourceChange{Address: resourceChange.Address, Action: action})
+	}
+	return summary, nil
+}
+
+// planAction the action of a change from its terraform actions: a replace is a delete and a create,
+// in either order depending on create_before_destroy
+func planAction(actions []string) string {
+	if len(actions) == 2 {
+		return ActionReplace
+	}
+	if len(actions) == 1 {
+		switch actions[0] {
+		case ActionCreate, ActionUpdate, ActionDelete:
+			return actions[0]
+		}
+	}
+	return ""
+}
+
+// PlanTerraform writes the plan of the config in 'tp' into a plan file, returning its summary.
+// terraform.tfvars isn't passed with -var-file: terraform loads it anyway, and explicit var files
+// would take precedence over the generated auto loaded variables
+func PlanTerraform(tp string, varArgs ...string) (*PlanSummary, error) {
+	argsArray := append([]string{"plan", "-input=false", "-out=" + planFile}, varArgs...)
+	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tp)
+	if _, err := cmd.RunWithError(); err != nil {
+		return nil, fmt.Errorf("error planning terraform: %v", err)
+	}
+	log.Printf("Terraform PLAN for %v written to %v", tp, filepath.Join(tp, planFile))
+
+	cmd = commandline.NewWithWorkingDir("terraform", []string{"show", "-json", planFile}, tp)
+	cmd.SetSuppressOutput(true)
+	output, err := cmd.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error reading terraform plan: %v", err)
+	}
+	return ParsePlanSummary([]byte(output.Output))
+}
+
+// ApplyTerraform applies the plan file written by PlanTerraform, and nothing else. A saved plan is
+// applied without prompting, and terraform rejects it if the state changed since it was written
+func ApplyTerraform(tp string) error {
+	argsArray := []string{"apply", "-input=false", planFile}
+	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tp)
+	if _, err := cmd.RunWithError(); err != nil {
+		return fmt.Errorf("error applying terraform plan: %v", err)
+	}
+	log.Printf("Terraform APPLY for %v finished", filepath.Join(tp, planFile))
+	return nil
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const planJson = `{
+  "format_version": "0.1",
+  "resource_changes": [
+    {"address": "google_compute_network.gke_network", "change": {"actions": ["no-op"]}},
+    {"address": "google_container_cluster.cluster", "change": {"actions": ["update"]}},
+    {"address": "google_container_node_pool.applications_node_pool", "change": {"actions": ["delete", "create"]}},
+    {"address": "google_compute_firewall.ingress", "change": {"actions": ["create"]}},
+    {"address": "google_compute_address.static", "change": {"actions": ["delete"]}},
+    {"address": "data.google_client_config.current", "change": {"actions": ["read"]}}
+  ]
+}`
+
+func TestPlanSummaryCountsChangesByAction(t *testing.T) {
+	summary, err := ParsePlanSummary([]byte(planJson))
+
+	assert.Nil(t, err)
+	assert.Len(t, summary.Changes, 4)
+	assert.Equal(t, 1, summary.Count(ActionCreate))
+	assert.Equal(t, 1, summary.Count(ActionUpdate))
+	assert.Equal(t, 1, summary.Count(ActionDelete))
+	assert.Equal(t, 1, summary.Count(ActionReplace))
+	assert.True(t, summary.HasChanges())
+	assert.True(t, summary.HasDestructiveChanges())
+}
+
+func TestPlanSummaryHighlightsDestructiveChanges(t *testing.T) {
+	summary, _ := ParsePlanSummary([]byte(planJson))
+
+	output := summary.String()
+
+	assert.True(t, strings.HasPrefix(output, "Plan: 1 to create, 1 to update, 1 to destroy, 1 to replace"))
+	assert.Contains(t, output, "!! -/+ google_container_node_pool.applications_node_pool (replace) !!")
+	assert.Contains(t, output, "!! - google_compute_address.static (delete) !!")
+	assert.Contains(t, output, "  + google_compute_firewall.ingress (create)")
+	assert.Contains(t, output, "WARNING")
+}
+
+func TestPlanWithoutChanges(t *testing.T) {
+	summary, err := ParsePlanSummary([]byte(`{"resource_changes": [{"address": "a.b", "change": {"actions": ["no-op"]}}]}`))
+
+	assert.Nil(t, err)
+	assert.False(t, summary.HasChanges())
+	assert.False(t, summary.HasDestructiveChanges())
+	assert.NotContains(t, summary.String(), "WARNING")
+}
@@ -3,9 +3,13 @@ package cluster
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/iac-io/myiac/internal/commandline"
 	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/kubeconfig"
+	"github.com/iac-io/myiac/internal/naming"
 	"github.com/iac-io/myiac/internal/preferences"
 )
 
@@ -14,54 +18,71 @@ const (
 )
 
 type Provider interface {
-	Setup()
-	ClusterSetup()
+	Setup() error
+	ClusterSetup() error
 }
 
-func ProviderSetup() {
+func ProviderSetup() error {
 	providerFactory := ProviderFactory{}
-	provider := providerFactory.getProvider()
-	provider.Setup()
-	provider.ClusterSetup()
+	provider, err := providerFactory.getProvider()
+	if err != nil {
+		return err
+	}
+	if err := provider.Setup(); err != nil {
+		return err
+	}
+	return provider.ClusterSetup()
 }
 
-func SetupProvider(providerValue string, zone string, clusterName string, project string,
-	keyLocation string, dryRunFlag bool) {
-	var provider Provider
-	if providerValue == gcpProviderName {
-		gkeCluster := GkeCluster{zone: zone, name: clusterName}
-		provider = NewGcpProvider(project, keyLocation, gkeCluster)
-	} else {
-		panic(fmt.Errorf("invalid provider provided: %v", providerValue))
+func SetupProvider(providerValue string, location string, clusterName string, project string, env string,
+	keyLocation string, dryRunFlag bool) error {
+	if providerValue != gcpProviderName {
+		return fmt.Errorf("invalid provider provided: %v", providerValue)
+	}
+	gkeCluster := GkeCluster{location: gcp.Location(location), name: clusterName, env: env}
+	provider, err := NewGcpProvider(project, keyLocation, gkeCluster)
+	if err != nil {
+		return err
+	}
+	if err := provider.Setup(); err != nil {
+		return err
 	}
-	provider.Setup()
 	if !dryRunFlag {
-		provider.ClusterSetup()
+		if err := provider.ClusterSetup(); err != nil {
+			return err
+		}
 	}
 
 	log.Printf("Set local kubectl to project: %v \n", project)
+	return nil
 }
 
+// GkeCluster a zonal or regional GKE cluster, the one of the environment 'env'
 type GkeCluster struct {
-	zone string
-	name string
+	location gcp.Location
+	name     string
+	env      string
 }
 
 type ProviderFactory struct {
 }
 
-func (pf ProviderFactory) getProvider() Provider {
+func (pf ProviderFactory) getProvider() (Provider, error) {
 	prefs := preferences.DefaultConfig()
 	provider := prefs.Get("provider")
 	if provider == gcpProviderName {
 		keyLocation := prefs.Get("keyLocation")
 		project := prefs.Get("project")
-		clusterName := prefs.Get("gke.clusterName")
-		clusterZone := prefs.Get("gke.clusterZone")
-		gkePrefs := GkeCluster{name: clusterName, zone: clusterZone}
-		return NewGcpProvider(project, keyLocation, gkePrefs)
+		clusterName := prefs.Get(prefsClusterName)
+		clusterLocation := prefs.Get(prefsClusterLocation)
+		gkePrefs := GkeCluster{name: clusterName, location: gcp.Location(clusterLocation), env: prefs.Get(prefsClusterEnv)}
+		gcpProvider, err := NewGcpProvider(project, keyLocation, gkePrefs)
+		if err != nil {
+			return nil, err
+		}
+		return gcpProvider, nil
 	} else {
-		panic(fmt.Sprintf("Cloud provider not present or supported supported: %s", provider))
+		return nil, fmt.Errorf("cloud provider not present or supported: %s", provider)
 	}
 }
 
@@ -73,16 +94,16 @@ type gcpProvider struct {
 	commandRunner      commandline.CommandRunner
 }
 
-func NewGcpProvider(projectId string, keyLocation string, gkeCluster GkeCluster) *gcpProvider {
+func NewGcpProvider(projectId string, keyLocation string, gkeCluster GkeCluster) (*gcpProvider, error) {
 	return newGcpProvider(commandline.NewEmpty(), projectId, keyLocation, gkeCluster)
 }
 
 func newGcpProvider(commandRunner commandline.CommandRunner, projectId string, keyLocation string,
-	gkeCluster GkeCluster) *gcpProvider {
+	gkeCluster GkeCluster) (*gcpProvider, error) {
 	auth, err := gcp.NewServiceAccountAuth(keyLocation)
 
 	if err != nil {
-		log.Fatal(fmt.Errorf("error creating service account auth from key %s: %v", keyLocation, err))
+		return nil, fmt.Errorf("error creating service account auth from key %s: %v", keyLocation, err)
 	}
 
 	return &gcpProvider{
@@ -91,36 +112,53 @@ func newGcpProvider(commandRunner commandline.CommandRunner, projectId string, k
 		gkeCluster:         gkeCluster,
 		prefs:              preferences.DefaultConfig(),
 		commandRunner:      commandRunner,
-	}
+	}, nil
 }
 
 // Setup activates the master service account from a key file
-func (gp *gcpProvider) Setup() {
+func (gp *gcpProvider) Setup() error {
 	log.Printf("Setting up GCP cloud provider authentication...")
 	if !gp.serviceAccountAuth.IsAuthenticated() {
 		gp.serviceAccountAuth.Authenticate()
-		gp.SetProject()
+		if err := gp.SetProject(); err != nil {
+			return err
+		}
 		gp.savePreferences()
 	}
+	return nil
 }
 
-func (gp *gcpProvider) SetProject() {
+func (gp *gcpProvider) SetProject() error {
 	cmdLine := fmt.Sprintf("gcloud config set project %s", gp.projectId)
 	cmd := commandline.NewCommandLine(cmdLine)
-	cmd.Run()
+	if _, err := cmd.RunWithError(); err != nil {
+		return fmt.Errorf("error setting project %s: %v", gp.projectId, err)
+	}
+	return nil
 }
 
-// ClusterSetup gcloud container clusters get-credentials [cluster-name]
-func (gp gcpProvider) ClusterSetup() {
+// ClusterSetup gcloud container clusters get-credentials [cluster-name], into the kubeconfig of the
+// cluster under ~/.myiac so the global kubectl context is left alone
+func (gp gcpProvider) ClusterSetup() error {
 	action := "container clusters get-credentials"
 	clusterName := gp.gkeCluster.name
-	zone := gp.gkeCluster.zone
+	location := gp.gkeCluster.location
 	project := gp.projectId
-	cmdLine := fmt.Sprintf("gcloud %s %s --zone %s --project %s", action, clusterName, zone, project)
+	kubeconfigPath, err := kubeconfig.Prepare(kubeconfig.GkeContext(project, location.String(), clusterName))
+	if err != nil {
+		return err
+	}
+	log.Printf("Using kubeconfig %s\n", kubeconfigPath)
+
+	cmdLine := fmt.Sprintf("gcloud %s %s %s --project %s", action, clusterName, strings.Join(location.Args(), " "),
+		project)
 	cmd := commandline.NewCommandLine(cmdLine)
-	cmd.Run()
+	if _, err := cmd.RunWithError(); err != nil {
+		return fmt.Errorf("error getting the credentials of cluster %s: %v", clusterName, err)
+	}
 	fmt.Println("GKE setup completed")
-	gp.saveGkePreferences(clusterName, zone)
+	gp.saveGkePreferences(clusterName, location)
+	return nil
 }
 
 func (gp gcpProvider) savePreferences() {
@@ -131,8 +169,69 @@ func (gp gcpProvider) savePreferences() {
 	prefs.Set("project", gp.projectId)
 }
 
-func (gp gcpProvider) saveGkePreferences(clusterName string, zone string) {
+// saveGkePreferences keeps the cluster as the active one of its project and environment, so a cluster
+// named with a prefix is the one later commands (and the kubeconfig context check) expect
+func (gp gcpProvider) saveGkePreferences(clusterName string, location gcp.Location) {
 	prefs := gp.prefs
-	prefs.Set("gke.clusterName", clusterName)
-	prefs.Set("gke.clusterZone", zone)
+	values := map[string]string{
+		prefsClusterProject:  gp.projectId,
+		prefsClusterEnv:      gp.gkeCluster.env,
+		prefsClusterName:     clusterName,
+		prefsClusterLocation: location.String(),
+	}
+	if prefs.Get(prefsClusterName) != clusterName {
+		// the terraform outputs saved belong to a different cluster
+		values[prefsClusterEndpoint], values[prefsNodePools] = "", ""
+	}
+	prefs.SetMultiple(values)
+}
+
+// ActiveKubeContext the kubeconfig context 'gcloud container clusters get-credentials' created for the
+// active cluster, empty when there's none
+func ActiveKubeContext(prefs preferences.Preferences) string {
+	project, clusterName, location := prefs.Get("project"), prefs.Get(prefsClusterName), prefs.Get(prefsClusterLocation)
+	if project == "" || clusterName == "" || location == "" {
+		return ""
+	}
+	return kubeconfig.GkeContext(project, location, clusterName)
+}
+
+// UseActiveKubeconfig makes the kubeconfig of the active cluster the one kubectl, helm and the
+// Kubernetes API calls use. Without one (credentials got before they were kept per cluster) the
+// default kubeconfig applies
+func UseActiveKubeconfig(prefs preferences.Preferences) {
+	kubeContext := ActiveKubeContext(prefs)
+	if kubeContext == "" {
+		return
+	}
+	if _, err := os.Stat(kubeconfig.PathFor(kubeContext)); err == nil {
+		kubeconfig.Use(kubeconfig.PathFor(kubeContext))
+	}
+}
+
+// VerifyKubeContext the context 'kubeContext' is the one of the cluster of 'project' and 'env': the
+// cluster named by the project naming convention, or the one created or set up for the environment
+func VerifyKubeContext(prefs preferences.Preferences, kubeContext string, project string, env string) error {
+	hint := fmt.Sprintf("run 'myiac setupEnvironment --project %s --env %s' to get its credentials", project, env)
+	contextProject, _, contextCluster, ok := kubeconfig.ParseGkeContext(kubeContext)
+	if !ok {
+		return fmt.Errorf("current kubeconfig context %s is not a GKE cluster of %s/%s, %s", kubeContext, project,
+			env, hint)
+	}
+
+	convention, err := naming.For(project)
+	if err != nil {
+		return err
+	}
+	expected := []string{convention.ClusterName(project, env, "")}
+	if outputs := SavedClusterOutputs(prefs, project, env); outputs != nil {
+		expected = append(expected, outputs.ClusterName)
+	}
+	for _, clusterName := range expected {
+		if contextProject == project && contextCluster == clusterName {
+			return nil
+		}
+	}
+	return fmt.Errorf("current kubeconfig context %s is not the cluster of %s/%s (%s), %s", kubeContext, project,
+		env, strings.Join(expected, " or "), hint)
 }
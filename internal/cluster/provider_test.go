@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a project without manifest, its clusters follow the default naming convention
+const contextProject = "contextcheck"
+
+func TestContextOfTheEnvironmentClusterIsAccepted(t *testing.T) {
+	prefs := testPreferences(t)
+
+	err := VerifyKubeContext(prefs, "gke_contextcheck_europe-west2-b_contextcheck-dev", contextProject, "dev")
+
+	assert.Nil(t, err)
+}
+
+func TestContextOfAnotherEnvironmentOrProjectIsRejected(t *testing.T) {
+	prefs := testPreferences(t)
+
+	for _, kubeContext := range []string{
+		"gke_contextcheck_europe-west2-b_contextcheck-prod",
+		"gke_moneycol_europe-west2-b_contextcheck-dev",
+		"minikube",
+	} {
+		err := VerifyKubeContext(prefs, kubeContext, contextProject, "dev")
+		assert.NotNil(t, err, kubeContext)
+		assert.Contains(t, err.Error(), "setupEnvironment --project contextcheck --env dev")
+	}
+}
+
+func TestContextOfTheClusterCreatedForTheEnvironmentIsAccepted(t *testing.T) {
+	prefs := testPreferences(t)
+	SaveClusterOutputs(prefs, contextProject, "dev", &ClusterOutputs{ClusterName: "blue-contextcheck-dev",
+		ClusterLocation: "europe-west2"})
+
+	assert.Nil(t, VerifyKubeContext(prefs, "gke_contextcheck_europe-west2_blue-contextcheck-dev", contextProject, "dev"))
+	assert.NotNil(t, VerifyKubeContext(prefs, "gke_contextcheck_europe-west2_blue-contextcheck-dev", contextProject,
+		"prod"))
+}
+
+func TestContextOfThePrefixedClusterSetUpForTheEnvironmentIsAccepted(t *testing.T) {
+	prefs := testPreferences(t)
+	provider := gcpProvider{projectId: contextProject, prefs: prefs,
+		gkeCluster: GkeCluster{name: "blue-contextcheck-dev", location: "europe-west2-b", env: "dev"}}
+
+	provider.saveGkePreferences("blue-contextcheck-dev", "europe-west2-b")
+
+	assert.Nil(t, VerifyKubeContext(prefs, "gke_contextcheck_europe-west2-b_blue-contextcheck-dev", contextProject, "dev"))
+	assert.NotNil(t, VerifyKubeContext(prefs, "gke_contextcheck_europe-west2-b_blue-contextcheck-dev", contextProject,
+		"prod"))
+}
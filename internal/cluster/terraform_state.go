@@ -0,0 +1,227 @@
+package cluster
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+)
+
+const (
+	clusterStatePrefix = "terraform/state/cluster"
+	defaultWorkspace   = "default"
+)
+
+// TerraformBackend where the state of an environment is kept: its own bucket, so switching
+// environments never reads or writes the state of another one
+type TerraformBackend struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+}
+
+// BackendFor the backend of the cluster state of a project environment
+func BackendFor(project string, env string) TerraformBackend {
+	return TerraformBackend{Bucket: gcp.StateBucketName(project, env), Prefix: clusterStatePrefix}
+}
+
+func (tb TerraformBackend) String() string {
+	return fmt.Sprintf("gs://%s/%s", tb.Bucket, tb.Prefix)
+}
+
+// initArgs the partial backend configuration passed to 'terraform init'
+func (tb TerraformBackend) initArgs() []string {
+	return []string{"-backend-config=bucket=" + tb.Bucket, "-backend-config=prefix=" + tb.Prefix}
+}
+
+// lockObjectKey the object the gcs backend creates while the state is locked
+func (tb TerraformBackend) lockObjectKey() string {
+	return fmt.Sprintf("%s/%s.tflock", tb.Prefix, defaultWorkspace)
+}
+
+// StateLock the lock info terraform writes while running an operation on the state
+type StateLock struct {
+	ID        string `json:"ID"`
+	Operation string `json:"Operation"`
+	Who       string `json:"Who"`
+	Version   string `json:"Version"`
+	Created   string `json:"Created"`
+	Path      string `json:"Path"`
+}
+
+func (sl StateLock) String() string {
+	return fmt.Sprintf("state %s locked by %s for %s since %s (lock id %s)", sl.Path, sl.Who, sl.Operation,
+		sl.Created, sl.ID)
+}
+
+// EnsureBackend runs 'terraform init -reconfigure' when the config dir isn't initialized with the
+// backend of the environment (i.e. it was last used for a different one)
+func EnsureBackend(tfPath string, backend TerraformBackend) error {
+	current := initializedBackend(tfPath)
+	if current != nil && *current == backend {
+		log.Printf("Terraform already initialized with backend %s\n", backend)
+		return nil
+	}
+
+	if current != nil {
+		log.Printf("Terraform initialized with backend %s, reconfiguring to %s\n", current, backend)
+	}
+
+	argsArray := append([]string{"init", "-reconfigure", "-input=false"}, backend.initArgs()...)
+	cmd := commandline.NewWithWorkingDir("terraform", argsArray, tfPath)
+	if _, err := cmd.RunWithError(); err != nil {
+		return fmt.Errorf("error initializing terraform with backend %s: %v", backend, err)
+	}
+	return nil
+}
+
+// initializedBackend the backend terraform was last initialized with in 'tfPath', from the
+// backend config it keeps in .terraform/terraform.tfstate. nil if not initialized
+func initializedBackend(tfPath string) *TerraformBackend {
+	content, err := ioutil.ReadFile(filepath.Join(tfPath, ".terraform", "terraform.tfstate"))
+	if err != nil {
+		return nil
+	}
+
+	var backendState struct {
+		Backend struct {
+			Type   string           `json:"type"`
+			Config TerraformBackend `json:"config"`
+		} `json:"backend"`
+	}
+	if err := json.Unmarshal(content, &backendState); err != nil || backendState.Backend.Type != "gcs" {
+		return nil
+	}
+	return &backendState.Backend.Config
+}
+
+// CurrentStateLock the lock held on the state of the backend, nil when it isn't locked
+func CurrentStateLock(storage gcp.ObjectStorageCache, backend TerraformBackend) (*StateLock, error) {
+	content, err := storage.Read(nil, backend.Bucket, backend.lockObjectKey())
+	if errors.Is(err, gcp.ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state lock of %s: %v", backend, err)
+	}
+
+	var lock StateLock
+	if err := json.Unmarshal([]byte(fmt.Sprintf("%v", content)), &lock); err != nil {
+		return nil, fmt.Errorf("error parsing state lock of %s: %v", backend, err)
+	}
+	return &lock, nil
+}
+
+// reportStateLock warns when the state is locked, terraform waits for (or fails on) the lock otherwise
+// without saying who holds it
+func reportStateLock(backend TerraformBackend) {
+	lock, err := CurrentStateLock(gcp.NewDefaultObjectStorageCache(), backend)
+	if err != nil {
+		log.Printf("[WARN] could not check the state lock: %v", err)
+		return
+	}
+	if lock != nil {
+		log.Printf("[WARN] %s. Use 'terraform force-unlock %s' if it's stale\n", lock, lock.ID)
+	}
+}
+
+// StateList the addresses of the resources in the state of the environment
+func StateList(tfPath string, backend TerraformBackend) ([]string, error) {
+	output, err := runStateCommand(tfPath, backend, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, line := range strings.Split(output, "\n") {
+		if address := strings.TrimSpace(line); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
+// StateShow the attributes of a resource in the state of the environment
+func StateShow(tfPath string, backend TerraformBackend, address string) (string, error) {
+	return runStateCommand(tfPath, backend, "show", address)
+}
+
+// runStateCommand runs a read only 'terraform state' subcommand, these never take the state lock
+func runStateCommand(tfPath string, backend TerraformBackend, args ...string) (string, error) {
+	if err := EnsureBackend(tfPath, backend); err != nil {
+		return "", err
+	}
+
+	cmd := commandline.NewWithWorkingDir("terraform", append([]string{"state"}, args...), tfPath)
+	cmd.SetSuppressOutput(true)
+	output, err := cmd.RunWithError()
+	if err != nil {
+		return "", fmt.Errorf("error reading terraform state: %v", err)
+	}
+	return output.Output, nil
+}
+
+// StateObjects the objects in the state bucket of the backend (state files of every prefix and locks)
+func StateObjects(storage gcp.ObjectStorageCache, backend TerraformBackend) ([]string, error) {
+	keys, err := storage.List(nil, backend.Bucket, "")
+	if err != nil {
+		return nil, fmt.Errorf("error listing state objects in %s: %v", backend.Bucket, err)
+	}
+	return keys, nil
+}
+
+// BackupState archives the current version of every object in the state bucket into a
+// <bucket>-<timestamp>.tar.gz file in 'backupDir', returning its path
+func BackupState(storage gcp.ObjectStorageCache, backend TerraformBackend, backupDir string) (string, error) {
+	keys, err := StateObjects(storage, backend)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", fmt.Errorf("error creating state backup dir %s: %v", backupDir, err)
+	}
+	archivePath := filepath.Join(backupDir, fmt.Sprintf("%s-%s.tar.gz", backend.Bucket, time.Now().Format("20060102150405")))
+	archive, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("error creating state backup %s: %v", archivePath, err)
+	}
+	defer archive.Close()
+
+	gzipWriter := gzip.NewWriter(archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, key := range keys {
+		content, err := storage.Read(nil, backend.Bucket, key)
+		if err != nil {
+			return "", fmt.Errorf("error reading state object %s: %v", key, err)
+		}
+
+		data := []byte(fmt.Sprintf("%v", content))
+		header := &tar.Header{Name: key, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return "", fmt.Errorf("error archiving state object %s: %v", key, err)
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			return "", fmt.Errorf("error archiving state object %s: %v", key, err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return "", fmt.Errorf("error writing state backup %s: %v", archivePath, err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", fmt.Errorf("error writing state backup %s: %v", archivePath, err)
+	}
+
+	log.Printf("Backed up %d state objects of %s to %s\n", len(keys), backend.Bucket, archivePath)
+	return archivePath, nil
+}
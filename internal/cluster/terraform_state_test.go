@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEachEnvironmentHasItsOwnBackend(t *testing.T) {
+	dev := BackendFor("moneycol", "dev")
+	prod := BackendFor("moneycol", "prod")
+
+	assert.Equal(t, TerraformBackend{Bucket: "moneycol-tfstate-dev", Prefix: "terraform/state/cluster"}, dev)
+	assert.NotEqual(t, dev, prod)
+}
+
+func TestInitializedBackendIsReadFromTerraformDir(t *testing.T) {
+	tfPath, _ := ioutil.TempDir("", "tfconfig")
+	defer os.RemoveAll(tfPath)
+
+	assert.Nil(t, initializedBackend(tfPath))
+
+	backendState := `{"version": 3, "backend": {"type": "gcs",
+		"config": {"bucket": "moneycol-tfstate-dev", "prefix": "terraform/state/cluster", "credentials": null}}}`
+	_ = os.MkdirAll(filepath.Join(tfPath, ".terraform"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(tfPath, ".terraform", "terraform.tfstate"), []byte(backendState), 0644)
+
+	backend := initializedBackend(tfPath)
+
+	assert.NotNil(t, backend)
+	assert.Equal(t, BackendFor("moneycol", "dev"), *backend)
+	assert.NotEqual(t, BackendFor("moneycol", "prod"), *backend)
+}
+
+func TestStateLockIsReadFromBackend(t *testing.T) {
+	storageDir, _ := ioutil.TempDir("", "storage")
+	defer os.RemoveAll(storageDir)
+	storage := gcp.NewFileObjectStorageCache(storageDir)
+	backend := BackendFor("moneycol", "dev")
+
+	lock, err := CurrentStateLock(storage, backend)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+
+	lockInfo := `{"ID":"1614600000000000","Path":"gs://moneycol-tfstate-dev/terraform/state/cluster/default.tflock",
+		"Operation":"OperationTypeApply","Who":"alice@laptop","Version":"0.14.7","Created":"2021-03-01T12:00:00Z","Info":""}`
+	_ = storage.Write(nil, backend.Bucket, "terraform/state/cluster/default.tflock", lockInfo)
+
+	lock, err = CurrentStateLock(storage, backend)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@laptop", lock.Who)
+	assert.Equal(t, "OperationTypeApply", lock.Operation)
+	assert.Equal(t, "1614600000000000", lock.ID)
+}
+
+func TestStateIsBackedUpToArchive(t *testing.T) {
+	storageDir, _ := ioutil.TempDir("", "storage")
+	defer os.RemoveAll(storageDir)
+	backupDir, _ := ioutil.TempDir("", "backups")
+	defer os.RemoveAll(backupDir)
+	storage := gcp.NewFileObjectStorageCache(storageDir)
+	backend := BackendFor("moneycol", "dev")
+	_ = storage.Write(nil, backend.Bucket, "terraform/state/cluster/default.tfstate", `{"version": 4}`)
+	_ = storage.Write(nil, backend.Bucket, "terraform/state/dns/default.tfstate", `{"version": 4, "serial": 2}`)
+
+	archivePath, err := BackupState(storage, backend, backupDir)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(archivePath), "moneycol-tfstate-dev-"))
+	archived := readArchive(t, archivePath)
+	assert.Equal(t, map[string]string{
+		"terraform/state/cluster/default.tfstate": `{"version": 4}`,
+		"terraform/state/dns/default.tfstate":     `{"version": 4, "serial": 2}`,
+	}, archived)
+}
+
+func readArchive(t *testing.T, archivePath string) map[string]string {
+	archive, err := os.Open(archivePath)
+	assert.Nil(t, err)
+	defer archive.Close()
+
+	gzipReader, err := gzip.NewReader(archive)
+	assert.Nil(t, err)
+	tarReader := tar.NewReader(gzipReader)
+
+	contents := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		content, _ := ioutil.ReadAll(tarReader)
+		contents[header.Name] = string(content)
+	}
+	return contents
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/naming"
+)
+
+// generatedTfVarsFile terraform loads *.auto.tfvars.json after terraform.tfvars, so the generated
+// values take precedence over the ones in the config, and -var overrides over both
+const generatedTfVarsFile = "myiac.auto.tfvars.json"
+
+// ClusterSettings the settings of a cluster passed to the terraform config as variables
+type ClusterSettings struct {
+	Project     string
+	Environment string
+	manifest.Cluster
+	// VarOverrides key=value pairs passed as -var to terraform
+	VarOverrides []string
+}
+
+// TerraformVars the terraform variables for the settings. Unset settings are left out so the
+// values from terraform.tfvars or the variable defaults apply
+func (cs ClusterSettings) TerraformVars() map[string]interface{} {
+	vars := make(map[string]interface{})
+	setIfPresent := func(name string, value interface{}, present bool) {
+		if present {
+			vars[name] = value
+		}
+	}
+
+	setIfPresent("project", cs.Project, cs.Project != "")
+	setIfPresent("environment", cs.Environment, cs.Environment != "")
+	if cs.Project != "" && cs.Environment != "" {
+		backend := BackendFor(cs.Project, cs.Environment)
+		vars["cluster_state_bucket"] = backend.Bucket
+		vars["state_bucket_prefix"] = backend.Prefix
+		vars["cluster_name"] = naming.MustFor(cs.Project).ClusterName(cs.Project, cs.Environment, cs.Prefix)
+	}
+	// the config takes a zone or a region as the cluster location
+	setIfPresent("cluster_zone", cs.Location(), cs.Location() != "")
+	setIfPresent("cluster_prefix", cs.Prefix, cs.Prefix != "")
+	setIfPresent("applications_machine_type", cs.MachineType, cs.MachineType != "")
+	setIfPresent("applications_node_count", cs.NodeCount, cs.NodeCount != 0)
+	setIfPresent("applications_max_node_count", cs.MaxNodeCount, cs.MaxNodeCount != 0)
+	if cs.Preemptible != nil {
+		vars["applications_preemptible"] = *cs.Preemptible
+	}
+	return vars
+}
+
+// VarArgs the -var arguments for the overrides
+func (cs ClusterSettings) VarArgs() ([]string, error) {
+	var args []string
+	for _, override := range cs.VarOverrides {
+		if !strings.Contains(override, "=") || strings.HasPrefix(override, "=") {
+			return nil, fmt.Errorf("invalid terraform variable '%s', expected key=value", override)
+		}
+		args = append(args, "-var", override)
+	}
+	return args, nil
+}
+
+// writeTfVars generates the auto loaded variables file of the settings in the terraform config dir
+func writeTfVars(tfConfigPath string, settings ClusterSettings) error {
+	varsJson, err := json.MarshalIndent(settings.TerraformVars(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing terraform variables: %v", err)
+	}
+
+	varsFile := filepath.Join(tfConfigPath, generatedTfVarsFile)
+	if err := ioutil.WriteFile(varsFile, varsJson, 0644); err != nil {
+		return fmt.Errorf("error writing terraform variables %s: %v", varsFile, err)
+	}
+
+	log.Printf("Terraform variables written to %s:\n%s\n", varsFile, varsJson)
+	return nil
+}
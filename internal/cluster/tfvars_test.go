@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlySetSettingsBecomeTerraformVars(t *testing.T) {
+	preemptible := false
+	settings := ClusterSettings{
+		Project:     "moneycol",
+		Environment: "dev",
+		Cluster:     manifest.Cluster{Zone: "europe-west2-b", MaxNodeCount: 4, Preemptible: &preemptible},
+	}
+
+	vars := settings.TerraformVars()
+
+	assert.Equal(t, map[string]interface{}{
+		"project":                     "moneycol",
+		"environment":                 "dev",
+		"cluster_state_bucket":        "moneycol-tfstate-dev",
+		"state_bucket_prefix":         "terraform/state/cluster",
+		"cluster_name":                "moneycol-dev",
+		"cluster_zone":                "europe-west2-b",
+		"applications_max_node_count": 4,
+		"applications_preemptible":    false,
+	}, vars)
+}
+
+func TestVarOverridesArePassedAsVarArgs(t *testing.T) {
+	settings := ClusterSettings{VarOverrides: []string{"k8s_master_version=1.18.20-gke.900", "cluster_zone=europe-west1-b"}}
+
+	args, err := settings.VarArgs()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-var", "k8s_master_version=1.18.20-gke.900", "-var", "cluster_zone=europe-west1-b"}, args)
+}
+
+func TestInvalidVarOverrideIsRejected(t *testing.T) {
+	_, err := ClusterSettings{VarOverrides: []string{"cluster_zone"}}.VarArgs()
+
+	assert.NotNil(t, err)
+}
+
+func TestTfVarsAreWrittenAsAutoLoadedJson(t *testing.T) {
+	tfConfigPath, _ := ioutil.TempDir("", "tfconfig")
+	defer os.RemoveAll(tfConfigPath)
+
+	err := writeTfVars(tfConfigPath, ClusterSettings{Project: "moneycol", Cluster: manifest.Cluster{MachineType: "e2-small"}})
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(tfConfigPath, "myiac.auto.tfvars.json"))
+	assert.Nil(t, err)
+	var vars map[string]interface{}
+	assert.Nil(t, json.Unmarshal(content, &vars))
+	assert.Equal(t, "e2-small", vars["applications_machine_type"])
+}
+
+func TestRegionIsTheClusterLocation(t *testing.T) {
+	settings := ClusterSettings{Cluster: manifest.Cluster{Region: "europe-west2"}}
+
+	vars := settings.TerraformVars()
+
+	assert.Equal(t, "europe-west2", vars["cluster_zone"])
+}
+
+func TestClusterNameFollowsTheNamingConvention(t *testing.T) {
+	settings := ClusterSettings{Project: "moneycol", Environment: "dev", Cluster: manifest.Cluster{Prefix: "blue"}}
+
+	vars := settings.TerraformVars()
+
+	assert.Equal(t, "blue-moneycol-dev", vars["cluster_name"])
+	assert.Equal(t, "blue", vars["cluster_prefix"])
+}
@@ -9,6 +9,8 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+
+	"github.com/iac-io/myiac/internal/kubeconfig"
 )
 
 // CommandRunner Implicit interface for commandline package, need access to those methods here
@@ -21,6 +23,7 @@ type CommandRunner interface {
 	IgnoreError(ignoreError bool)
 	SetSuppressOutput(suppressOutput bool)
 	Run() CommandOutput
+	RunWithError() (CommandOutput, error)
 }
 
 type CommandOptions struct {
@@ -40,6 +43,30 @@ type commandExec struct {
 
 type CommandOutput struct {
 	Output string
+	// Stderr what the command wrote to stderr, only set by RunWithError where Output is only stdout
+	Stderr string
+}
+
+// CommandError is returned by RunWithError when the command could not run or exited with non-zero status
+type CommandError struct {
+	CommandLine string
+	ExitCode    int
+	Output      string
+	Stderr      string
+	Err         error
+}
+
+func (ce *CommandError) Error() string {
+	return fmt.Sprintf("command [ %s ] failed with exit code %d: %v", ce.CommandLine, ce.ExitCode, ce.Err)
+}
+
+// progressOutput where the commands run and their output are shown
+var progressOutput io.Writer = os.Stdout
+
+// ShowProgressOnStderr shows the commands run and their output on stderr instead of stdout, for
+// the commands whose report (i.e. JSON) printed to stdout must be parseable
+func ShowProgressOnStderr() {
+	progressOutput = os.Stderr
 }
 
 func NewEmpty() CommandRunner {
@@ -117,16 +144,19 @@ func (c *commandExec) SetSuppressOutput(suppressOutput bool) {
 
 func (c *commandExec) Run() CommandOutput {
 	cmd := exec.Command(c.executable, c.arguments...)
+	cmd.Env = kubeconfig.Env(c.executable)
 
 	if c.workingDir != "" {
 		cmd.Dir = c.workingDir
-		fmt.Printf("Working dir is: %s\n", c.workingDir)
+		fmt.Fprintf(progressOutput, "Working dir is: %s\n", c.workingDir)
 	}
 
 	cmdStr := string(strings.Join(cmd.Args, " "))
-	fmt.Printf("Executing [ %s ]\n", cmdStr)
+	fmt.Fprintf(progressOutput, "Executing [ %s ]\n", cmdStr)
 
-	output, err := withProgress(cmd, c.IsSuppressOutput, c.ignoreError)
+	stdout, stderr, err := withProgress(cmd, c.IsSuppressOutput, c.ignoreError)
+	// Not sure if this is the way, but there are valid data on stdout and stderr
+	output := stdout + "\n" + stderr
 
 	if err != nil && !c.ignoreError {
 		log.Fatalf("command [ %s ] failed with %s\n", cmdStr, err)
@@ -134,15 +164,46 @@ func (c *commandExec) Run() CommandOutput {
 
 	if err != nil && c.ignoreError {
 		log.Printf("Ignoring error for command [ %s ] with %v\n", cmdStr, err)
-	} else {
-		c.saveOutput(output)
 	}
 
+	c.saveOutput(output)
+
 	outputResult := CommandOutput{Output: c.commandOutput}
 
 	return outputResult
 }
 
+// RunWithError runs the command as Run does, but a failure is returned as a *CommandError
+// instead of terminating the process, so callers can inspect exit code and output. The output is
+// only stdout, so it can be parsed even when the command warns on stderr
+func (c *commandExec) RunWithError() (CommandOutput, error) {
+	cmd := exec.Command(c.executable, c.arguments...)
+	cmd.Env = kubeconfig.Env(c.executable)
+
+	if c.workingDir != "" {
+		cmd.Dir = c.workingDir
+		fmt.Fprintf(progressOutput, "Working dir is: %s\n", c.workingDir)
+	}
+
+	cmdStr := strings.Join(cmd.Args, " ")
+	fmt.Fprintf(progressOutput, "Executing [ %s ]\n", cmdStr)
+
+	stdout, stderr, err := withProgress(cmd, c.IsSuppressOutput, true)
+	c.saveOutput(stdout)
+	outputResult := CommandOutput{Output: stdout, Stderr: stderr}
+
+	if err != nil {
+		exitCode := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return outputResult, &CommandError{CommandLine: cmdStr, ExitCode: exitCode, Output: stdout, Stderr: stderr,
+			Err: err}
+	}
+
+	return outputResult, nil
+}
+
 func (c *commandExec) RunVoid() {
 	// Important: for this delegation to work properly and save the output, we need to
 	// pass in a pointer, which is what ultimately gets modified in the 'saveOutput' method
@@ -221,7 +282,8 @@ func copyAndCapture(w io.Writer, r io.Reader) ([]byte, error) {
 	}
 }
 
-func withProgress(cmd *exec.Cmd, suppressOutput bool, ignoreError bool) (string, error) {
+// withProgress runs the command showing its output as it goes, returning its stdout and stderr
+func withProgress(cmd *exec.Cmd, suppressOutput bool, ignoreError bool) (string, string, error) {
 
 	var stdout, stderr []byte
 	var errStdout, errStderr error
@@ -230,6 +292,9 @@ func withProgress(cmd *exec.Cmd, suppressOutput bool, ignoreError bool) (string,
 	stderrIn, _ := cmd.StderrPipe()
 
 	err := cmd.Start()
+	if err != nil && ignoreError {
+		return "", "", err
+	}
 	if err != nil {
 		log.Fatalf("cmd.Start() failed with '%s'\n", err)
 	}
@@ -240,7 +305,7 @@ func withProgress(cmd *exec.Cmd, suppressOutput bool, ignoreError bool) (string,
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		stdout, errStdout = copyAndCapture(os.Stdout, stdoutIn)
+		stdout, errStdout = copyAndCapture(progressOutput, stdoutIn)
 		wg.Done()
 	}()
 
@@ -263,12 +328,9 @@ func withProgress(cmd *exec.Cmd, suppressOutput bool, ignoreError bool) (string,
 	}
 
 	outputStr, errorStr := string(stdout), string(stderr)
-
-	// Not sure if this is the way, but there are valid data on stdout and stderr
-	combinedOutputStr := outputStr + "\n" + errorStr
 	if !suppressOutput {
-		fmt.Printf("\nOutput:\n%s\n", combinedOutputStr)
+		fmt.Fprintf(progressOutput, "\nOutput:\n%s\n%s\n", outputStr, errorStr)
 	}
 
-	return combinedOutputStr, nil
+	return outputStr, errorStr, err
 }
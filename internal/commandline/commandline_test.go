@@ -0,0 +1,37 @@
+package commandline
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithErrorOutputIsOnlyStdout(t *testing.T) {
+	output, err := New("sh", []string{"-c", `echo '{"key": "value"}'; echo 'WARNING: deprecated' >&2`}).RunWithError()
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"key": "value"}`, strings.TrimSpace(output.Output))
+	assert.Equal(t, "WARNING: deprecated", strings.TrimSpace(output.Stderr))
+}
+
+func TestProgressOnStderrLeavesStdoutForReports(t *testing.T) {
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error creating pipe %v", err)
+	}
+	originalStdout, originalProgress := os.Stdout, progressOutput
+	os.Stdout = stdoutWriter
+	defer func() { os.Stdout, progressOutput = originalStdout, originalProgress }()
+
+	ShowProgressOnStderr()
+	output, err := NewWithWorkingDir("echo", []string{"{\"key\": \"value\"}"}, os.TempDir()).RunWithError()
+	_ = stdoutWriter.Close()
+	written, _ := ioutil.ReadAll(stdoutReader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"key": "value"}`, strings.TrimSpace(output.Output))
+	assert.Empty(t, string(written))
+}
@@ -0,0 +1,189 @@
+// Package cost estimates the monthly cost of the cluster of an environment (nodes, their boot disks
+// and static IPs) from a pricing table, and what scheduled scale downs or preemptible nodes save
+package cost
+
+import (
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/schedule"
+)
+
+const defaultDiskType = "pd-standard"
+
+// NodePool what the cost of a node pool depends on
+type NodePool struct {
+	Name         string `json:"name"`
+	MachineType  string `json:"machineType"`
+	NodesPerZone int    `json:"nodesPerZone"`
+	Zones        int    `json:"zones"`
+	Preemptible  bool   `json:"preemptible"`
+	DiskType     string `json:"diskType"`
+	DiskSizeGb   int    `json:"diskSizeGb"`
+}
+
+// NodePoolOf the cost settings of a live node pool with 'nodesPerZone' nodes in each of its zones
+func NodePoolOf(nodePool gcp.NodePool, nodesPerZone int) NodePool {
+	zones := len(nodePool.Locations)
+	if zones == 0 {
+		zones = 1
+	}
+	diskType := nodePool.Config.DiskType
+	if diskType == "" {
+		diskType = defaultDiskType
+	}
+	return NodePool{
+		Name:         nodePool.Name,
+		MachineType:  nodePool.Config.MachineType,
+		NodesPerZone: nodesPerZone,
+		Zones:        zones,
+		Preemptible:  nodePool.Config.Preemptible || nodePool.Config.Spot,
+		DiskType:     diskType,
+		DiskSizeGb:   nodePool.Config.DiskSizeGb,
+	}
+}
+
+// Nodes the nodes of the pool in all its zones
+func (np NodePool) Nodes() int {
+	return np.NodesPerZone * np.Zones
+}
+
+// PoolEstimate the monthly cost of a node pool
+type PoolEstimate struct {
+	NodePool
+	Compute float64 `json:"compute"`
+	Disk    float64 `json:"disk"`
+	// ScaledDownHours hours a week the pool is scaled down by its schedule window
+	ScaledDownHours float64 `json:"scaledDownHours"`
+	// ScheduleSavings what the nodes removed by the schedule window save
+	ScheduleSavings float64 `json:"scheduleSavings"`
+	// PreemptibleSavings what switching the on-demand nodes to preemptible ones would save, on top of the schedule
+	PreemptibleSavings float64 `json:"preemptibleSavings"`
+}
+
+// Estimate the monthly cost of the cluster of an environment
+type Estimate struct {
+	PricingVersion string         `json:"pricingVersion"`
+	Currency       string         `json:"currency"`
+	Pools          []PoolEstimate `json:"pools"`
+	// StaticIps the external static IPs in the region of the cluster
+	StaticIps     int     `json:"staticIps"`
+	StaticIpsCost float64 `json:"staticIpsCost"`
+	// ProjectStaticIps the global external static IPs and the ones of other regions, which can't be told
+	// apart by environment, so they aren't part of the total
+	ProjectStaticIps     int     `json:"projectStaticIps"`
+	ProjectStaticIpsCost float64 `json:"projectStaticIpsCost"`
+}
+
+// Total the monthly cost without the scheduled scale downs nor the project-wide static IPs
+func (e *Estimate) Total() float64 {
+	total := e.StaticIpsCost
+	for _, pool := range e.Pools {
+		total += pool.Compute + pool.Disk
+	}
+	return total
+}
+
+// ScheduleSavings the monthly savings of the schedule windows of the environment
+func (e *Estimate) ScheduleSavings() float64 {
+	savings := 0.0
+	for _, pool := range e.Pools {
+		savings += pool.ScheduleSavings
+	}
+	return savings
+}
+
+// PreemptibleSavings the monthly savings of switching every on-demand node pool to preemptible nodes
+func (e *Estimate) PreemptibleSavings() float64 {
+	savings := 0.0
+	for _, pool := range e.Pools {
+		savings += pool.PreemptibleSavings
+	}
+	return savings
+}
+
+// EstimateCluster the monthly cost of 'nodePools' and the external ones of 'addresses' with 'pricing',
+// and the savings of the schedule 'windows' of the environment. Only the addresses of the cluster
+// 'region' count as the ones of the cluster, the rest are reported as project-wide
+func EstimateCluster(pricing *Pricing, nodePools []NodePool, addresses []gcp.Address, region string,
+	windows []manifest.ScheduleWindow) (*Estimate, error) {
+	estimate := &Estimate{PricingVersion: pricing.Version, Currency: pricing.Currency}
+
+	windowsByPool := make(map[string]manifest.ScheduleWindow)
+	for _, window := range windows {
+		windowsByPool[window.NodePool] = window
+	}
+
+	for _, nodePool := range nodePools {
+		var window *manifest.ScheduleWindow
+		if poolWindow, ok := windowsByPool[nodePool.Name]; ok {
+			window = &poolWindow
+		}
+		poolEstimate, err := estimatePool(pricing, nodePool, window)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Pools = append(estimate.Pools, *poolEstimate)
+	}
+
+	for _, address := range addresses {
+		if !address.IsExternal() {
+			continue
+		}
+		hourly := pricing.StaticIp.Reserved
+		if address.Status == gcp.AddressInUse {
+			hourly = pricing.StaticIp.InUse
+		}
+		if address.RegionName() == region {
+			estimate.StaticIps++
+			estimate.StaticIpsCost += hourly * HoursPerMonth
+		} else {
+			estimate.ProjectStaticIps++
+			estimate.ProjectStaticIpsCost += hourly * HoursPerMonth
+		}
+	}
+	return estimate, nil
+}
+
+func estimatePool(pricing *Pricing, nodePool NodePool, window *manifest.ScheduleWindow) (*PoolEstimate, error) {
+	machinePrice, err := pricing.machinePrice(nodePool.MachineType)
+	if err != nil {
+		return nil, err
+	}
+	diskPrice, err := pricing.diskPrice(nodePool.DiskType)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := machinePrice.OnDemand
+	if nodePool.Preemptible {
+		hourly = machinePrice.Preemptible
+	}
+	nodes := float64(nodePool.Nodes())
+	diskPerNode := float64(nodePool.DiskSizeGb) * diskPrice
+	poolEstimate := &PoolEstimate{
+		NodePool: nodePool,
+		Compute:  nodes * hourly * HoursPerMonth,
+		Disk:     nodes * diskPerNode,
+	}
+
+	// nodes removed by the window don't run nor keep their boot disks while scaled down
+	nodeHours := nodes * HoursPerMonth
+	if window != nil {
+		scaledDownHours, err := schedule.ScaledDownHours(*window)
+		if err != nil {
+			return nil, err
+		}
+		removed := float64((nodePool.NodesPerZone - window.Size) * nodePool.Zones)
+		if removed > 0 {
+			downFraction := scaledDownHours / (7 * 24)
+			poolEstimate.ScaledDownHours = scaledDownHours
+			poolEstimate.ScheduleSavings = removed * (hourly*HoursPerMonth + diskPerNode) * downFraction
+			nodeHours -= removed * HoursPerMonth * downFraction
+		}
+	}
+
+	if !nodePool.Preemptible {
+		poolEstimate.PreemptibleSavings = (machinePrice.OnDemand - machinePrice.Preemptible) * nodeHours
+	}
+	return poolEstimate, nil
+}
@@ -0,0 +1,147 @@
+package cost
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPricing() *Pricing {
+	return &Pricing{
+		Version:  "test",
+		Currency: "USD",
+		MachineTypes: map[string]MachinePrice{
+			"e2-standard-2": {OnDemand: 0.10, Preemptible: 0.03},
+		},
+		Disks:    map[string]float64{"pd-standard": 0.05},
+		StaticIp: StaticIpPrice{InUse: 0.005, Reserved: 0.01},
+	}
+}
+
+func defaultPool() NodePool {
+	return NodePool{Name: "default-pool", MachineType: "e2-standard-2", NodesPerZone: 3, Zones: 1,
+		DiskType: "pd-standard", DiskSizeGb: 100}
+}
+
+func TestEstimateOfNodesDisksAndStaticIps(t *testing.T) {
+	regionUrl := "https://www.googleapis.com/compute/v1/projects/moneycol/regions/"
+	addresses := []gcp.Address{
+		{Name: "traefik-ip", AddressType: gcp.AddressExternal, Status: gcp.AddressInUse, Region: regionUrl + "europe-west2"},
+		{Name: "dns-ip", AddressType: gcp.AddressExternal, Status: "RESERVED", Region: regionUrl + "europe-west2"},
+		{Name: "frontend-ip", Status: "RESERVED"},
+		{Name: "us-ip", AddressType: gcp.AddressExternal, Status: gcp.AddressInUse, Region: regionUrl + "us-east1"},
+		{Name: "nat-internal", AddressType: "INTERNAL", Status: "RESERVED", Region: regionUrl + "europe-west2"},
+	}
+
+	estimate, err := EstimateCluster(testPricing(), []NodePool{defaultPool()}, addresses, "europe-west2", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test", estimate.PricingVersion)
+	assert.InDelta(t, 3*0.10*730, estimate.Pools[0].Compute, 0.001)
+	assert.InDelta(t, 3*100*0.05, estimate.Pools[0].Disk, 0.001)
+	assert.Equal(t, 2, estimate.StaticIps)
+	assert.InDelta(t, (0.005+0.01)*730, estimate.StaticIpsCost, 0.001)
+	// global and other region IPs are reported apart, out of the total
+	assert.Equal(t, 2, estimate.ProjectStaticIps)
+	assert.InDelta(t, (0.01+0.005)*730, estimate.ProjectStaticIpsCost, 0.001)
+	assert.InDelta(t, 219+15+10.95, estimate.Total(), 0.001)
+	assert.Zero(t, estimate.ScheduleSavings())
+	assert.InDelta(t, 3*0.07*730, estimate.PreemptibleSavings(), 0.001)
+}
+
+func TestScheduleWindowSavings(t *testing.T) {
+	// scaled down to 1 node 12 hours every day, half the time
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 19 * * *", ScaleUp: "0 7 * * *", Size: 1}}
+
+	estimate, err := EstimateCluster(testPricing(), []NodePool{defaultPool()}, nil, "", windows)
+
+	assert.Nil(t, err)
+	pool := estimate.Pools[0]
+	assert.Equal(t, 84.0, pool.ScaledDownHours)
+	assert.InDelta(t, 2*(0.10*730+5)/2, pool.ScheduleSavings, 0.001)
+	// preemptible savings only count the hours the nodes run
+	assert.InDelta(t, 0.07*(3*730-2*365), pool.PreemptibleSavings, 0.001)
+}
+
+func TestRegionalPoolsCountNodesOfEveryZone(t *testing.T) {
+	pool := defaultPool()
+	pool.Zones, pool.Preemptible = 3, true
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 19 * * *", ScaleUp: "0 7 * * *", Size: 2}}
+
+	estimate, err := EstimateCluster(testPricing(), []NodePool{pool}, nil, "", windows)
+
+	assert.Nil(t, err)
+	assert.InDelta(t, 9*0.03*730, estimate.Pools[0].Compute, 0.001)
+	assert.InDelta(t, 3*(0.03*730+5)/2, estimate.Pools[0].ScheduleSavings, 0.001)
+	assert.Zero(t, estimate.Pools[0].PreemptibleSavings)
+}
+
+func TestUnknownMachineTypeFails(t *testing.T) {
+	pool := defaultPool()
+	pool.MachineType = "custom-4-16384"
+
+	_, err := EstimateCluster(testPricing(), []NodePool{pool}, nil, "", nil)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "custom-4-16384")
+}
+
+func TestNodePoolOfLiveRegionalPool(t *testing.T) {
+	var nodePool gcp.NodePool
+	nodePool.Name = "default-pool"
+	nodePool.Config.MachineType = "e2-medium"
+	nodePool.Config.Spot = true
+	nodePool.Config.DiskSizeGb = 50
+	nodePool.Locations = []string{"europe-west2-a", "europe-west2-b"}
+
+	pool := NodePoolOf(nodePool, 2)
+
+	assert.Equal(t, 4, pool.Nodes())
+	assert.True(t, pool.Preemptible)
+	assert.Equal(t, "pd-standard", pool.DiskType)
+}
+
+func TestPricingFileOverridesDefaultTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pricing")
+	if err != nil {
+		t.Fatalf("error creating pricing dir %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pricingPath := filepath.Join(dir, "pricing.yaml")
+	content := `version: 2026.10-committed-use
+machineTypes:
+  e2-standard-2: {onDemand: 0.06, preemptible: 0.02}
+  c3-standard-4: {onDemand: 0.25, preemptible: 0.08}
+`
+	if err := ioutil.WriteFile(pricingPath, []byte(content), 0600); err != nil {
+		t.Fatalf("error writing pricing file %v", err)
+	}
+
+	pricing, err := LoadPricing(pricingPath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2026.10-committed-use", pricing.Version)
+	assert.Equal(t, 0.06, pricing.MachineTypes["e2-standard-2"].OnDemand)
+	assert.Equal(t, 0.25, pricing.MachineTypes["c3-standard-4"].OnDemand)
+	assert.Equal(t, DefaultPricing().MachineTypes["e2-medium"], pricing.MachineTypes["e2-medium"])
+	assert.Equal(t, DefaultPricing().Disks, pricing.Disks)
+}
+
+func TestPricingFileWithoutVersionIsRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pricing")
+	if err != nil {
+		t.Fatalf("error creating pricing dir %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pricingPath := filepath.Join(dir, "pricing.yaml")
+	_ = ioutil.WriteFile(pricingPath, []byte("disks: {pd-ssd: 0.17}\n"), 0600)
+
+	_, err = LoadPricing(pricingPath)
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,120 @@
+package cost
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HoursPerMonth the hours of an average month, the one GCP bills with
+const HoursPerMonth = 730.0
+
+// Pricing the list prices of the resources of a cluster. The built-in table is versioned so every
+// estimate says which prices it used, a pricing file overrides it entry by entry
+type Pricing struct {
+	Version  string `yaml:"version"`
+	Currency string `yaml:"currency"`
+	// MachineTypes the hourly price of a node of each machine type
+	MachineTypes map[string]MachinePrice `yaml:"machineTypes"`
+	// Disks the monthly price of a GB of each boot disk type
+	Disks map[string]float64 `yaml:"disks"`
+	// StaticIp the hourly price of an external static IP
+	StaticIp StaticIpPrice `yaml:"staticIp"`
+}
+
+// MachinePrice hourly price of a node, on-demand and preemptible (or spot)
+type MachinePrice struct {
+	OnDemand    float64 `yaml:"onDemand"`
+	Preemptible float64 `yaml:"preemptible"`
+}
+
+// StaticIpPrice hourly price of an external static IP attached to a resource and of one only reserved
+type StaticIpPrice struct {
+	InUse    float64 `yaml:"inUse"`
+	Reserved float64 `yaml:"reserved"`
+}
+
+// DefaultPricing the built-in table: list prices of europe-west2 (London) in USD
+func DefaultPricing() *Pricing {
+	return &Pricing{
+		Version:  "2024.06-europe-west2",
+		Currency: "USD",
+		MachineTypes: map[string]MachinePrice{
+			"e2-micro":       {OnDemand: 0.0108, Preemptible: 0.0033},
+			"e2-small":       {OnDemand: 0.0216, Preemptible: 0.0065},
+			"e2-medium":      {OnDemand: 0.0432, Preemptible: 0.0130},
+			"e2-standard-2":  {OnDemand: 0.0863, Preemptible: 0.0259},
+			"e2-standard-4":  {OnDemand: 0.1726, Preemptible: 0.0518},
+			"e2-standard-8":  {OnDemand: 0.3452, Preemptible: 0.1036},
+			"e2-highmem-2":   {OnDemand: 0.1164, Preemptible: 0.0349},
+			"e2-highmem-4":   {OnDemand: 0.2328, Preemptible: 0.0698},
+			"g1-small":       {OnDemand: 0.0331, Preemptible: 0.0070},
+			"n1-standard-1":  {OnDemand: 0.0612, Preemptible: 0.0129},
+			"n1-standard-2":  {OnDemand: 0.1224, Preemptible: 0.0258},
+			"n1-standard-4":  {OnDemand: 0.2448, Preemptible: 0.0516},
+			"n2-standard-2":  {OnDemand: 0.1251, Preemptible: 0.0304},
+			"n2-standard-4":  {OnDemand: 0.2502, Preemptible: 0.0608},
+			"n2d-standard-2": {OnDemand: 0.1088, Preemptible: 0.0264},
+		},
+		Disks: map[string]float64{
+			"pd-standard": 0.048,
+			"pd-balanced": 0.120,
+			"pd-ssd":      0.204,
+		},
+		StaticIp: StaticIpPrice{InUse: 0.005, Reserved: 0.010},
+	}
+}
+
+// LoadPricing the built-in table overridden by the pricing file 'path'. The file sets its own
+// version, as its prices are no longer the built-in ones
+func LoadPricing(path string) (*Pricing, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pricing file %s: %v", path, err)
+	}
+	var overrides Pricing
+	if err := yaml.UnmarshalStrict(content, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid pricing file %s: %v", path, err)
+	}
+	if overrides.Version == "" {
+		return nil, fmt.Errorf("pricing file %s has no version", path)
+	}
+
+	pricing := DefaultPricing()
+	pricing.Version = overrides.Version
+	if overrides.Currency != "" {
+		pricing.Currency = overrides.Currency
+	}
+	for machineType, price := range overrides.MachineTypes {
+		pricing.MachineTypes[machineType] = price
+	}
+	for diskType, price := range overrides.Disks {
+		pricing.Disks[diskType] = price
+	}
+	if overrides.StaticIp.InUse != 0 {
+		pricing.StaticIp.InUse = overrides.StaticIp.InUse
+	}
+	if overrides.StaticIp.Reserved != 0 {
+		pricing.StaticIp.Reserved = overrides.StaticIp.Reserved
+	}
+	return pricing, nil
+}
+
+// machinePrice the price of 'machineType', an error for the ones missing in the table
+func (p *Pricing) machinePrice(machineType string) (MachinePrice, error) {
+	price, ok := p.MachineTypes[machineType]
+	if !ok {
+		return MachinePrice{}, fmt.Errorf("no price for machine type %s in pricing %s, add it to a pricing file",
+			machineType, p.Version)
+	}
+	return price, nil
+}
+
+func (p *Pricing) diskPrice(diskType string) (float64, error) {
+	price, ok := p.Disks[diskType]
+	if !ok {
+		return 0, fmt.Errorf("no price for disk type %s in pricing %s, add it to a pricing file", diskType, p.Version)
+	}
+	return price, nil
+}
@@ -1,20 +1,53 @@
 package deploy
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/encryption"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/naming"
 	"github.com/iac-io/myiac/internal/util"
 )
 
+// Deployer deploys an app into an environment, reporting what it ended up with
 type Deployer interface {
-	Deploy(appName string, environment string, propertiesMap map[string]string, dryRun bool)
+	Deploy(ctx context.Context, request DeploymentRequest) (DeployResult, error)
+}
+
+// DeploymentRequest what to deploy and where
+type DeploymentRequest struct {
+	AppName     string
+	Environment string
+	Properties  map[string]string // key value pairs (helm --set style, dotted keys for nested values)
+	DryRun      bool
+}
+
+// DeployResult what a deployment landed on. Revisions are 0 when unknown (i.e. first install has
+// no previous revision, dry runs and manifests apps have no revisions)
+type DeployResult struct {
+	ReleaseName      string
+	Namespace        string
+	Revision         int
+	PreviousRevision int
+	Image            string
+	Duration         time.Duration
+}
+
+func (dr DeployResult) String() string {
+	return fmt.Sprintf("release: %s, namespace: %s, revision: %d (previous %d), image: %s, took %s",
+		dr.ReleaseName, dr.Namespace, dr.Revision, dr.PreviousRevision, dr.Image, dr.Duration)
 }
 
 type baseDeployer struct {
 	helmDeployer
 	chartsPath string
+	decrypter  encryption.Encrypter
+	// releaseName the release of a first install of an app, the app name when nil
+	releaseName func(appName string, environment string) string
 }
 
 func NewDeployerWithCharts(chartsPath string) Deployer {
@@ -28,19 +61,76 @@ func NewDeployer() Deployer {
 	return &baseDeployer{chartsPath: getBaseChartsPath()}
 }
 
+// NewDeployerWithDecrypter creates a Deployer able to use encrypted (.enc) values files
+// found next to the charts, decrypting them at deploy time with 'decrypter'
+func NewDeployerWithDecrypter(decrypter encryption.Encrypter) Deployer {
+	return &baseDeployer{chartsPath: getBaseChartsPath(), decrypter: decrypter}
+}
+
+// projectDeployer deploys each app with the deployer chosen for it in the project manifest
+type projectDeployer struct {
+	project   *manifest.Project
+	helm      Deployer
+	manifests Deployer
+}
+
+// NewProjectDeployer creates a Deployer that uses helm or plain manifests depending on the app,
+// as set in the project manifest
+func NewProjectDeployer(project *manifest.Project, decrypter encryption.Encrypter) Deployer {
+	convention := naming.MustFor(project.Name)
+	return &projectDeployer{
+		project: project,
+		helm: &baseDeployer{chartsPath: getBaseChartsPath(), decrypter: decrypter,
+			releaseName: func(appName string, environment string) string {
+				return convention.ReleaseName(project.Name, environment, appName)
+			}},
+		manifests: NewManifestsDeployer("", project, commandline.NewEmpty()),
+	}
+}
+
+func (pd *projectDeployer) Deploy(ctx context.Context, request DeploymentRequest) (DeployResult, error) {
+	if pd.project.DeployerFor(request.AppName) == manifest.DeployerManifests {
+		return pd.manifests.Deploy(ctx, request)
+	}
+	return pd.helm.Deploy(ctx, request)
+}
+
 // moneycolfrontend, moneycolserver, elasticsearch, traefik, traefik-dev, collections-api
-func (bd baseDeployer) Deploy(appName string, environment string, propertiesMap map[string]string, dryRun bool) {
+func (bd baseDeployer) Deploy(ctx context.Context, request DeploymentRequest) (DeployResult, error) {
 	helmSetParams := make(map[string]string)
-	addPropertiesToSetParams(helmSetParams, propertiesMap)
+	addPropertiesToSetParams(helmSetParams, request.Properties)
 	cmdRunner := commandline.NewEmpty()
-	helmDeployer := NewHelmDeployer(bd.chartsPath, cmdRunner, nil)
+	helmDeployer := NewHelmDeployer(bd.chartsPath, cmdRunner, nil).WithDecrypter(bd.decrypter)
 	deployment := HelmDeployment{
+		AppName:       request.AppName,
+		Environment:   request.Environment,
+		HelmSetParams: helmSetParams,
+		DryRun:        request.DryRun,
+	}
+	if bd.releaseName != nil {
+		deployment.NewReleaseName = bd.releaseName(request.AppName, request.Environment)
+	}
+	return helmDeployer.Deploy(ctx, &deployment)
+}
+
+// Validate runs the same checks done before deploying 'appName' with the deployer set for it in the
+// project manifest, without deploying anything. Helm apps are validated with their decrypted (.enc)
+// values (chart lint, values schema, unknown keys and values policies), manifests apps are rendered
+func Validate(project *manifest.Project, decrypter encryption.Encrypter, appName string, environment string,
+	propertiesMap map[string]string) *ValidationReport {
+	if project.DeployerFor(appName) == manifest.DeployerManifests {
+		md := &manifestsDeployer{manifestsPath: getBaseManifestsPath(), project: project, cmdRunner: commandline.NewEmpty()}
+		return md.Validate(appName, environment, propertiesMap)
+	}
+
+	helmSetParams := make(map[string]string)
+	addPropertiesToSetParams(helmSetParams, propertiesMap)
+	helmDeployer := NewHelmDeployer(getBaseChartsPath(), commandline.NewEmpty(), nil).WithDecrypter(decrypter)
+	return helmDeployer.ValidateApp(&HelmDeployment{
 		AppName:       appName,
 		Environment:   environment,
 		HelmSetParams: helmSetParams,
-		DryRun:        dryRun,
-	}
-	helmDeployer.Deploy(&deployment)
+	})
 }
 
 func addPropertiesToSetParams(helmSetParams map[string]string, propertiesMap map[string]string) {
@@ -51,6 +141,11 @@ func addPropertiesToSetParams(helmSetParams map[string]string, propertiesMap map
 	fmt.Printf("Helm Set Params %v", helmSetParams)
 }
 
+// ChartsPath the base path of the helm charts: CHARTS_PATH or the charts dir next to the executable
+func ChartsPath() string {
+	return getBaseChartsPath()
+}
+
 func getBaseChartsPath() string {
 	chartsPath := os.Getenv("CHARTS_PATH")
 	if chartsPath != "" {
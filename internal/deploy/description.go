@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const chartFile = "Chart.yaml"
+
+// DeploymentDescription what is being deployed for an app: chart version, image tag, a hash of
+// the values and the commit of the charts repository
+type DeploymentDescription struct {
+	ChartVersion string
+	ImageTag     string
+	ValuesHash   string
+	GitCommit    string
+}
+
+// Describe collects the description of deploying 'appName' with the given properties
+func Describe(appName string, propertiesMap map[string]string) (*DeploymentDescription, error) {
+	helmDeployer := NewHelmDeployer(getBaseChartsPath(), commandline.NewEmpty(), nil)
+	chartPath, err := helmDeployer.findChartForApp(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	chart, err := readYamlValues(filepath.Join(chartPath, chartFile))
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := deploymentValues(chartPath, &HelmDeployment{AppName: appName, HelmSetParams: propertiesMap})
+	if err != nil {
+		return nil, err
+	}
+
+	// json marshalling sorts map keys, so the same values always produce the same hash
+	valuesJson, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing values: %v", err)
+	}
+	valuesHash := sha256.Sum256(valuesJson)
+
+	return &DeploymentDescription{
+		ChartVersion: fmt.Sprintf("%v", chart["version"]),
+		ImageTag:     imageTagFromValues(values),
+		ValuesHash:   hex.EncodeToString(valuesHash[:]),
+		GitCommit:    gitCommit(chartPath),
+	}, nil
+}
+
+// deploymentValues the chart values merged with the values files and set params of a deployment
+func deploymentValues(chartPath string, deployment *HelmDeployment) (map[string]interface{}, error) {
+	values, err := readYamlValues(filepath.Join(chartPath, chartValuesFile))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, valuesFile := range deployment.HelmValuesParams {
+		fileValues, err := readYamlValues(valuesFile)
+		if err != nil {
+			return nil, err
+		}
+		util.MergeValues(values, fileValues)
+	}
+	util.MergeValues(values, setParamsToValues(deployment.HelmSetParams))
+	return values, nil
+}
+
+// imageFromValues the image (repository:tag) in the common chart layouts: image.repository and
+// image.tag or image: repository:tag
+func imageFromValues(values map[string]interface{}) string {
+	switch image := values["image"].(type) {
+	case map[string]interface{}:
+		repository, _ := image["repository"].(string)
+		if tag := imageTagFromValues(values); tag != "" {
+			return repository + ":" + tag
+		}
+		return repository
+	case string:
+		return image
+	}
+	return ""
+}
+
+// imageTagFromValues finds the image tag in the common chart layouts: image.tag or image: repo:tag
+func imageTagFromValues(values map[string]interface{}) string {
+	switch image := values["image"].(type) {
+	case map[string]interface{}:
+		if tag, ok := image["tag"]; ok {
+			return fmt.Sprintf("%v", tag)
+		}
+	case string:
+		if idx := strings.LastIndex(image, ":"); idx >= 0 {
+			return image[idx+1:]
+		}
+	}
+	return ""
+}
+
+func gitCommit(dir string) string {
+	cmd := commandline.NewWithWorkingDir("git", []string{"rev-parse", "--short", "HEAD"}, dir)
+	cmd.SetSuppressOutput(true)
+	output, err := cmd.RunWithError()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output.Output)
+}
@@ -0,0 +1,157 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const encryptedValuesSuffix = ".enc"
+
+// pendingDecryptedValues the decrypted values files not removed yet, so they are removed as well
+// when the process is interrupted (SIGINT/SIGTERM) in the middle of a deployment
+var pendingDecryptedValues = struct {
+	sync.Mutex
+	files         map[string]bool
+	handleSignals sync.Once
+}{files: make(map[string]bool)}
+
+// findEncryptedValuesFiles looks for values files encrypted with the 'crypt' command next to the chart.
+//
+// 'secrets.yaml.enc' applies to every environment while 'secrets.prod.yaml.enc' only applies to 'prod'.
+// The ones of every environment come first so the ones of the environment override them, as helm
+// gives precedence to the last --values file
+func findEncryptedValuesFiles(chartPath string, environment string) ([]string, error) {
+	candidates, err := filepath.Glob(filepath.Join(chartPath, "*"+encryptedValuesSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("error looking for encrypted values in %s: %v", chartPath, err)
+	}
+
+	var genericFiles, environmentFiles []string
+	for _, candidate := range candidates {
+		valuesFilename := strings.TrimSuffix(filepath.Base(candidate), encryptedValuesSuffix)
+		baseName := strings.TrimSuffix(valuesFilename, filepath.Ext(valuesFilename))
+		nameParts := strings.Split(baseName, ".")
+		fileEnvironment := ""
+		if len(nameParts) > 1 {
+			fileEnvironment = nameParts[len(nameParts)-1]
+		}
+
+		switch fileEnvironment {
+		case "":
+			genericFiles = append(genericFiles, candidate)
+		case environment:
+			environmentFiles = append(environmentFiles, candidate)
+		default:
+			log.Printf("Skipping encrypted values %s, they only apply to environment %s\n", candidate,
+				fileEnvironment)
+		}
+	}
+
+	sort.Strings(genericFiles)
+	sort.Strings(environmentFiles)
+	return append(genericFiles, environmentFiles...), nil
+}
+
+// decryptValuesFiles decrypts the encrypted values of the chart for the environment into
+// ephemeral files only readable by the current user. Callers must remove them with
+// removeDecryptedValues once helm has consumed them
+func (hd *helmDeployer) decryptValuesFiles(chartPath string, environment string) ([]string, error) {
+	encryptedFiles, err := findEncryptedValuesFiles(chartPath, environment)
+	if err != nil || len(encryptedFiles) == 0 {
+		return nil, err
+	}
+
+	if hd.decrypter == nil {
+		return nil, fmt.Errorf("found encrypted values %v but no decrypter is configured", encryptedFiles)
+	}
+
+	var decryptedFiles []string
+	for _, encryptedFile := range encryptedFiles {
+		cipherText, err := util.ReadFileToString(encryptedFile)
+		if err != nil {
+			removeDecryptedValues(decryptedFiles)
+			return nil, err
+		}
+
+		plainText, err := hd.decrypter.Decrypt(cipherText)
+		if err != nil {
+			removeDecryptedValues(decryptedFiles)
+			return nil, fmt.Errorf("error decrypting %s: %v", encryptedFile, err)
+		}
+
+		// TempFile creates the file with 0600 permissions
+		tmpFile, err := ioutil.TempFile("", "myiac-values-*.yaml")
+		if err != nil {
+			removeDecryptedValues(decryptedFiles)
+			return nil, fmt.Errorf("error creating ephemeral values file: %v", err)
+		}
+		decryptedFiles = append(decryptedFiles, tmpFile.Name())
+		trackDecryptedValues(tmpFile.Name())
+
+		_, writeErr := tmpFile.WriteString(plainText)
+		closeErr := tmpFile.Close()
+		if writeErr != nil || closeErr != nil {
+			removeDecryptedValues(decryptedFiles)
+			return nil, fmt.Errorf("error writing ephemeral values file for %s", encryptedFile)
+		}
+
+		log.Printf("Decrypted values from %s\n", encryptedFile)
+	}
+
+	return decryptedFiles, nil
+}
+
+func removeDecryptedValues(decryptedFiles []string) {
+	pendingDecryptedValues.Lock()
+	defer pendingDecryptedValues.Unlock()
+	for _, decryptedFile := range decryptedFiles {
+		if err := os.Remove(decryptedFile); err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARN] could not remove decrypted values file %s: %v", decryptedFile, err)
+		}
+		delete(pendingDecryptedValues.files, decryptedFile)
+	}
+}
+
+// trackDecryptedValues registers a decrypted values file to remove if the process is interrupted
+// before removeDecryptedValues runs
+func trackDecryptedValues(decryptedFile string) {
+	pendingDecryptedValues.handleSignals.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			removePendingDecryptedValues()
+			// let the signal terminate the process as it would have without this handler
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			if process, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = process.Signal(sig)
+			}
+		}()
+	})
+
+	pendingDecryptedValues.Lock()
+	defer pendingDecryptedValues.Unlock()
+	pendingDecryptedValues.files[decryptedFile] = true
+}
+
+func removePendingDecryptedValues() {
+	pendingDecryptedValues.Lock()
+	var decryptedFiles []string
+	for decryptedFile := range pendingDecryptedValues.files {
+		decryptedFiles = append(decryptedFiles, decryptedFile)
+	}
+	pendingDecryptedValues.Unlock()
+
+	log.Printf("Interrupted, removing %d decrypted values files\n", len(decryptedFiles))
+	removeDecryptedValues(decryptedFiles)
+}
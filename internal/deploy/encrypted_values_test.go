@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reverseDecrypter a fake encrypter where the cipherText is the reversed plainText
+type reverseDecrypter struct{}
+
+func (rd reverseDecrypter) Encrypt(plainText string) (string, error) {
+	return reverse(plainText), nil
+}
+
+func (rd reverseDecrypter) Decrypt(cipherText string) (string, error) {
+	return reverse(cipherText), nil
+}
+
+func reverse(text string) string {
+	runes := []rune(text)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func createChartWithEncryptedValues(t *testing.T) string {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatalf("error creating chart dir %v", err)
+	}
+	for _, name := range []string{"secrets.yaml.enc", "secrets.dev.yaml.enc", "secrets.prod.yaml.enc"} {
+		_ = ioutil.WriteFile(filepath.Join(chartPath, name), []byte(reverse("password: "+name)), 0644)
+	}
+	return chartPath
+}
+
+func TestFindsEncryptedValuesForEnvironment(t *testing.T) {
+	chartPath := createChartWithEncryptedValues(t)
+	defer os.RemoveAll(chartPath)
+
+	files, err := findEncryptedValuesFiles(chartPath, "dev")
+
+	assert.Nil(t, err)
+	// the values of the environment go last to override the ones of every environment
+	assert.Equal(t, []string{
+		filepath.Join(chartPath, "secrets.yaml.enc"),
+		filepath.Join(chartPath, "secrets.dev.yaml.enc"),
+	}, files)
+}
+
+func TestDecryptsValuesIntoEphemeralFiles(t *testing.T) {
+	chartPath := createChartWithEncryptedValues(t)
+	defer os.RemoveAll(chartPath)
+	d := NewHelmDeployer("charts", &mockCommandRunner{}, nil).WithDecrypter(reverseDecrypter{})
+
+	decryptedFiles, err := d.decryptValuesFiles(chartPath, "prod")
+
+	assert.Nil(t, err)
+	assert.Len(t, decryptedFiles, 2)
+	for _, decryptedFile := range decryptedFiles {
+		assert.False(t, strings.HasPrefix(decryptedFile, chartPath))
+		info, _ := os.Stat(decryptedFile)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		content, _ := ioutil.ReadFile(decryptedFile)
+		assert.True(t, strings.HasPrefix(string(content), "password: secrets."))
+	}
+
+	removeDecryptedValues(decryptedFiles)
+	for _, decryptedFile := range decryptedFiles {
+		assert.NoFileExists(t, decryptedFile)
+	}
+}
+
+func TestEncryptedValuesRequireDecrypter(t *testing.T) {
+	chartPath := createChartWithEncryptedValues(t)
+	defer os.RemoveAll(chartPath)
+	d := NewHelmDeployer("charts", &mockCommandRunner{}, nil)
+
+	_, err := d.decryptValuesFiles(chartPath, "dev")
+
+	assert.NotNil(t, err)
+}
+
+func TestPendingDecryptedValuesAreRemovedOnInterrupt(t *testing.T) {
+	chartPath := createChartWithEncryptedValues(t)
+	defer os.RemoveAll(chartPath)
+	d := NewHelmDeployer("charts", &mockCommandRunner{}, nil).WithDecrypter(reverseDecrypter{})
+	decryptedFiles, _ := d.decryptValuesFiles(chartPath, "prod")
+
+	removePendingDecryptedValues()
+
+	for _, decryptedFile := range decryptedFiles {
+		assert.NoFileExists(t, decryptedFile)
+	}
+	assert.Empty(t, pendingDecryptedValues.files)
+}
@@ -1,13 +1,17 @@
 package deploy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/encryption"
 	"github.com/iac-io/myiac/internal/util"
 	"io/ioutil"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //https://quii.gitbook.io/learn-go-with-tests/go-fundamentals/mocking
@@ -62,7 +66,7 @@ func (fr *fileReader) ReadDir(dir string) ([]NamedFile, error) {
 		return nil, err
 	}
 
-	var namedFileInfos = make([]NamedFile, len(fileInfos))
+	var namedFileInfos = make([]NamedFile, 0, len(fileInfos))
 	for _, fileInfo := range fileInfos {
 		namedFileInfo := namedFile{
 			name: fileInfo.Name(),
@@ -73,9 +77,11 @@ func (fr *fileReader) ReadDir(dir string) ([]NamedFile, error) {
 }
 
 type helmDeployer struct {
-	cmdRunner  commandline.CommandRunner
-	chartsPath string
-	fileReader FileReader
+	cmdRunner      commandline.CommandRunner
+	chartsPath     string
+	fileReader     FileReader
+	valuesPolicies []ValuesPolicy
+	decrypter      encryption.Encrypter
 }
 
 type HelmDeployment struct {
@@ -84,12 +90,15 @@ type HelmDeployment struct {
 	Environment      string
 	HelmSetParams    map[string]string // key value pairs
 	HelmValuesParams []string          // yaml filenames to pass as --values
+	ReleaseName      string            // explicit release to install/upgrade, found from AppName otherwise
+	NewReleaseName   string            // release of a first install, AppName when empty
 }
 
 func NewHelmDeployer(chartsPath string, commandRunner commandline.CommandRunner, outFileReader FileReader) *helmDeployer {
 	hd := new(helmDeployer)
 	hd.cmdRunner = commandRunner
 	hd.chartsPath = chartsPath
+	hd.valuesPolicies = DefaultValuesPolicies()
 
 	
 	if outFileReader != nil {
@@ -103,84 +112,109 @@ func NewHelmDeployer(chartsPath string, commandRunner commandline.CommandRunner,
 	return hd
 }
 
-func (hd *helmDeployer) DeployedReleasesExistsFor(appName string) bool {
-	return hd.ReleaseFor(appName) != ""
+func (hd *helmDeployer) DeployedReleasesExistsFor(appName string) (bool, error) {
+	releaseName, err := hd.ReleaseFor(appName)
+	return releaseName != "", err
 }
 
-func (hd *helmDeployer) ReleaseFor(appName string) string {
-	releasesList := hd.ListReleases()
-
-	fmt.Println("Cleaning up FAILED releases")
-	hd.DeleteFailedReleases()
+func (hd *helmDeployer) ReleaseFor(appName string) (string, error) {
+	releasesList, err := hd.ListReleases()
+	if err != nil {
+		return "", err
+	}
 
 	for _, release := range releasesList {
-		appNameIsPartOfChart := strings.Contains(strings.ToLower(release.Chart), appName)
-		if appNameIsPartOfChart && release.Status == "deployed" {
+		if isReleaseOfApp(release, appName) && release.Status == "deployed" && !isCandidateRelease(release.Name) {
 			// It exists with the given name
 			fmt.Printf("Release for app %s found. "+
 				"Name: %s, Status %s, Chart: %s\n",
 				appName, release.Name, release.Status, release.Chart)
-			return release.Name
+			return release.Name, nil
 		}
 	}
 	fmt.Printf("No releases found for app %s\n", appName)
-	return ""
+	return "", nil
 }
 
-func (hd *helmDeployer) ListReleases() []*Release {
-	cmdArgs := "list %s %s"
-	argsArray := util.StringTemplateToArgsArray(cmdArgs, "--output", "json")
-	hd.cmdRunner.Setup("helm", argsArray)
-	hd.cmdRunner.RunVoid()
-	cmdOutputJson := hd.cmdRunner.Output()
-	listReleases := hd.ParseReleasesList(cmdOutputJson)
-	return listReleases
+// findRelease the release named 'releaseName' (whatever its status), nil if there's none
+func (hd *helmDeployer) findRelease(releaseName string) (*Release, error) {
+	releasesList, err := hd.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, release := range releasesList {
+		if release.Name == releaseName {
+			return release, nil
+		}
+	}
+	return nil, nil
 }
 
-func (hd *helmDeployer) DeleteFailedReleases() {
-	releasesList := hd.ListReleases()
+// checkNoStuckReleases fails when the release to deploy, or any other release of the app's chart, is failed
+// or pending, as helm won't deploy over them until they are repaired
+func (hd *helmDeployer) checkNoStuckReleases(deployment *HelmDeployment) error {
+	releaseName := deployment.AppName
+	if deployment.ReleaseName != "" {
+		releaseName = deployment.ReleaseName
+	} else if deployment.NewReleaseName != "" {
+		releaseName = deployment.NewReleaseName
+	}
+
+	releasesList, err := hd.ListReleases()
+	if err != nil {
+		return err
+	}
+
 	for _, release := range releasesList {
-		if release.Status == "FAILED" {
-			fmt.Printf("Deleting FAILED Helm release -> %s\n", release.Name)
-			deleteHelmRelease(hd, release.Name)
+		isTargeted := release.Name == releaseName || isReleaseOfApp(release, deployment.AppName)
+		if isTargeted && isStuckStatus(release.Status) {
+			return fmt.Errorf("release %s is %s, run 'myiac releases repair' before deploying",
+				release.Name, release.Status)
 		}
 	}
+	return nil
 }
 
-func deleteHelmRelease(hd *helmDeployer, releaseName string) {
-	cmdArgs := "delete %s"
-	argsArray := util.StringTemplateToArgsArray(cmdArgs, releaseName)
-	hd.cmdRunner.IgnoreError(true)
+// isReleaseOfApp tells if the release was installed from the chart of the app
+func isReleaseOfApp(release *Release, appName string) bool {
+	return strings.Contains(strings.ToLower(release.Chart), appName)
+}
+
+func (hd *helmDeployer) ListReleases() ([]*Release, error) {
+	// --all so pending releases are listed too
+	cmdArgs := "list %s %s %s"
+	argsArray := util.StringTemplateToArgsArray(cmdArgs, "--all", "--output", "json")
 	hd.cmdRunner.Setup("helm", argsArray)
-	hd.cmdRunner.RunVoid()
-	hd.cmdRunner.IgnoreError(false)
+	cmdOutput, err := hd.cmdRunner.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error listing helm releases: %v", err)
+	}
+	return hd.ParseReleasesList(cmdOutput.Output)
 }
 
-func (hd *helmDeployer) ParseReleasesList(jsonString string) []*Release {
+func (hd *helmDeployer) ParseReleasesList(jsonString string) ([]*Release, error) {
 	var listReleases []*Release
 
 	// If there is no releases, a single space is returned
 	if jsonString == "" || len(strings.TrimSpace(jsonString)) == 0 {
 		// empty releases list
 		log.Printf("Empty list of releases found")
-		listReleases = []*Release{}
-	} else {
-		jsonData := []byte(jsonString)
-		err := json.Unmarshal(jsonData, &listReleases)
-		if err != nil {
-			log.Fatalf("Error parsing json to struct %v %v", jsonData, err)
-		}
+		return []*Release{}, nil
 	}
 
-	return listReleases
+	if err := json.Unmarshal([]byte(jsonString), &listReleases); err != nil {
+		return nil, fmt.Errorf("error parsing helm releases %s: %v", jsonString, err)
+	}
+	return listReleases, nil
 }
 
-func (hd *helmDeployer) findChartForApp(appName string) string {
+func (hd *helmDeployer) findChartForApp(appName string) (string, error) {
 
 	files, err := hd.fileReader.ReadDir(getBaseChartsPath())
 	
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("error reading charts path %s: %v", getBaseChartsPath(), err)
 	}
 
 	for _, file := range files {
@@ -193,56 +227,148 @@ func (hd *helmDeployer) findChartForApp(appName string) string {
 
 		if appNameNormalized == chartFolderNormalized {
 			fmt.Printf("Found chart for app [%s] -> [%s]\n", appNameNormalized, chartFolderNormalized)
-			return getBaseChartsPath() + "/" + chartFolder
+			return getBaseChartsPath() + "/" + chartFolder, nil
 		}
 	}
 
-	log.Fatalf("Could not find chart for app %s in path %s\n", appName, getBaseChartsPath())
-	return ""
+	return "", fmt.Errorf("could not find chart for app %s in path %s", appName, getBaseChartsPath())
+}
+
+func (hd *helmDeployer) Deploy(ctx context.Context, helmDeployment *HelmDeployment) (DeployResult, error) {
+	startedAt := time.Now()
+	chartPathForApp, err := hd.findChartForApp(helmDeployment.AppName)
+	if err != nil {
+		return DeployResult{}, err
+	}
+
+	decryptedValues, err := hd.decryptValuesFiles(chartPathForApp, helmDeployment.Environment)
+	if err != nil {
+		return DeployResult{}, fmt.Errorf("could not decrypt values for app %s: %v", helmDeployment.AppName, err)
+	}
+
+	// decrypted values must not outlive the deployment, interruptions remove them too (see trackDecryptedValues)
+	defer removeDecryptedValues(decryptedValues)
+
+	result, err := hd.deployChart(ctx, chartPathForApp, helmDeployment, decryptedValues)
+	result.Duration = time.Since(startedAt).Round(time.Millisecond)
+
+	if err != nil {
+		return result, fmt.Errorf("error deploying app %s: %v", helmDeployment.AppName, err)
+	}
+	fmt.Printf("Finished deploying app: %s (%s)\n\n", helmDeployment.AppName, result)
+	return result, nil
 }
 
-func (hd *helmDeployer) Deploy(helmDeployment *HelmDeployment) {
+func (hd *helmDeployer) deployChart(ctx context.Context, chartPathForApp string, helmDeployment *HelmDeployment,
+	extraValues []string) (DeployResult, error) {
+	deployment := *helmDeployment
+	deployment.HelmValuesParams = append(append([]string{}, helmDeployment.HelmValuesParams...), extraValues...)
+	result := DeployResult{}
+
+	if err := hd.checkNoStuckReleases(&deployment); err != nil {
+		return result, err
+	}
+
+	report := hd.Validate(chartPathForApp, &deployment)
+	report.Print()
+	if report.HasErrors() {
+		return result, fmt.Errorf("validation failed for app %s in %s, not deploying", deployment.AppName, deployment.Environment)
+	}
+
+	if values, err := deploymentValues(chartPathForApp, &deployment); err == nil {
+		result.Image = imageFromValues(values)
+	}
+
 	var helmArgs = ""
-	var chartPathForApp = hd.findChartForApp(helmDeployment.AppName)
-	var existingRelease = hd.ReleaseFor(helmDeployment.AppName)
+	var existingRelease *Release
+	result.ReleaseName = deployment.AppName
+	if deployment.NewReleaseName != "" {
+		result.ReleaseName = deployment.NewReleaseName
+	}
+	var err error
+	if deployment.ReleaseName != "" {
+		result.ReleaseName = deployment.ReleaseName
+		if existingRelease, err = hd.findRelease(deployment.ReleaseName); err != nil {
+			return result, err
+		}
+	} else {
+		releaseName, err := hd.ReleaseFor(deployment.AppName)
+		if err != nil {
+			return result, err
+		}
+		if releaseName != "" {
+			if existingRelease, err = hd.findRelease(releaseName); err != nil {
+				return result, err
+			}
+		}
+	}
+
 	var action = "install %s"
-	if existingRelease != "" {
-		action = fmt.Sprintf("upgrade %s", existingRelease)
+	if existingRelease != nil {
+		result.ReleaseName = existingRelease.Name
+		result.PreviousRevision, _ = strconv.Atoi(existingRelease.Revision)
+		action = fmt.Sprintf("upgrade %s", existingRelease.Name)
 	} else {
 		// in helm 3, install requires release name
-		action = fmt.Sprintf(action, helmDeployment.AppName)
+		action = fmt.Sprintf(action, result.ReleaseName)
 	}
 
 	helmArgs = fmt.Sprintf("%s %s", helmArgs, action)
 	helmArgs = fmt.Sprintf("%s %s", helmArgs, chartPathForApp)
 
-	if len(helmDeployment.HelmValuesParams) > 0 {
+	if len(deployment.HelmValuesParams) > 0 {
 		valuesParams := ""
-		for _, filePath := range helmDeployment.HelmValuesParams {
-			valuesParams += valuesParams + "--values " + filePath + " "
+		for _, filePath := range deployment.HelmValuesParams {
+			valuesParams += "--values " + filePath + " "
 		}
 		valuesParams = strings.TrimSpace(valuesParams)
 		helmArgs = fmt.Sprintf("%s %s", helmArgs, valuesParams)
 	}
 
-	if len(helmDeployment.HelmSetParams) > 0 {
+	if len(deployment.HelmSetParams) > 0 {
 		setParams := ""
-		for k, v := range helmDeployment.HelmSetParams {
-			setParams += setParams + "--set " + k + "=" + v + " "
+		for k, v := range deployment.HelmSetParams {
+			setParams += "--set " + k + "=" + v + " "
 		}
 		setParams = strings.TrimSpace(setParams)
 		helmArgs = fmt.Sprintf("%s %s", helmArgs, setParams)
 	}
 
-	if helmDeployment.DryRun {
+	if deployment.DryRun {
 		helmArgs += " --debug --dry-run"
 	}
 
+	// last chance to stop before changing anything in the cluster
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
 	argsArray := strings.Fields(helmArgs)
 	//cmd := commandline.New("helm", argsArray)
 	hd.cmdRunner.Setup("helm", argsArray)
-	hd.cmdRunner.Run()
-	fmt.Printf("Finished deploying app: %s\n\n", helmDeployment.AppName)
+	if _, err := hd.cmdRunner.RunWithError(); err != nil {
+		return result, err
+	}
+
+	if deployment.DryRun {
+		return result, nil
+	}
+
+	if deployed, err := hd.findRelease(result.ReleaseName); err != nil {
+		log.Printf("[WARN] could not read release %s after deploying, revision unknown: %v", result.ReleaseName, err)
+	} else if deployed != nil {
+		result.Namespace = deployed.Namespace
+		result.Revision, _ = strconv.Atoi(deployed.Revision)
+	} else {
+		log.Printf("[WARN] release %s not found after deploying, revision unknown", result.ReleaseName)
+	}
+	return result, nil
+}
+
+// WithDecrypter sets the decrypter used for encrypted (.enc) values files found next to the chart
+func (hd *helmDeployer) WithDecrypter(decrypter encryption.Encrypter) *helmDeployer {
+	hd.decrypter = decrypter
+	return hd
 }
 
 func normalizeName(str string) string {
@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/iac-io/myiac/internal/commandline"
@@ -54,6 +55,10 @@ func (mcr mockCommandRunner) Run() commandline.CommandOutput {
 	return commandline.CommandOutput{Output: mcr.output}
 }
 
+func (mcr mockCommandRunner) RunWithError() (commandline.CommandOutput, error) {
+	return mcr.Run(), nil
+}
+
 func (mcr mockCommandRunner) SetupCmdLine(cmdLine string) {
 	// ignored
 }
@@ -64,8 +69,8 @@ func TestReleaseDeployed(t *testing.T) {
 	commandRunner := &mockCommandRunner{output: ExistingReleasesOutput}
 	d := NewHelmDeployer("charts", commandRunner, nil)
 
-	if !d.DeployedReleasesExistsFor("elastic") {
-		t.Errorf("The release is deployed was incorrect, got: %v, want: %v.", false, true)
+	if deployed, err := d.DeployedReleasesExistsFor("elastic"); err != nil || !deployed {
+		t.Errorf("The release is deployed was incorrect, got: %v (%v), want: %v.", deployed, err, true)
 	}
 }
 
@@ -75,7 +80,10 @@ func TestReleaseHasFailed(t *testing.T) {
 
 	// Given: a release (2nd one) has failed status
 
-	releasesList := d.ParseReleasesList(ExistingReleasesOutput)
+	releasesList, err := d.ParseReleasesList(ExistingReleasesOutput)
+	if err != nil {
+		t.Errorf("Failure: error parsing releases %v\n", err)
+	}
 	release := releasesList[1]
 	release.Status = "failed"
 
@@ -88,7 +96,7 @@ func TestReleaseHasFailed(t *testing.T) {
 	commandRunner.SetOutput(string(existingReleasesModified))
 
 	// When: checking if it has been deployed
-	deployed := d.DeployedReleasesExistsFor("startup-daemonset")
+	deployed, _ := d.DeployedReleasesExistsFor("startup-daemonset")
 
 	// Then: it shouldn't be deployed but failed
 	if deployed {
@@ -129,8 +137,42 @@ func TestHelm3RequiresNameForInstall(t *testing.T) {
 	
 
 	// when deploying it
-	d.Deploy(&HelmDeployment{AppName: "app", DryRun: false, Environment: "dev"})
+	result, err := d.Deploy(context.Background(), &HelmDeployment{AppName: "app", DryRun: false, Environment: "dev"})
 
-	// it works...
+	// it's installed with the app name as release
+	if err != nil {
+		t.Errorf("Failure: error deploying %v\n", err)
+	}
+	if result.ReleaseName != "app" || result.PreviousRevision != 0 {
+		t.Errorf("Expected new release 'app' without previous revision, got %v\n", result)
+	}
 	fmt.Println(commandRunner.Output())
 }
+
+func TestDeployIsNotStartedWhenCancelled(t *testing.T) {
+	commandRunner := &mockCommandRunner{output: ExistingReleasesOutput}
+	d := NewHelmDeployer("charts", commandRunner, new(mockFileReader))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.Deploy(ctx, &HelmDeployment{AppName: "app", Environment: "dev"})
+
+	if err == nil {
+		t.Errorf("Expected cancelled deployment to fail\n")
+	}
+}
+
+func TestFirstInstallUsesTheNewReleaseName(t *testing.T) {
+	commandRunner := &mockCommandRunner{output: ExistingReleasesOutput}
+	d := NewHelmDeployer("charts", commandRunner, new(mockFileReader))
+
+	result, err := d.Deploy(context.Background(),
+		&HelmDeployment{AppName: "app", Environment: "dev", NewReleaseName: "app-dev"})
+
+	if err != nil {
+		t.Errorf("Failure: error deploying %v\n", err)
+	}
+	if result.ReleaseName != "app-dev" {
+		t.Errorf("Expected new release 'app-dev', got %v\n", result)
+	}
+}
@@ -0,0 +1,349 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/internal/util"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	manifestsBaseDir     = "base"
+	manifestsOverlaysDir = "overlays"
+	overlayFile          = "overlay.yaml"
+
+	// AppLabel and EnvironmentLabel mark the resources owned by an app in an environment, so the ones
+	// removed from its manifests are pruned without touching other environments sharing the namespace
+	AppLabel         = "myiac.io/app"
+	EnvironmentLabel = "myiac.io/environment"
+	managedByLabel   = "app.kubernetes.io/managed-by"
+)
+
+// Overlay per environment customization of the base manifests (overlays/<env>/overlay.yaml)
+//
+//	namePrefix: dev-
+//	patches:
+//	- deployment-replicas.yaml
+type Overlay struct {
+	NamePrefix string   `yaml:"namePrefix"`
+	Patches    []string `yaml:"patches"`
+}
+
+// manifestsDeployer applies a directory of plain Kubernetes manifests: base/ plus the overlay of
+// the environment. Manifests are Go templates rendered with the project manifest values
+type manifestsDeployer struct {
+	manifestsPath string
+	project       *manifest.Project
+	cmdRunner     commandline.CommandRunner
+}
+
+// NewManifestsDeployer creates a Deployer for apps defined as manifests in 'manifestsPath'
+// (MANIFESTS_PATH by default)
+func NewManifestsDeployer(manifestsPath string, project *manifest.Project, cmdRunner commandline.CommandRunner) Deployer {
+	if manifestsPath == "" {
+		manifestsPath = getBaseManifestsPath()
+	}
+	return &manifestsDeployer{manifestsPath: manifestsPath, project: project, cmdRunner: cmdRunner}
+}
+
+// Deploy applies the rendered manifests. There are no releases with manifests, so the result only
+// has the app as release name and the image of its first container
+func (md *manifestsDeployer) Deploy(ctx context.Context, request DeploymentRequest) (DeployResult, error) {
+	startedAt := time.Now()
+	result := DeployResult{ReleaseName: request.AppName}
+
+	resources, err := md.renderResources(request.AppName, request.Environment, request.Properties)
+	if err != nil {
+		return result, fmt.Errorf("error rendering manifests of %s: %v", request.AppName, err)
+	}
+	result.Image = firstContainerImage(resources)
+
+	rendered, err := serializeResources(resources)
+	if err != nil {
+		return result, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	err = md.apply(request.AppName, request.Environment, rendered, request.DryRun)
+	result.Duration = time.Since(startedAt).Round(time.Millisecond)
+	if err != nil {
+		return result, fmt.Errorf("error deploying manifests of %s: %v", request.AppName, err)
+	}
+	return result, nil
+}
+
+// Render produces the manifests of 'appName' for 'environment', ready to apply
+func (md *manifestsDeployer) Render(appName string, environment string, propertiesMap map[string]string) (string, error) {
+	resources, err := md.renderResources(appName, environment, propertiesMap)
+	if err != nil {
+		return "", err
+	}
+	return serializeResources(resources)
+}
+
+// Validate checks the manifests of 'appName' render for 'environment' into resources kubectl can apply
+func (md *manifestsDeployer) Validate(appName string, environment string, propertiesMap map[string]string) *ValidationReport {
+	report := &ValidationReport{AppName: appName, Environment: environment}
+	resources, err := md.renderResources(appName, environment, propertiesMap)
+	if err != nil {
+		report.addError("render", err.Error())
+		return report
+	}
+
+	for i, resource := range resources {
+		metadata, _ := resource["metadata"].(map[string]interface{})
+		if resource["apiVersion"] == nil || resource["kind"] == nil || metadata["name"] == nil {
+			report.addError("resource", fmt.Sprintf("resource %d of %s has no apiVersion, kind or metadata.name", i+1, appName))
+		}
+	}
+	return report
+}
+
+func (md *manifestsDeployer) renderResources(appName string, environment string,
+	propertiesMap map[string]string) ([]map[string]interface{}, error) {
+	appPath := filepath.Join(md.manifestsPath, md.project.ManifestsPathFor(appName))
+
+	values := md.project.ValuesFor(environment)
+	util.MergeValues(values, setParamsToValues(propertiesMap))
+	templateData := map[string]interface{}{
+		"Values":      values,
+		"Project":     md.project.Name,
+		"Environment": environment,
+		"App":         appName,
+	}
+
+	resources, err := readManifestsDir(filepath.Join(appPath, manifestsBaseDir), templateData)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no manifests found in %s", filepath.Join(appPath, manifestsBaseDir))
+	}
+
+	overlayPath := filepath.Join(appPath, manifestsOverlaysDir, environment)
+	overlay, err := readOverlay(overlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patchFile := range overlay.Patches {
+		patches, err := readManifestsFile(filepath.Join(overlayPath, patchFile), templateData)
+		if err != nil {
+			return nil, err
+		}
+		for _, patch := range patches {
+			if err := applyPatch(resources, patch); err != nil {
+				return nil, fmt.Errorf("error applying patch %s: %v", patchFile, err)
+			}
+		}
+	}
+
+	for _, resource := range resources {
+		setResourceMetadata(resource, overlay.NamePrefix, appName, environment)
+	}
+	return resources, nil
+}
+
+func serializeResources(resources []map[string]interface{}) (string, error) {
+	var rendered bytes.Buffer
+	for _, resource := range resources {
+		resourceYaml, err := yaml.Marshal(resource)
+		if err != nil {
+			return "", fmt.Errorf("error serializing %s: %v", resourceId(resource), err)
+		}
+		rendered.WriteString("---\n")
+		rendered.Write(resourceYaml)
+	}
+	return rendered.String(), nil
+}
+
+// firstContainerImage the image of the first container found in the resources, in order
+func firstContainerImage(resources []map[string]interface{}) string {
+	for _, resource := range resources {
+		image := ""
+		walkValues("", resource, func(path string, value interface{}) {
+			if image == "" && strings.Contains(path, "containers[0]") && strings.HasSuffix(path, ".image") {
+				image, _ = value.(string)
+			}
+		})
+		if image != "" {
+			return image
+		}
+	}
+	return ""
+}
+
+// apply runs kubectl apply pruning the resources of the app in the environment that are no longer
+// in its manifests
+func (md *manifestsDeployer) apply(appName string, environment string, rendered string, dryRun bool) error {
+	manifestFile, err := ioutil.TempFile("", appName+"-*.yaml")
+	if err != nil {
+		return fmt.Errorf("error creating manifests file: %v", err)
+	}
+	defer os.Remove(manifestFile.Name())
+
+	if _, err := manifestFile.WriteString(rendered); err != nil {
+		return fmt.Errorf("error writing manifests file: %v", err)
+	}
+	_ = manifestFile.Close()
+
+	args := []string{"apply", "-f", manifestFile.Name(), "--prune", "-l", pruneSelector(appName, environment)}
+	if dryRun {
+		fmt.Printf("Manifests of %s:\n%s\n", appName, rendered)
+		args = append(args, "--dry-run=client")
+	}
+
+	md.cmdRunner.Setup("kubectl", args)
+	_, err = md.cmdRunner.RunWithError()
+	return err
+}
+
+// readManifestsDir reads and renders all the yaml files in 'dir', in name order
+func readManifestsDir(dir string, templateData map[string]interface{}) ([]map[string]interface{}, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifests directory %s: %v", dir, err)
+	}
+
+	var fileNames []string
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if !file.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			fileNames = append(fileNames, file.Name())
+		}
+	}
+	sort.Strings(fileNames)
+
+	var resources []map[string]interface{}
+	for _, fileName := range fileNames {
+		fileResources, err := readManifestsFile(filepath.Join(dir, fileName), templateData)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, fileResources...)
+	}
+	return resources, nil
+}
+
+// readManifestsFile renders a (multi document) yaml file as a template and parses its resources
+func readManifestsFile(filePath string, templateData map[string]interface{}) ([]map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest %s: %v", filePath, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(filePath)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", filePath, err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, templateData); err != nil {
+		return nil, fmt.Errorf("error rendering %s: %v", filePath, err)
+	}
+
+	var resources []map[string]interface{}
+	decoder := yaml.NewDecoder(&rendered)
+	for {
+		var raw map[interface{}]interface{}
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml in %s: %v", filePath, err)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		resources = append(resources, util.NormalizeYaml(raw).(map[string]interface{}))
+	}
+	return resources, nil
+}
+
+func readOverlay(overlayPath string) (*Overlay, error) {
+	overlay := &Overlay{}
+	overlayFilePath := filepath.Join(overlayPath, overlayFile)
+	if !util.FileExists(overlayFilePath) {
+		return overlay, nil
+	}
+
+	content, err := ioutil.ReadFile(overlayFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading overlay %s: %v", overlayFilePath, err)
+	}
+	if err := yaml.Unmarshal(content, overlay); err != nil {
+		return nil, fmt.Errorf("invalid overlay %s: %v", overlayFilePath, err)
+	}
+	return overlay, nil
+}
+
+// applyPatch merges 'patch' into the resource with the same kind and name
+func applyPatch(resources []map[string]interface{}, patch map[string]interface{}) error {
+	for _, resource := range resources {
+		if resource["kind"] == patch["kind"] && resourceName(resource) == resourceName(patch) {
+			util.MergeValues(resource, patch)
+			return nil
+		}
+	}
+	return fmt.Errorf("no resource %s to patch", resourceId(patch))
+}
+
+// pruneSelector the resources of 'appName' in 'environment'
+func pruneSelector(appName string, environment string) string {
+	return fmt.Sprintf("%s=%s,%s=%s", AppLabel, appName, EnvironmentLabel, environment)
+}
+
+func setResourceMetadata(resource map[string]interface{}, namePrefix string, appName string, environment string) {
+	metadata, ok := resource["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = make(map[string]interface{})
+		resource["metadata"] = metadata
+	}
+
+	if namePrefix != "" {
+		metadata["name"] = namePrefix + resourceName(resource)
+	}
+
+	labels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		labels = make(map[string]interface{})
+		metadata["labels"] = labels
+	}
+	labels[AppLabel] = appName
+	labels[EnvironmentLabel] = environment
+	labels[managedByLabel] = "myiac"
+}
+
+func resourceName(resource map[string]interface{}) string {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+func resourceId(resource map[string]interface{}) string {
+	return fmt.Sprintf("%v/%s", resource["kind"], resourceName(resource))
+}
+
+func getBaseManifestsPath() string {
+	manifestsPath := os.Getenv("MANIFESTS_PATH")
+	if manifestsPath != "" {
+		return manifestsPath
+	}
+	return util.CurrentExecutableDir() + "/manifests"
+}
@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const baseDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: external-dns
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: external-dns
+        args:
+        - --domain-filter={{ .Values.domain }}
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: external-dns
+`
+
+func writeManifestFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatalf("error creating manifests dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("error writing %s %v", name, err)
+		}
+	}
+	return dir
+}
+
+func testManifestsDeployer(manifestsPath string) *manifestsDeployer {
+	project := &manifest.Project{
+		Name:   "moneycol",
+		Values: map[string]interface{}{"domain": "moneycol.net"},
+	}
+	return NewManifestsDeployer(manifestsPath, project, testutil.FakeCommandRunner("")).(*manifestsDeployer)
+}
+
+func TestRendersBaseWithProjectValuesAndAppLabel(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"dns/base/deployment.yaml": baseDeployment,
+	})
+
+	rendered, err := testManifestsDeployer(manifestsPath).Render("dns", "dev", nil)
+
+	assert.Nil(t, err)
+	assert.Contains(t, rendered, "--domain-filter=moneycol.net")
+	assert.Contains(t, rendered, "myiac.io/app: dns")
+	assert.Contains(t, rendered, "myiac.io/environment: dev")
+	assert.Contains(t, rendered, "kind: ServiceAccount")
+}
+
+func TestRendersOverlayPatchesAndNamePrefix(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"dns/base/deployment.yaml":        baseDeployment,
+		"dns/overlays/prod/overlay.yaml":  "namePrefix: prod-\npatches:\n- replicas.yaml\n",
+		"dns/overlays/prod/replicas.yaml": "kind: Deployment\nmetadata:\n  name: external-dns\nspec:\n  replicas: 3\n",
+	})
+
+	rendered, err := testManifestsDeployer(manifestsPath).Render("dns", "prod", map[string]string{"domain": "moneycol.com"})
+
+	assert.Nil(t, err)
+	assert.Contains(t, rendered, "name: prod-external-dns")
+	assert.Contains(t, rendered, "replicas: 3")
+	assert.Contains(t, rendered, "--domain-filter=moneycol.com")
+}
+
+func TestPatchOfMissingResourceFails(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"dns/base/deployment.yaml":       baseDeployment,
+		"dns/overlays/prod/overlay.yaml": "patches:\n- missing.yaml\n",
+		"dns/overlays/prod/missing.yaml": "kind: Service\nmetadata:\n  name: external-dns\n",
+	})
+
+	_, err := testManifestsDeployer(manifestsPath).Render("dns", "prod", nil)
+
+	assert.NotNil(t, err)
+}
+
+func TestDeployReportsContainerImage(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"dns/base/deployment.yaml": baseDeployment + "---\nkind: Deployment\nmetadata:\n  name: other\nspec:\n" +
+			"  template:\n    spec:\n      containers:\n      - image: other:1.0\n",
+	})
+
+	result, err := testManifestsDeployer(manifestsPath).Deploy(context.Background(),
+		DeploymentRequest{AppName: "dns", Environment: "dev", DryRun: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "dns", result.ReleaseName)
+	assert.Equal(t, "other:1.0", result.Image)
+}
+
+func TestPruneIsLimitedToTheEnvironment(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"dns/base/deployment.yaml":       baseDeployment,
+		"dns/overlays/prod/overlay.yaml": "namePrefix: prod-\n",
+	})
+	cmdRunner := testutil.FakeCommandRunner("")
+	deployer := NewManifestsDeployer(manifestsPath, &manifest.Project{Name: "moneycol",
+		Values: map[string]interface{}{"domain": "moneycol.com"}}, cmdRunner)
+
+	_, err := deployer.Deploy(context.Background(), DeploymentRequest{AppName: "dns", Environment: "prod"})
+
+	assert.Nil(t, err)
+	cmdLines := cmdRunner.GetCmdLines()
+	assert.Len(t, cmdLines, 1)
+	assert.Contains(t, strings.Join(cmdLines, "\n"), "--prune -l myiac.io/app=dns,myiac.io/environment=prod")
+}
+
+func TestValidateRendersManifests(t *testing.T) {
+	manifestsPath := writeManifestFiles(t, map[string]string{
+		"external-dns/base/deployment.yaml": baseDeployment,
+		"external-dns/base/broken.yaml":     "kind: ConfigMap\n",
+	})
+
+	report := testManifestsDeployer(manifestsPath).Validate("external-dns", "dev", nil)
+
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, "resource", report.Issues[0].Check)
+}
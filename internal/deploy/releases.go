@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+)
+
+const (
+	// RepairPolicyRollback rolls stuck releases back to their last good revision, uninstalling
+	// the ones that never had one (i.e. a failed first install)
+	RepairPolicyRollback = "rollback"
+	// RepairPolicyUninstall uninstalls every stuck release
+	RepairPolicyUninstall = "uninstall"
+
+	repairActionRollback  = "rollback"
+	repairActionUninstall = "uninstall"
+)
+
+// RepairAction what was done (or would be done in dry run) to a stuck release
+type RepairAction struct {
+	Release  string
+	Status   string
+	Action   string
+	Revision int // revision rolled back to
+	Err      error
+}
+
+func (ra RepairAction) String() string {
+	action := ra.Action
+	if ra.Action == repairActionRollback {
+		action = fmt.Sprintf("%s to revision %d", ra.Action, ra.Revision)
+	}
+	return fmt.Sprintf("%s (%s): %s", ra.Release, ra.Status, action)
+}
+
+type releaseRevision struct {
+	Revision int    `json:"revision"`
+	Status   string `json:"status"`
+}
+
+// isStuckStatus tells if a release in this status blocks further deployments. Helm 3 reports
+// lowercase statuses, helm 2 uppercase ones
+func isStuckStatus(status string) bool {
+	status = strings.ToLower(status)
+	return status == "failed" || strings.HasPrefix(status, "pending-")
+}
+
+// RepairReleases finds the stuck releases (failed, pending-install, pending-upgrade, pending-rollback)
+// and rolls back or uninstalls them following 'policy'. With 'dryRun' it only reports what it would do
+func RepairReleases(policy string, dryRun bool) ([]RepairAction, error) {
+	hd := NewHelmDeployer(getBaseChartsPath(), commandline.NewEmpty(), nil)
+	return hd.RepairReleases(policy, dryRun)
+}
+
+func (hd *helmDeployer) RepairReleases(policy string, dryRun bool) ([]RepairAction, error) {
+	if policy != RepairPolicyRollback && policy != RepairPolicyUninstall {
+		return nil, fmt.Errorf("unknown repair policy %s, use %s or %s", policy, RepairPolicyRollback, RepairPolicyUninstall)
+	}
+
+	releases, err := hd.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []RepairAction
+	for _, release := range releases {
+		if !isStuckStatus(release.Status) {
+			continue
+		}
+
+		action := RepairAction{Release: release.Name, Status: release.Status, Action: repairActionUninstall}
+		if policy == RepairPolicyRollback {
+			revision, err := hd.lastGoodRevision(release)
+			if err != nil {
+				action.Err = err
+				actions = append(actions, action)
+				continue
+			}
+			if revision > 0 {
+				action.Action = repairActionRollback
+				action.Revision = revision
+			}
+		}
+
+		if !dryRun {
+			action.Err = hd.runRepairAction(action)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// lastGoodRevision the latest revision before the current one that was deployed, 0 if there's none
+func (hd *helmDeployer) lastGoodRevision(release *Release) (int, error) {
+	hd.cmdRunner.Setup("helm", []string{"history", release.Name, "--output", "json"})
+	hd.cmdRunner.SetSuppressOutput(true)
+	output, err := hd.cmdRunner.RunWithError()
+	hd.cmdRunner.SetSuppressOutput(false)
+	if err != nil {
+		return 0, fmt.Errorf("error reading history of %s: %v", release.Name, err)
+	}
+
+	var revisions []releaseRevision
+	if err := json.Unmarshal([]byte(output.Output), &revisions); err != nil {
+		return 0, fmt.Errorf("error parsing history of %s: %v", release.Name, err)
+	}
+
+	currentRevision, _ := strconv.Atoi(release.Revision)
+	lastGood := 0
+	for _, revision := range revisions {
+		status := strings.ToLower(revision.Status)
+		isGood := status == "deployed" || status == "superseded"
+		if isGood && revision.Revision < currentRevision && revision.Revision > lastGood {
+			lastGood = revision.Revision
+		}
+	}
+	return lastGood, nil
+}
+
+func (hd *helmDeployer) runRepairAction(action RepairAction) error {
+	args := []string{"uninstall", action.Release}
+	if action.Action == repairActionRollback {
+		args = []string{"rollback", action.Release, strconv.Itoa(action.Revision)}
+	}
+
+	hd.cmdRunner.Setup("helm", args)
+	_, err := hd.cmdRunner.RunWithError()
+	return err
+}
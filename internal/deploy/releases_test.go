@@ -0,0 +1,94 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const stuckReleasesOutput = `[
+{"name":"elastic","namespace":"default","revision":"1","status":"deployed","chart":"elasticsearch-1.0.0"},
+{"name":"moneycol-server","namespace":"default","revision":"4","status":"pending-upgrade","chart":"moneycol-server-0.1.0"},
+{"name":"collections-api","namespace":"default","revision":"1","status":"failed","chart":"collections-api-0.1.0"}]`
+
+const moneycolServerHistory = `[
+{"revision":1,"status":"superseded"},{"revision":2,"status":"deployed"},
+{"revision":3,"status":"failed"},{"revision":4,"status":"pending-upgrade"}]`
+
+func TestRepairRollsBackToLastGoodRevisionOrUninstalls(t *testing.T) {
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommand("helm list --all --output json", stuckReleasesOutput)
+	cmdRunner.FakeCommand("helm history moneycol-server --output json", moneycolServerHistory)
+	cmdRunner.FakeCommand("helm history collections-api --output json", `[{"revision":1,"status":"failed"}]`)
+	hd := NewHelmDeployer("charts", cmdRunner, nil)
+
+	actions, err := hd.RepairReleases(RepairPolicyRollback, false)
+
+	assert.Nil(t, err)
+	assert.Len(t, actions, 2)
+	assert.Equal(t, "moneycol-server (pending-upgrade): rollback to revision 2", actions[0].String())
+	assert.Equal(t, "collections-api (failed): uninstall", actions[1].String())
+	assert.Contains(t, cmdRunner.GetCmdLines(), "helm rollback moneycol-server 2")
+	assert.Contains(t, cmdRunner.GetCmdLines(), "helm uninstall collections-api")
+}
+
+func TestRepairDryRunOnlyReports(t *testing.T) {
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommand("helm list --all --output json", stuckReleasesOutput)
+	cmdRunner.FakeCommand("helm history moneycol-server --output json", moneycolServerHistory)
+	cmdRunner.FakeCommand("helm history collections-api --output json", `[{"revision":1,"status":"failed"}]`)
+	hd := NewHelmDeployer("charts", cmdRunner, nil)
+
+	actions, err := hd.RepairReleases(RepairPolicyUninstall, true)
+
+	assert.Nil(t, err)
+	assert.Len(t, actions, 2)
+	assert.Equal(t, repairActionUninstall, actions[0].Action)
+	assert.NotContains(t, cmdRunner.GetCmdLines(), "helm uninstall moneycol-server")
+}
+
+func TestStuckStatusesIgnoreCase(t *testing.T) {
+	assert.True(t, isStuckStatus("FAILED"))
+	assert.True(t, isStuckStatus("pending-install"))
+	assert.False(t, isStuckStatus("deployed"))
+	assert.False(t, isStuckStatus("superseded"))
+}
+
+func TestReleasesThatCannotBeListedFail(t *testing.T) {
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommandError("helm list --all --output json", 1)
+	hd := NewHelmDeployer("charts", cmdRunner, nil)
+
+	_, err := hd.RepairReleases(RepairPolicyRollback, false)
+	assert.NotNil(t, err)
+
+	_, err = hd.ReleaseFor("moneycol-server")
+	assert.NotNil(t, err)
+}
+
+func TestInvalidReleasesListFails(t *testing.T) {
+	hd := NewHelmDeployer("charts", testutil.FakeCommandRunner(""), nil)
+
+	_, err := hd.ParseReleasesList("Error: Kubernetes cluster unreachable")
+
+	assert.NotNil(t, err)
+}
+
+func TestDeployStopsOnAnyStuckReleaseOfTheChartBeforeValidating(t *testing.T) {
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommand("helm list --all --output json", `[
+{"name":"app","namespace":"default","revision":"2","status":"deployed","chart":"app-0.1.0"},
+{"name":"app-dev","namespace":"default","revision":"3","status":"pending-upgrade","chart":"app-0.1.0"}]`)
+	hd := NewHelmDeployer("charts", cmdRunner, new(mockFileReader))
+
+	_, err := hd.Deploy(context.Background(), &HelmDeployment{AppName: "app", Environment: "dev"})
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "release app-dev is pending-upgrade")
+	for _, cmdLine := range cmdRunner.GetCmdLines() {
+		assert.NotContains(t, cmdLine, "helm lint")
+		assert.NotContains(t, cmdLine, "upgrade")
+	}
+}
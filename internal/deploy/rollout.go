@@ -0,0 +1,498 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/encryption"
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const (
+	StrategyRolling   = "rolling"
+	StrategyCanary    = "canary"
+	StrategyBlueGreen = "bluegreen"
+
+	canaryReleaseSuffix = "-canary"
+	greenReleaseSuffix  = "-green"
+	// candidateIngressParam turns off the ingress of the candidate chart, its traffic comes through the weighted one
+	candidateIngressParam = "ingress.enabled"
+
+	weightedIngressSuffix       = "-weighted"
+	strategyAnnotation          = "myiac.io/strategy"
+	environmentAnnotation       = "myiac.io/environment"
+	stableReleaseAnnotation     = "myiac.io/stable-release"
+	candidateReleaseAnnotation  = "myiac.io/candidate-release"
+	candidatePropertyAnnotation = "myiac.io/candidate-properties"
+	stableServiceAnnotation     = "myiac.io/stable-service"
+	candidateServiceAnnotation  = "myiac.io/candidate-service"
+	weightAnnotation            = "myiac.io/weight"
+	hostAnnotation              = "myiac.io/host"
+	servicePortAnnotation       = "myiac.io/service-port"
+	stableIngressesAnnotation   = "myiac.io/stable-ingresses"
+
+	// the ingresses of the stable release get a class no controller serves while the weighted one routes their host
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+	disabledIngressClass   = "myiac-rollout-disabled"
+)
+
+// weightedIngressTemplate routes a host to the stable and candidate releases using Traefik 1.7
+// weighted backends (the 'service-weights' annotation), the ingress controller run in the clusters
+var weightedIngressTemplate = template.Must(template.New("weightedIngress").Funcs(template.FuncMap{
+	"quote": func(value string) string {
+		// a JSON string is a valid YAML double quoted scalar
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	},
+}).Parse(`apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: {{ .IngressName }}
+  labels:
+    app.kubernetes.io/managed-by: myiac
+    myiac.io/app: {{ .AppName }}
+  annotations:
+    kubernetes.io/ingress.class: traefik
+    traefik.ingress.kubernetes.io/service-weights: |
+      {{ .StableService }}: {{ .StableWeight }}%
+      {{ .CandidateService }}: {{ .Weight }}%
+    myiac.io/strategy: {{ .Strategy }}
+    myiac.io/environment: {{ .Environment }}
+    myiac.io/stable-release: {{ .StableRelease }}
+    myiac.io/candidate-release: {{ .CandidateRelease }}
+    myiac.io/stable-service: {{ .StableService }}
+    myiac.io/candidate-service: {{ .CandidateService }}
+    myiac.io/weight: "{{ .Weight }}"
+    myiac.io/candidate-properties: {{ quote .CandidateProperties }}
+    myiac.io/host: {{ .Host }}
+    myiac.io/service-port: "{{ .ServicePort }}"
+    myiac.io/stable-ingresses: {{ quote .StableIngresses }}
+spec:
+  rules:
+  - host: {{ .Host }}
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: {{ .StableService }}
+          servicePort: {{ .ServicePort }}
+      - path: /
+        backend:
+          serviceName: {{ .CandidateService }}
+          servicePort: {{ .ServicePort }}
+`))
+
+// RolloutOptions how a new version of an app is rolled out next to the stable one
+type RolloutOptions struct {
+	Strategy    string
+	Weight      int // % of traffic sent to the candidate (canary only, bluegreen always switches 100%)
+	Host        string
+	ServicePort int
+	DryRun      bool // renders the candidate release only, without routing traffic to it
+}
+
+// Rollout the state of a canary or blue/green rollout, kept in the weighted ingress annotations
+// so it can be promoted or aborted from anywhere
+type Rollout struct {
+	AppName             string
+	Environment         string
+	Strategy            string
+	Weight              int
+	Host                string
+	ServicePort         int
+	StableRelease       string
+	StableService       string
+	CandidateRelease    string
+	CandidateService    string
+	CandidateProperties string
+	StableIngresses     string // JSON object of the stable release ingresses and the class they had before the rollout
+}
+
+func (r Rollout) IngressName() string {
+	return r.AppName + weightedIngressSuffix
+}
+
+func (r Rollout) StableWeight() int {
+	return 100 - r.Weight
+}
+
+// WeightedIngressManifest renders the ingress splitting traffic between stable and candidate
+func WeightedIngressManifest(rollout Rollout) (string, error) {
+	var manifest bytes.Buffer
+	if err := weightedIngressTemplate.Execute(&manifest, rollout); err != nil {
+		return "", fmt.Errorf("error rendering weighted ingress for %s: %v", rollout.AppName, err)
+	}
+	return manifest.String(), nil
+}
+
+// RolloutManager installs a candidate release next to the stable one with weighted routing, and
+// promotes it (stable takes the candidate version) or aborts it (candidate removed)
+type RolloutManager interface {
+	Start(ctx context.Context, appName string, environment string, propertiesMap map[string]string,
+		options RolloutOptions) error
+	Promote(ctx context.Context, appName string) error
+	Abort(ctx context.Context, appName string) error
+}
+
+type helmRolloutManager struct {
+	helmDeployer *helmDeployer
+	cmdRunner    commandline.CommandRunner
+}
+
+// NewRolloutManager creates a RolloutManager deploying through helm and routing through Traefik
+func NewRolloutManager(decrypter encryption.Encrypter) RolloutManager {
+	cmdRunner := commandline.NewEmpty()
+	return &helmRolloutManager{
+		helmDeployer: NewHelmDeployer(getBaseChartsPath(), cmdRunner, nil).WithDecrypter(decrypter),
+		cmdRunner:    cmdRunner,
+	}
+}
+
+func (hrm *helmRolloutManager) Start(ctx context.Context, appName string, environment string,
+	propertiesMap map[string]string, options RolloutOptions) error {
+	candidateSuffix := canaryReleaseSuffix
+	weight := options.Weight
+	switch options.Strategy {
+	case StrategyCanary:
+		if weight <= 0 || weight > 100 {
+			return fmt.Errorf("canary weight should be between 1 and 100, got %d", weight)
+		}
+	case StrategyBlueGreen:
+		candidateSuffix = greenReleaseSuffix
+		weight = 100
+	default:
+		return fmt.Errorf("unknown rollout strategy %s", options.Strategy)
+	}
+
+	if options.Host == "" {
+		return fmt.Errorf("a host is required to route traffic between releases of %s", appName)
+	}
+
+	stableRelease, err := hrm.helmDeployer.ReleaseFor(appName)
+	if err != nil {
+		return err
+	}
+	if stableRelease == "" {
+		return fmt.Errorf("no stable release found for %s, deploy it first", appName)
+	}
+
+	helmSetParams := make(map[string]string)
+	addPropertiesToSetParams(helmSetParams, propertiesMap)
+	propertiesJson, _ := json.Marshal(helmSetParams)
+
+	rollout := Rollout{
+		AppName:             appName,
+		Environment:         environment,
+		Strategy:            options.Strategy,
+		Weight:              weight,
+		Host:                options.Host,
+		ServicePort:         options.ServicePort,
+		StableRelease:       stableRelease,
+		CandidateRelease:    appName + candidateSuffix,
+		CandidateProperties: string(propertiesJson),
+	}
+
+	// the candidate properties are promoted to the stable release, which keeps its ingress
+	candidateSetParams := make(map[string]string)
+	for key, value := range helmSetParams {
+		candidateSetParams[key] = value
+	}
+	if hrm.chartDefinesIngress(appName) {
+		candidateSetParams[candidateIngressParam] = "false"
+	}
+
+	if _, err := hrm.helmDeployer.Deploy(ctx, &HelmDeployment{
+		AppName:       appName,
+		DryRun:        options.DryRun,
+		Environment:   environment,
+		HelmSetParams: candidateSetParams,
+		ReleaseName:   rollout.CandidateRelease,
+	}); err != nil {
+		return err
+	}
+
+	if options.DryRun {
+		log.Printf("Dry run: %d%% of %s traffic would be routed to %s\n", rollout.Weight, rollout.Host,
+			rollout.CandidateRelease)
+		return nil
+	}
+
+	if rollout.StableService, err = hrm.releaseService(rollout.StableRelease); err != nil {
+		return err
+	}
+	if rollout.CandidateService, err = hrm.releaseService(rollout.CandidateRelease); err != nil {
+		return err
+	}
+	stableIngressClasses, err := hrm.releaseIngressClasses(rollout.StableRelease)
+	if err != nil {
+		return err
+	}
+	stableIngressesJson, _ := json.Marshal(stableIngressClasses)
+	rollout.StableIngresses = string(stableIngressesJson)
+
+	log.Printf("Routing %d%% of %s traffic to %s\n", rollout.Weight, rollout.Host, rollout.CandidateRelease)
+	if err := hrm.applyWeightedIngress(rollout); err != nil {
+		return err
+	}
+
+	// the host is routed by the weighted ingress only, the stable one would keep taking its traffic otherwise
+	for ingressName := range stableIngressClasses {
+		if err := hrm.setIngressClass(ingressName, disabledIngressClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chartDefinesIngress tells if the values of the chart of 'appName' have an ingress section to turn off
+func (hrm *helmRolloutManager) chartDefinesIngress(appName string) bool {
+	chartPath, err := hrm.helmDeployer.findChartForApp(appName)
+	if err != nil {
+		return false
+	}
+	chartValues, err := readYamlValues(filepath.Join(chartPath, chartValuesFile))
+	if err != nil {
+		return false
+	}
+	_, hasIngress := chartValues["ingress"]
+	return hasIngress
+}
+
+// Promote upgrades the stable release with the candidate properties, then removes the candidate
+func (hrm *helmRolloutManager) Promote(ctx context.Context, appName string) error {
+	rollout, err := hrm.currentRollout(appName)
+	if err != nil {
+		return err
+	}
+
+	helmSetParams := make(map[string]string)
+	if err := json.Unmarshal([]byte(rollout.CandidateProperties), &helmSetParams); err != nil {
+		return fmt.Errorf("invalid candidate properties in rollout of %s: %v", appName, err)
+	}
+
+	log.Printf("Promoting %s: upgrading %s with the candidate properties\n", rollout.CandidateRelease, rollout.StableRelease)
+	if _, err := hrm.helmDeployer.Deploy(ctx, &HelmDeployment{
+		AppName:       appName,
+		Environment:   rollout.Environment,
+		HelmSetParams: helmSetParams,
+		ReleaseName:   rollout.StableRelease,
+	}); err != nil {
+		return err
+	}
+
+	return hrm.removeCandidate(rollout)
+}
+
+// Abort sends all the traffic back to the stable release and removes the candidate
+func (hrm *helmRolloutManager) Abort(ctx context.Context, appName string) error {
+	rollout, err := hrm.currentRollout(appName)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Aborting rollout of %s\n", rollout.CandidateRelease)
+	return hrm.removeCandidate(rollout)
+}
+
+func (hrm *helmRolloutManager) removeCandidate(rollout *Rollout) error {
+	rollout.Weight = 0
+	if err := hrm.applyWeightedIngress(*rollout); err != nil {
+		return err
+	}
+
+	if err := hrm.restoreStableIngresses(rollout); err != nil {
+		return err
+	}
+
+	hrm.cmdRunner.Setup("helm", []string{"uninstall", rollout.CandidateRelease})
+	if _, err := hrm.cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error uninstalling candidate release %s: %v", rollout.CandidateRelease, err)
+	}
+
+	hrm.cmdRunner.Setup("kubectl", []string{"delete", "ingress", rollout.IngressName()})
+	if _, err := hrm.cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error deleting weighted ingress %s: %v", rollout.IngressName(), err)
+	}
+
+	log.Printf("Rollout of %s finished, all traffic goes to %s\n", rollout.AppName, rollout.StableRelease)
+	return nil
+}
+
+// restoreStableIngresses gives the ingresses of the stable release back the class they had before the rollout
+func (hrm *helmRolloutManager) restoreStableIngresses(rollout *Rollout) error {
+	if rollout.StableIngresses == "" {
+		return nil
+	}
+
+	stableIngressClasses := make(map[string]string)
+	if err := json.Unmarshal([]byte(rollout.StableIngresses), &stableIngressClasses); err != nil {
+		return fmt.Errorf("invalid stable ingresses in rollout of %s: %v", rollout.AppName, err)
+	}
+	for ingressName, ingressClass := range stableIngressClasses {
+		if err := hrm.setIngressClass(ingressName, ingressClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setIngressClass sets the class of an ingress, an empty class removes it
+func (hrm *helmRolloutManager) setIngressClass(ingressName string, ingressClass string) error {
+	classArg := ingressClassAnnotation + "-"
+	if ingressClass != "" {
+		classArg = ingressClassAnnotation + "=" + ingressClass
+	}
+
+	hrm.cmdRunner.Setup("kubectl", []string{"annotate", "ingress", ingressName, classArg, "--overwrite"})
+	if _, err := hrm.cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error setting class of ingress %s: %v", ingressName, err)
+	}
+	return nil
+}
+
+func (hrm *helmRolloutManager) applyWeightedIngress(rollout Rollout) error {
+	manifest, err := WeightedIngressManifest(rollout)
+	if err != nil {
+		return err
+	}
+
+	manifestFile, err := ioutil.TempFile("", rollout.IngressName()+"-*.yaml")
+	if err != nil {
+		return fmt.Errorf("error creating ingress manifest file: %v", err)
+	}
+	defer os.Remove(manifestFile.Name())
+
+	if _, err := manifestFile.WriteString(manifest); err != nil {
+		return fmt.Errorf("error writing ingress manifest file: %v", err)
+	}
+	_ = manifestFile.Close()
+
+	hrm.cmdRunner.Setup("kubectl", []string{"apply", "-f", manifestFile.Name()})
+	if _, err := hrm.cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error applying weighted ingress %s: %v", rollout.IngressName(), err)
+	}
+	return nil
+}
+
+func (hrm *helmRolloutManager) currentRollout(appName string) (*Rollout, error) {
+	ingressName := appName + weightedIngressSuffix
+	hrm.cmdRunner.Setup("kubectl", []string{"get", "ingress", ingressName, "-o", "json"})
+	hrm.cmdRunner.SetSuppressOutput(true)
+	output, err := hrm.cmdRunner.RunWithError()
+	hrm.cmdRunner.SetSuppressOutput(false)
+	if err != nil {
+		return nil, fmt.Errorf("no rollout in progress for %s: %v", appName, err)
+	}
+
+	return rolloutFromIngress(appName, output.Output)
+}
+
+func rolloutFromIngress(appName string, ingressJson string) (*Rollout, error) {
+	var ingress struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(ingressJson)), &ingress); err != nil {
+		return nil, fmt.Errorf("error reading rollout of %s: %v", appName, err)
+	}
+
+	annotations := ingress.Metadata.Annotations
+	rollout := &Rollout{
+		AppName:             appName,
+		Environment:         annotations[environmentAnnotation],
+		Strategy:            annotations[strategyAnnotation],
+		Host:                annotations[hostAnnotation],
+		StableRelease:       annotations[stableReleaseAnnotation],
+		StableService:       annotations[stableServiceAnnotation],
+		CandidateRelease:    annotations[candidateReleaseAnnotation],
+		CandidateService:    annotations[candidateServiceAnnotation],
+		CandidateProperties: annotations[candidatePropertyAnnotation],
+		StableIngresses:     annotations[stableIngressesAnnotation],
+	}
+
+	if rollout.StableRelease == "" || rollout.CandidateRelease == "" {
+		return nil, fmt.Errorf("ingress of %s is not managed by a myiac rollout", appName)
+	}
+
+	var err error
+	if rollout.Weight, err = strconv.Atoi(annotations[weightAnnotation]); err != nil {
+		return nil, fmt.Errorf("invalid weight in rollout of %s: %v", appName, err)
+	}
+	if rollout.ServicePort, err = strconv.Atoi(annotations[servicePortAnnotation]); err != nil {
+		return nil, fmt.Errorf("invalid service port in rollout of %s: %v", appName, err)
+	}
+
+	return rollout, nil
+}
+
+// releaseSelectors the standard helm labels selecting the resources of a release
+func releaseSelectors(releaseName string) []string {
+	return []string{"app.kubernetes.io/instance=" + releaseName, "release=" + releaseName}
+}
+
+// releaseService finds the service of a release by the standard helm labels
+func (hrm *helmRolloutManager) releaseService(releaseName string) (string, error) {
+	for _, selector := range releaseSelectors(releaseName) {
+		hrm.cmdRunner.Setup("kubectl", []string{"get", "services", "-l", selector, "-o", "json"})
+		hrm.cmdRunner.SetSuppressOutput(true)
+		output, err := hrm.cmdRunner.RunWithError()
+		hrm.cmdRunner.SetSuppressOutput(false)
+		if err != nil {
+			return "", fmt.Errorf("error finding service of release %s: %v", releaseName, err)
+		}
+
+		services := util.GetJsonArray(util.Parse(output.Output), "items")
+		if len(services) > 0 {
+			return util.GetStringValue(util.GetJsonObject(services[0], "metadata"), "name"), nil
+		}
+	}
+	return "", fmt.Errorf("no service found for release %s", releaseName)
+}
+
+// releaseIngressClasses finds the ingresses of a release by the standard helm labels, with their class
+func (hrm *helmRolloutManager) releaseIngressClasses(releaseName string) (map[string]string, error) {
+	ingressClasses := make(map[string]string)
+	for _, selector := range releaseSelectors(releaseName) {
+		hrm.cmdRunner.Setup("kubectl", []string{"get", "ingress", "-l", selector, "-o", "json"})
+		hrm.cmdRunner.SetSuppressOutput(true)
+		output, err := hrm.cmdRunner.RunWithError()
+		hrm.cmdRunner.SetSuppressOutput(false)
+		if err != nil {
+			return nil, fmt.Errorf("error finding ingresses of release %s: %v", releaseName, err)
+		}
+
+		var ingresses struct {
+			Items []struct {
+				Metadata struct {
+					Name        string            `json:"name"`
+					Annotations map[string]string `json:"annotations"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(output.Output)), &ingresses); err != nil {
+			return nil, fmt.Errorf("error reading ingresses of release %s: %v", releaseName, err)
+		}
+		for _, ingress := range ingresses.Items {
+			ingressClasses[ingress.Metadata.Name] = ingress.Metadata.Annotations[ingressClassAnnotation]
+		}
+		if len(ingressClasses) > 0 {
+			break
+		}
+	}
+	return ingressClasses, nil
+}
+
+func isCandidateRelease(releaseName string) bool {
+	return strings.HasSuffix(releaseName, canaryReleaseSuffix) || strings.HasSuffix(releaseName, greenReleaseSuffix)
+}
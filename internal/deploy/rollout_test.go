@@ -0,0 +1,147 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRollout() Rollout {
+	return Rollout{
+		AppName:             "moneycol-server",
+		Environment:         "dev",
+		Strategy:            StrategyCanary,
+		Weight:              10,
+		Host:                "moneycol.dev.example.com",
+		ServicePort:         80,
+		StableRelease:       "moneycol-server",
+		StableService:       "moneycol-server-svc",
+		CandidateRelease:    "moneycol-server-canary",
+		CandidateService:    "moneycol-server-canary-svc",
+		CandidateProperties: `{"image.tag":"it's-1.2.0"}`,
+		StableIngresses:     `{"moneycol-server":"traefik"}`,
+	}
+}
+
+func TestWeightedIngressSplitsTrafficBetweenReleases(t *testing.T) {
+	manifest, err := WeightedIngressManifest(testRollout())
+	assert.Nil(t, err)
+
+	ingress, err := parseYamlValues([]byte(manifest), "ingress")
+	assert.Nil(t, err)
+
+	metadata := ingress["metadata"].(map[string]interface{})
+	assert.Equal(t, "moneycol-server-weighted", metadata["name"])
+
+	annotations := metadata["annotations"].(map[string]interface{})
+	assert.Equal(t, "traefik", annotations["kubernetes.io/ingress.class"])
+	assert.Equal(t, "moneycol-server-svc: 90%\nmoneycol-server-canary-svc: 10%\n",
+		annotations["traefik.ingress.kubernetes.io/service-weights"])
+	assert.Equal(t, `{"image.tag":"it's-1.2.0"}`, annotations[candidatePropertyAnnotation])
+}
+
+func TestRolloutIsReadBackFromIngress(t *testing.T) {
+	manifest, _ := WeightedIngressManifest(testRollout())
+	ingress, _ := parseYamlValues([]byte(manifest), "ingress")
+	ingressJson, _ := json.Marshal(ingress)
+
+	rollout, err := rolloutFromIngress("moneycol-server", string(ingressJson))
+
+	assert.Nil(t, err)
+	assert.Equal(t, testRollout(), *rollout)
+}
+
+func TestRolloutFromUnmanagedIngressFails(t *testing.T) {
+	ingressJson, _ := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{}})
+
+	_, err := rolloutFromIngress("moneycol-server", string(ingressJson))
+
+	assert.NotNil(t, err)
+}
+
+func TestCandidateReleasesAreNotStable(t *testing.T) {
+	assert.True(t, isCandidateRelease("moneycol-server-canary"))
+	assert.True(t, isCandidateRelease("moneycol-server-green"))
+	assert.False(t, isCandidateRelease("moneycol-server"))
+}
+
+// useTestChartsPath points the charts path to a directory with the 'app' chart of mockFileReader
+func useTestChartsPath(t *testing.T) {
+	chartsPath, err := ioutil.TempDir("", "charts")
+	if err != nil {
+		t.Fatalf("error creating charts dir %v", err)
+	}
+	_ = os.MkdirAll(filepath.Join(chartsPath, "app"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(chartsPath, "app", chartValuesFile), []byte(testChartValues+"ingress:\n  enabled: true\n"), 0644)
+	_ = os.Setenv("CHARTS_PATH", chartsPath)
+	t.Cleanup(func() {
+		_ = os.Unsetenv("CHARTS_PATH")
+		_ = os.RemoveAll(chartsPath)
+	})
+}
+
+func TestDryRunRolloutOnlyRendersCandidate(t *testing.T) {
+	useTestChartsPath(t)
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommand("helm list --all --output json",
+		`[{"name":"app","namespace":"default","revision":"3","status":"deployed","chart":"app-0.1.0"}]`)
+	hrm := &helmRolloutManager{helmDeployer: NewHelmDeployer("charts", cmdRunner, new(mockFileReader)), cmdRunner: cmdRunner}
+	options := RolloutOptions{Strategy: StrategyCanary, Weight: 10, Host: "app.dev.example.com", ServicePort: 80, DryRun: true}
+
+	err := hrm.Start(context.Background(), "app", "dev", map[string]string{"image.tag": "1.2.0"}, options)
+
+	assert.Nil(t, err)
+	var installs []string
+	for _, cmdLine := range cmdRunner.GetCmdLines() {
+		assert.False(t, strings.HasPrefix(cmdLine, "kubectl"), "unexpected %s", cmdLine)
+		if strings.HasPrefix(cmdLine, "helm install app-canary") {
+			installs = append(installs, cmdLine)
+		}
+	}
+	assert.Len(t, installs, 1)
+	assert.Contains(t, installs[0], "--dry-run")
+	assert.Contains(t, installs[0], "--set ingress.enabled=false")
+}
+
+func TestStableIngressIsDisabledDuringRolloutAndRestoredOnAbort(t *testing.T) {
+	useTestChartsPath(t)
+	cmdRunner := testutil.FakeCommandRunner("")
+	cmdRunner.FakeCommand("helm list --all --output json",
+		`[{"name":"app","namespace":"default","revision":"3","status":"deployed","chart":"app-0.1.0"}]`)
+	cmdRunner.FakeCommand("kubectl get services -l app.kubernetes.io/instance=app -o json",
+		`{"items":[{"metadata":{"name":"app-svc"}}]}`)
+	cmdRunner.FakeCommand("kubectl get services -l app.kubernetes.io/instance=app-canary -o json",
+		`{"items":[{"metadata":{"name":"app-canary-svc"}}]}`)
+	cmdRunner.FakeCommand("kubectl get ingress -l app.kubernetes.io/instance=app -o json",
+		`{"items":[{"metadata":{"name":"app","annotations":{"kubernetes.io/ingress.class":"traefik"}}}]}`)
+	hrm := &helmRolloutManager{helmDeployer: NewHelmDeployer("charts", cmdRunner, new(mockFileReader)), cmdRunner: cmdRunner}
+	options := RolloutOptions{Strategy: StrategyCanary, Weight: 10, Host: "app.dev.example.com", ServicePort: 80}
+
+	err := hrm.Start(context.Background(), "app", "dev", map[string]string{"image.tag": "1.2.0"}, options)
+
+	assert.Nil(t, err)
+	assert.Contains(t, cmdRunner.GetCmdLines(),
+		"kubectl annotate ingress app kubernetes.io/ingress.class=myiac-rollout-disabled --overwrite")
+
+	rollout := testRollout()
+	rollout.AppName = "app"
+	rollout.StableRelease = "app"
+	rollout.CandidateRelease = "app-canary"
+	rollout.StableIngresses = `{"app":"traefik"}`
+	manifest, _ := WeightedIngressManifest(rollout)
+	ingress, _ := parseYamlValues([]byte(manifest), "ingress")
+	ingressJson, _ := json.Marshal(ingress)
+	cmdRunner.FakeCommand("kubectl get ingress app-weighted -o json", string(ingressJson))
+
+	err = hrm.Abort(context.Background(), "app")
+
+	assert.Nil(t, err)
+	assert.Contains(t, cmdRunner.GetCmdLines(), "kubectl annotate ingress app kubernetes.io/ingress.class=traefik --overwrite")
+}
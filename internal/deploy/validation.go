@@ -0,0 +1,384 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/util"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	severityError   = "ERROR"
+	severityWarning = "WARNING"
+
+	chartValuesFile       = "values.yaml"
+	chartSchemaFile       = "values.schema.json"
+	chartRequirementsFile = "requirements.yaml"
+)
+
+// ValidationIssue a single finding of the pre-deploy validation
+type ValidationIssue struct {
+	Severity string
+	Check    string
+	Message  string
+}
+
+// ValidationReport the findings of validating an app chart and its values for an environment
+type ValidationReport struct {
+	AppName     string
+	Environment string
+	Issues      []ValidationIssue
+}
+
+func (vr *ValidationReport) addError(check string, message string) {
+	vr.Issues = append(vr.Issues, ValidationIssue{Severity: severityError, Check: check, Message: message})
+}
+
+func (vr *ValidationReport) addWarning(check string, message string) {
+	vr.Issues = append(vr.Issues, ValidationIssue{Severity: severityWarning, Check: check, Message: message})
+}
+
+// HasErrors tells if any of the issues should stop the deployment
+func (vr *ValidationReport) HasErrors() bool {
+	for _, issue := range vr.Issues {
+		if issue.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (vr *ValidationReport) Print() {
+	if len(vr.Issues) == 0 {
+		fmt.Printf("Validation of %s for %s passed\n", vr.AppName, vr.Environment)
+		return
+	}
+
+	fmt.Printf("Validation of %s for %s found %d issue(s):\n", vr.AppName, vr.Environment, len(vr.Issues))
+	for _, issue := range vr.Issues {
+		fmt.Printf("  [%s] %s: %s\n", issue.Severity, issue.Check, issue.Message)
+	}
+}
+
+// ValuesPolicy a rule on the final (merged) values an app is deployed with
+type ValuesPolicy interface {
+	Check(environment string, values map[string]interface{}, report *ValidationReport)
+}
+
+// noLatestImageTagPolicy rejects mutable 'latest' image tags in the given environments
+type noLatestImageTagPolicy struct {
+	environments []string
+}
+
+func DefaultValuesPolicies() []ValuesPolicy {
+	return []ValuesPolicy{&noLatestImageTagPolicy{environments: []string{"prod", "production"}}}
+}
+
+func (p *noLatestImageTagPolicy) Check(environment string, values map[string]interface{}, report *ValidationReport) {
+	applies := false
+	for _, env := range p.environments {
+		if env == environment {
+			applies = true
+		}
+	}
+
+	if !applies {
+		return
+	}
+
+	walkValues("", values, func(path string, value interface{}) {
+		strValue, isString := value.(string)
+		if !isString {
+			return
+		}
+		lastKey := path[strings.LastIndex(path, ".")+1:]
+		isLatestTag := strings.EqualFold(lastKey, "tag") && strValue == "latest"
+		isLatestImage := strings.EqualFold(lastKey, "image") && strings.HasSuffix(strValue, ":latest")
+		if isLatestTag || isLatestImage {
+			report.addError("image-tag", fmt.Sprintf("'%s' uses the 'latest' image tag, not allowed in %s", path, environment))
+		}
+	})
+}
+
+// ValidateApp runs the pre-deploy checks of a deployment as Deploy does, finding the chart of the app
+// and decrypting its encrypted values for the environment
+func (hd *helmDeployer) ValidateApp(helmDeployment *HelmDeployment) *ValidationReport {
+	chartPath, err := hd.findChartForApp(helmDeployment.AppName)
+	if err != nil {
+		report := &ValidationReport{AppName: helmDeployment.AppName, Environment: helmDeployment.Environment}
+		report.addError("chart", err.Error())
+		return report
+	}
+
+	decryptedValues, err := hd.decryptValuesFiles(chartPath, helmDeployment.Environment)
+	if err != nil {
+		report := &ValidationReport{AppName: helmDeployment.AppName, Environment: helmDeployment.Environment}
+		report.addError("values", fmt.Sprintf("could not decrypt values: %v", err))
+		return report
+	}
+	defer removeDecryptedValues(decryptedValues)
+
+	deployment := *helmDeployment
+	deployment.HelmValuesParams = append(append([]string{}, helmDeployment.HelmValuesParams...), decryptedValues...)
+	return hd.Validate(chartPath, &deployment)
+}
+
+// Validate runs the pre-deploy checks for a deployment: chart lint, merged values against
+// the chart values.schema.json, unknown top-level keys and the values policies
+func (hd *helmDeployer) Validate(chartPath string, helmDeployment *HelmDeployment) *ValidationReport {
+	report := &ValidationReport{AppName: helmDeployment.AppName, Environment: helmDeployment.Environment}
+
+	hd.lintChart(chartPath, helmDeployment, report)
+
+	chartValues, err := readYamlValues(filepath.Join(chartPath, chartValuesFile))
+	if err != nil {
+		report.addError("values", err.Error())
+		return report
+	}
+
+	overrides := make(map[string]interface{})
+	for _, valuesFile := range helmDeployment.HelmValuesParams {
+		fileValues, err := readYamlValues(valuesFile)
+		if err != nil {
+			report.addError("values", err.Error())
+			continue
+		}
+		util.MergeValues(overrides, fileValues)
+	}
+	util.MergeValues(overrides, setParamsToValues(helmDeployment.HelmSetParams))
+
+	schema, err := readSchema(filepath.Join(chartPath, chartSchemaFile))
+	if err != nil {
+		report.addError("schema", err.Error())
+	}
+
+	dependencies, err := chartDependencyNames(chartPath)
+	if err != nil {
+		report.addError("values", err.Error())
+	}
+	checkUnknownTopLevelKeys(chartValues, schema, dependencies, overrides, report)
+
+	merged := make(map[string]interface{})
+	util.MergeValues(merged, chartValues)
+	util.MergeValues(merged, overrides)
+
+	if schema != nil {
+		validateAgainstSchema(schema, merged, report)
+	}
+
+	for _, policy := range hd.valuesPolicies {
+		policy.Check(helmDeployment.Environment, merged, report)
+	}
+
+	return report
+}
+
+func (hd *helmDeployer) lintChart(chartPath string, helmDeployment *HelmDeployment, report *ValidationReport) {
+	argsArray := []string{"lint", chartPath}
+	for _, valuesFile := range helmDeployment.HelmValuesParams {
+		argsArray = append(argsArray, "--values", valuesFile)
+	}
+	for k, v := range helmDeployment.HelmSetParams {
+		argsArray = append(argsArray, "--set", k+"="+v)
+	}
+
+	hd.cmdRunner.Setup("helm", argsArray)
+	output, err := hd.cmdRunner.RunWithError()
+
+	lintErrors := 0
+	for _, line := range strings.Split(output.Output, "\n") {
+		if strings.Contains(line, "[ERROR]") {
+			report.addError("lint", strings.TrimSpace(line))
+			lintErrors++
+		} else if strings.Contains(line, "[WARNING]") {
+			report.addWarning("lint", strings.TrimSpace(line))
+		}
+	}
+
+	if err != nil && lintErrors == 0 {
+		report.addError("lint", err.Error())
+	}
+}
+
+// checkUnknownTopLevelKeys flags the top-level keys of 'overrides' not in the chart values nor its schema.
+// Keys named after a dependency of the chart configure that subchart, so they are known too
+func checkUnknownTopLevelKeys(chartValues map[string]interface{}, schema map[string]interface{},
+	dependencies []string, overrides map[string]interface{}, report *ValidationReport) {
+	var schemaProperties map[string]interface{}
+	if schema != nil {
+		schemaProperties, _ = schema["properties"].(map[string]interface{})
+	}
+
+	isDependency := make(map[string]bool)
+	for _, dependency := range dependencies {
+		isDependency[dependency] = true
+	}
+
+	var keys []string
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		_, inValues := chartValues[key]
+		_, inSchema := schemaProperties[key]
+		if !inValues && !inSchema && !isDependency[key] && key != "global" {
+			report.addError("unknown-key", fmt.Sprintf("top-level key '%s' is not defined by the chart", key))
+		}
+	}
+}
+
+// chartDependencyNames the names (or aliases) the subcharts of a chart take their values under, from the
+// dependencies of Chart.yaml (helm 3) or requirements.yaml (helm 2)
+func chartDependencyNames(chartPath string) ([]string, error) {
+	var names []string
+	for _, dependenciesFile := range []string{chartFile, chartRequirementsFile} {
+		content, err := ioutil.ReadFile(filepath.Join(chartPath, dependenciesFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", dependenciesFile, err)
+		}
+
+		var chart struct {
+			Dependencies []struct {
+				Name  string `yaml:"name"`
+				Alias string `yaml:"alias"`
+			} `yaml:"dependencies"`
+		}
+		if err := yaml.Unmarshal(content, &chart); err != nil {
+			return nil, fmt.Errorf("invalid yaml in %s: %v", dependenciesFile, err)
+		}
+		for _, dependency := range chart.Dependencies {
+			if dependency.Alias != "" {
+				names = append(names, dependency.Alias)
+			} else {
+				names = append(names, dependency.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+func validateAgainstSchema(schema map[string]interface{}, values map[string]interface{}, report *ValidationReport) {
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(values))
+	if err != nil {
+		report.addError("schema", fmt.Sprintf("could not validate values against schema: %v", err))
+		return
+	}
+
+	for _, schemaErr := range result.Errors() {
+		report.addError("schema", schemaErr.String())
+	}
+}
+
+func readSchema(schemaPath string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(schemaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", schemaPath, err)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %v", schemaPath, err)
+	}
+	return schema, nil
+}
+
+// readYamlValues reads a values file into a JSON compatible map. A missing file has no values
+func readYamlValues(valuesPath string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(valuesPath)
+	if os.IsNotExist(err) {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", valuesPath, err)
+	}
+
+	return parseYamlValues(content, valuesPath)
+}
+
+func parseYamlValues(content []byte, source string) (map[string]interface{}, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("invalid yaml in %s: %v", source, err)
+	}
+
+	values, _ := util.NormalizeYaml(raw).(map[string]interface{})
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return values, nil
+}
+
+// setParamsToValues turns helm --set style params (a.b.c=value) into nested values
+func setParamsToValues(setParams map[string]string) map[string]interface{} {
+	values := make(map[string]interface{})
+	for key, value := range setParams {
+		current := values
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[part] = next
+			}
+			current = next
+		}
+		current[parts[len(parts)-1]] = parseSetValue(value)
+	}
+	return values
+}
+
+func parseSetValue(value string) interface{} {
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		var list []interface{}
+		inner := strings.ReplaceAll(strings.Trim(value, "{}"), "\\,", ",")
+		for _, item := range strings.Split(inner, ",") {
+			list = append(list, parseSetValue(item))
+		}
+		return list
+	}
+
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+
+	if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return intValue
+	}
+
+	return value
+}
+
+func walkValues(path string, value interface{}, visit func(path string, value interface{})) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for k, v := range typed {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			walkValues(childPath, v, visit)
+		}
+	case []interface{}:
+		for i, v := range typed {
+			walkValues(fmt.Sprintf("%s[%d]", path, i), v, visit)
+		}
+	default:
+		visit(path, value)
+	}
+}
@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testChartValues = `
+replicaCount: 1
+image:
+  repository: eu.gcr.io/moneycol/moneycol-server
+  tag: 0.1.0
+`
+
+const testChartSchema = `{
+  "type": "object",
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {"type": "object"}
+  }
+}`
+
+func createTestChart(t *testing.T) string {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatalf("error creating chart dir %v", err)
+	}
+	_ = ioutil.WriteFile(filepath.Join(chartPath, chartValuesFile), []byte(testChartValues), 0644)
+	_ = ioutil.WriteFile(filepath.Join(chartPath, chartSchemaFile), []byte(testChartSchema), 0644)
+	return chartPath
+}
+
+func validateWithSetParams(t *testing.T, env string, setParams map[string]string) *ValidationReport {
+	chartPath := createTestChart(t)
+	defer os.RemoveAll(chartPath)
+
+	d := NewHelmDeployer("charts", &mockCommandRunner{}, nil)
+	deployment := &HelmDeployment{AppName: "moneycol-server", Environment: env, HelmSetParams: setParams}
+	return d.Validate(chartPath, deployment)
+}
+
+func TestValidValuesPass(t *testing.T) {
+	report := validateWithSetParams(t, "prod", map[string]string{"image.tag": "0.2.0", "replicaCount": "2"})
+
+	assert.False(t, report.HasErrors())
+}
+
+func TestUnknownTopLevelKeyIsFlagged(t *testing.T) {
+	report := validateWithSetParams(t, "dev", map[string]string{"replicaCont": "2"})
+
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, "unknown-key", report.Issues[0].Check)
+}
+
+func TestSchemaViolationIsFlagged(t *testing.T) {
+	report := validateWithSetParams(t, "dev", map[string]string{"replicaCount": "0"})
+
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, "schema", report.Issues[0].Check)
+}
+
+func TestLatestImageTagRejectedOnlyInProd(t *testing.T) {
+	latestTag := map[string]string{"image.tag": "latest"}
+
+	assert.False(t, validateWithSetParams(t, "dev", latestTag).HasErrors())
+
+	prodReport := validateWithSetParams(t, "prod", latestTag)
+	assert.True(t, prodReport.HasErrors())
+	assert.Equal(t, "image-tag", prodReport.Issues[0].Check)
+}
+
+func TestSetParamsBecomeNestedValues(t *testing.T) {
+	values := setParamsToValues(map[string]string{"image.tag": "0.1.0", "replicaCount": "3",
+		"externalIps": "{1.1.1.1\\,2.2.2.2}"})
+
+	assert.Equal(t, "0.1.0", values["image"].(map[string]interface{})["tag"])
+	assert.Equal(t, int64(3), values["replicaCount"])
+	assert.Equal(t, []interface{}{"1.1.1.1", "2.2.2.2"}, values["externalIps"])
+}
+
+func TestDependencyValuesAreNotUnknownKeys(t *testing.T) {
+	chartPath := createTestChart(t)
+	defer os.RemoveAll(chartPath)
+	chart := `apiVersion: v2
+name: moneycol-server
+dependencies:
+- name: redis
+  version: 17.0.0
+- name: postgresql
+  version: 12.0.0
+  alias: db
+`
+	_ = ioutil.WriteFile(filepath.Join(chartPath, chartFile), []byte(chart), 0644)
+	d := NewHelmDeployer("charts", &mockCommandRunner{}, nil)
+	setParams := map[string]string{"redis.enabled": "true", "db.auth.database": "moneycol"}
+
+	report := d.Validate(chartPath, &HelmDeployment{AppName: "moneycol-server", Environment: "dev", HelmSetParams: setParams})
+
+	assert.False(t, report.HasErrors())
+}
+
+func TestValidateAppChecksDecryptedValues(t *testing.T) {
+	chartsPath, err := ioutil.TempDir("", "charts")
+	if err != nil {
+		t.Fatalf("error creating charts dir %v", err)
+	}
+	chartPath := filepath.Join(chartsPath, "moneycol-server")
+	_ = os.MkdirAll(chartPath, 0755)
+	_ = ioutil.WriteFile(filepath.Join(chartPath, chartValuesFile), []byte(testChartValues), 0644)
+	_ = ioutil.WriteFile(filepath.Join(chartPath, chartSchemaFile), []byte(testChartSchema), 0644)
+	_ = ioutil.WriteFile(filepath.Join(chartPath, "secrets.dev.yaml.enc"), []byte(reverse("replicaCount: 0\n")), 0644)
+	_ = os.Setenv("CHARTS_PATH", chartsPath)
+	defer func() {
+		_ = os.Unsetenv("CHARTS_PATH")
+		_ = os.RemoveAll(chartsPath)
+	}()
+	d := NewHelmDeployer(chartsPath, &mockCommandRunner{}, nil).WithDecrypter(reverseDecrypter{})
+
+	report := d.ValidateApp(&HelmDeployment{AppName: "moneycol-server", Environment: "dev"})
+
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, "schema", report.Issues[0].Check)
+}
@@ -0,0 +1,299 @@
+// Package doctor checks the tools and credentials myiac depends on, so missing or incompatible ones
+// are reported upfront with a hint to fix them instead of failing deep inside a command
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+)
+
+const (
+	StatusPass = "PASS"
+	StatusWarn = "WARN"
+	StatusFail = "FAIL"
+)
+
+// Result the outcome of a check, failures and warnings come with a remediation hint
+type Result struct {
+	Check   string
+	Status  string
+	Message string
+	Hint    string
+}
+
+func pass(check string, message string) Result {
+	return Result{Check: check, Status: StatusPass, Message: message}
+}
+
+func warn(check string, message string, hint string) Result {
+	return Result{Check: check, Status: StatusWarn, Message: message, Hint: hint}
+}
+
+func fail(check string, message string, hint string) Result {
+	return Result{Check: check, Status: StatusFail, Message: message, Hint: hint}
+}
+
+// HasFailures if any of the checks failed, warnings aren't failures
+func HasFailures(results []Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Version a major.minor.patch version
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) lessThan(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+var versionRegex = regexp.MustCompile(`v?(\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion the first version found in the output of a version command
+func ParseVersion(output string) (Version, bool) {
+	match := versionRegex.FindStringSubmatch(output)
+	if match == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	patch, _ := strconv.Atoi(match[3])
+	return Version{Major: major, Minor: minor, Patch: patch}, true
+}
+
+// Binary an executable myiac shells out to, with the versions it works with
+type Binary struct {
+	Name        string
+	VersionArgs []string
+	// MinVersion oldest compatible version, zero means any
+	MinVersion Version
+	// MaxMajor newest compatible major version, zero means any
+	MaxMajor int
+	// Optional only some commands need it, missing it is a warning
+	Optional bool
+	Hint     string
+}
+
+// Binaries the executables myiac uses. The Docker image pins Helm 3.1.2 and Terraform 0.12.29:
+// helm 2 lacks 'uninstall' and 'list --output json', terraform before 0.12 lacks 'show -json'
+var Binaries = []Binary{
+	{Name: "gcloud", VersionArgs: []string{"version"},
+		Hint: "install the Google Cloud SDK: https://cloud.google.com/sdk/docs/install"},
+	{Name: "kubectl", VersionArgs: []string{"version", "--client"},
+		Hint: "install it with 'gcloud components install kubectl'"},
+	{Name: "helm", VersionArgs: []string{"version", "--short"}, MinVersion: Version{Major: 3}, MaxMajor: 3,
+		Hint: "install helm 3 (the image uses 3.1.2): https://helm.sh/docs/intro/install"},
+	{Name: "terraform", VersionArgs: []string{"version"}, MinVersion: Version{Minor: 12},
+		Hint: "install terraform 0.12 or newer (the image uses 0.12.29): https://www.terraform.io/downloads.html"},
+	{Name: "docker", VersionArgs: []string{"version", "--format", "{{.Client.Version}}"}, Optional: true,
+		Hint: "install docker to use dockerSetup and dockerBuild: https://docs.docker.com/get-docker"},
+}
+
+// Options what to check besides the binaries
+type Options struct {
+	// KeyLocation the service account key JSON
+	KeyLocation string
+	// ExpectedContext the kubeconfig context of the active cluster, empty when there's none
+	ExpectedContext string
+	ChartsPath      string
+}
+
+// Doctor runs the checks
+type Doctor struct {
+	cmdRunner commandline.CommandRunner
+	lookPath  func(file string) (string, error)
+}
+
+// New a Doctor running the real commands
+func New() *Doctor {
+	return newDoctor(commandline.NewEmpty(), exec.LookPath)
+}
+
+func newDoctor(cmdRunner commandline.CommandRunner, lookPath func(file string) (string, error)) *Doctor {
+	return &Doctor{cmdRunner: cmdRunner, lookPath: lookPath}
+}
+
+// Run every check: binaries and their versions, the service account key, the active gcloud account,
+// the kubeconfig context and the charts path
+func (d *Doctor) Run(options Options) []Result {
+	var results []Result
+	for _, binary := range Binaries {
+		results = append(results, d.checkBinary(binary))
+	}
+
+	keyResult, keyEmail := checkServiceAccountKey(options.KeyLocation)
+	results = append(results, keyResult)
+	results = append(results, d.whenPresent("gcloud", "gcloud account", func() Result {
+		return d.checkActiveAccount(keyEmail)
+	}))
+	results = append(results, d.whenPresent("kubectl", "kubeconfig context", func() Result {
+		return d.checkKubeContext(options.ExpectedContext)
+	}))
+	results = append(results, checkChartsPath(options.ChartsPath))
+	return results
+}
+
+// Preflight the fast subset run before commands: only the presence of the binaries they need
+func (d *Doctor) Preflight(binaryNames []string) []Result {
+	var results []Result
+	for _, binary := range Binaries {
+		for _, name := range binaryNames {
+			if binary.Name == name {
+				results = append(results, d.checkPresence(binary))
+			}
+		}
+	}
+	return results
+}
+
+// whenPresent runs a check relying on a binary only if it's present
+func (d *Doctor) whenPresent(binaryName string, check string, run func() Result) Result {
+	if _, err := d.lookPath(binaryName); err != nil {
+		return fail(check, fmt.Sprintf("can't be checked without %s", binaryName), "install "+binaryName)
+	}
+	return run()
+}
+
+func (d *Doctor) checkPresence(binary Binary) Result {
+	path, err := d.lookPath(binary.Name)
+	if err != nil {
+		return fail(binary.Name, "not found in PATH", binary.Hint)
+	}
+	return pass(binary.Name, path)
+}
+
+func (d *Doctor) checkBinary(binary Binary) Result {
+	result := d.checkPresence(binary)
+	if result.Status != StatusPass {
+		if binary.Optional {
+			result.Status = StatusWarn
+		}
+		return result
+	}
+
+	output, err := d.run(binary.Name, binary.VersionArgs)
+	if err != nil {
+		return fail(binary.Name, fmt.Sprintf("'%s %s' failed: %v", binary.Name, strings.Join(binary.VersionArgs, " "), err),
+			binary.Hint)
+	}
+
+	version, found := ParseVersion(output)
+	if !found {
+		return warn(binary.Name, "version could not be determined", binary.Hint)
+	}
+
+	tooOld := version.lessThan(binary.MinVersion)
+	tooNew := binary.MaxMajor != 0 && version.Major > binary.MaxMajor
+	if tooOld || tooNew {
+		return fail(binary.Name, fmt.Sprintf("version %s is not compatible", version), binary.Hint)
+	}
+	return pass(binary.Name, "version "+version.String())
+}
+
+// checkServiceAccountKey validates the key JSON, returning the email of the service account
+func checkServiceAccountKey(keyLocation string) (Result, string) {
+	const check = "service account key"
+	hint := "pass a service account JSON key with --keyPath, it's saved by setupEnvironment"
+	if keyLocation == "" {
+		return fail(check, "no key location configured", hint), ""
+	}
+
+	content, err := ioutil.ReadFile(keyLocation)
+	if err != nil {
+		return fail(check, fmt.Sprintf("%s can't be read: %v", keyLocation, err), hint), ""
+	}
+
+	var key struct {
+		Type        string `json:"type"`
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(content, &key); err != nil {
+		return fail(check, fmt.Sprintf("%s is not valid JSON: %v", keyLocation, err), hint), ""
+	}
+
+	hint = "create a new key in the IAM console (Service accounts > Keys > Add key > JSON)"
+	if key.Type != "service_account" {
+		return fail(check, fmt.Sprintf("%s is a '%s' key, not a service account one", keyLocation, key.Type), hint), ""
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return fail(check, fmt.Sprintf("%s has no client_email or private_key", keyLocation), hint), ""
+	}
+	return pass(check, fmt.Sprintf("%s (%s)", key.ClientEmail, key.ProjectID)), key.ClientEmail
+}
+
+// checkActiveAccount the active gcloud account is the one of the key
+func (d *Doctor) checkActiveAccount(keyEmail string) Result {
+	const check = "gcloud account"
+	output, err := d.run("gcloud", []string{"auth", "list", "--filter=status:ACTIVE", "--format=value(account)"})
+	if err != nil {
+		return fail(check, fmt.Sprintf("accounts can't be listed: %v", err), "check the gcloud installation")
+	}
+
+	active := strings.TrimSpace(output)
+	if active == "" {
+		return fail(check, "no active account", "run 'myiac setupEnvironment' to activate the service account")
+	}
+	if keyEmail != "" && active != keyEmail {
+		return warn(check, fmt.Sprintf("active account is %s, not the key one %s", active, keyEmail),
+			"run 'myiac setupEnvironment' to activate the service account")
+	}
+	return pass(check, active)
+}
+
+// checkKubeContext there's a current kubeconfig context and it's the one of the active cluster
+func (d *Doctor) checkKubeContext(expectedContext string) Result {
+	const check = "kubeconfig context"
+	hint := "run 'myiac setupEnvironment' to get the credentials of the cluster"
+	output, err := d.run("kubectl", []string{"config", "current-context"})
+	current := strings.TrimSpace(output)
+	if err != nil || current == "" {
+		return fail(check, "no current context", hint)
+	}
+	if expectedContext != "" && current != expectedContext {
+		return warn(check, fmt.Sprintf("current context is %s, the active cluster is %s", current, expectedContext), hint)
+	}
+	return pass(check, current)
+}
+
+func checkChartsPath(chartsPath string) Result {
+	const check = "charts path"
+	hint := "set CHARTS_PATH to the directory of the helm charts"
+	info, err := os.Stat(chartsPath)
+	if err != nil || !info.IsDir() {
+		return fail(check, fmt.Sprintf("%s is not a directory", chartsPath), hint)
+	}
+	return pass(check, chartsPath)
+}
+
+func (d *Doctor) run(executable string, args []string) (string, error) {
+	d.cmdRunner.Setup(executable, args)
+	d.cmdRunner.SetSuppressOutput(true)
+	output, err := d.cmdRunner.RunWithError()
+	d.cmdRunner.SetSuppressOutput(false)
+	return output.Output, err
+}
@@ -0,0 +1,55 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+)
+
+const (
+	AddressExternal = "EXTERNAL"
+	AddressInUse    = "IN_USE"
+)
+
+// Address a static IP address reserved in the project, as listed by gcloud
+type Address struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	AddressType string `json:"addressType"`
+	Status      string `json:"status"`
+	// Region the URL of the region of a regional address, empty for global ones
+	Region string `json:"region"`
+}
+
+// IsExternal whether the address is an external one, the only ones billed
+func (a Address) IsExternal() bool {
+	// global addresses don't list a type, they are external
+	return a.AddressType == "" || a.AddressType == AddressExternal
+}
+
+// RegionName the name of the region of a regional address (europe-west2), empty for global ones
+func (a Address) RegionName() string {
+	return a.Region[strings.LastIndex(a.Region, "/")+1:]
+}
+
+// ListAddresses the static IP addresses reserved in the project, global and regional
+func ListAddresses(cmdRunner commandline.CommandRunner, project string) ([]Address, error) {
+	cmdRunner.Setup("gcloud", []string{"compute", "addresses", "list", "--project", project, "--format", "json"})
+	cmdRunner.SetSuppressOutput(true)
+	defer cmdRunner.SetSuppressOutput(false)
+	output, err := cmdRunner.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error listing addresses of %s: %v", project, err)
+	}
+
+	var addresses []Address
+	if strings.TrimSpace(output.Output) == "" {
+		return addresses, nil
+	}
+	if err := json.Unmarshal([]byte(output.Output), &addresses); err != nil {
+		return nil, fmt.Errorf("error parsing addresses: %v", err)
+	}
+	return addresses, nil
+}
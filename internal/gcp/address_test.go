@@ -0,0 +1,34 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const addressesJson = `[
+  {"name": "traefik-ip", "address": "35.197.220.11", "addressType": "EXTERNAL", "status": "IN_USE",
+   "region": "https://www.googleapis.com/compute/v1/projects/moneycol/regions/europe-west2"},
+  {"name": "nat-internal", "address": "10.154.0.40", "addressType": "INTERNAL", "status": "RESERVED",
+   "region": "https://www.googleapis.com/compute/v1/projects/moneycol/regions/europe-west2"},
+  {"name": "frontend-global-ip", "address": "34.120.1.20", "status": "RESERVED"}
+]`
+
+func TestListAddressesOfProject(t *testing.T) {
+	cmdLine := "gcloud compute addresses list --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(cmdLine, addressesJson)
+
+	addresses, err := ListAddresses(runner, "moneycol")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{cmdLine}, runner.GetCmdLines())
+	assert.Len(t, addresses, 3)
+	assert.Equal(t, AddressInUse, addresses[0].Status)
+	assert.True(t, addresses[0].IsExternal())
+	assert.False(t, addresses[1].IsExternal())
+	assert.True(t, addresses[2].IsExternal())
+	assert.Equal(t, "europe-west2", addresses[0].RegionName())
+	assert.Equal(t, "", addresses[2].RegionName())
+}
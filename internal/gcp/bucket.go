@@ -6,14 +6,31 @@ import (
 	"log"
 
 	"cloud.google.com/go/storage"
+	"github.com/iac-io/myiac/internal/naming"
 	"google.golang.org/api/iterator"
 )
 
-const tfstate = "-tfstate-"
+// ProjectBucketName is the bucket myiac keeps its own per-project state in (locks, history...)
+func ProjectBucketName(projectID string) string {
+	return naming.MustFor(projectID).ProjectBucketName(projectID)
+}
+
+// StateBucketName is the bucket holding the terraform state of an environment
+func StateBucketName(projectID string, e string) string {
+	return naming.MustFor(projectID).StateBucketName(projectID, e)
+}
 
 func CreateGCSBucket(projectID string, e string) error {
+	return EnsureGCSBucket(projectID, StateBucketName(projectID, e))
+}
+
+// EnsureGCSBucket creates the bucket 'bucketName' in the project unless it already exists
+func EnsureGCSBucket(projectID string, bucketName string) error {
+	if bucketName == "" {
+		return fmt.Errorf("BucketName entered is empty %v.", bucketName)
+	}
+
 	// Setup context, client and bucket name
-	bucketName := projectID + tfstate + e
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -25,9 +42,6 @@ func CreateGCSBucket(projectID string, e string) error {
 
 	buckets := client.Buckets(ctx, projectID)
 	for {
-		if bucketName == "" {
-			return fmt.Errorf("BucketName entered is empty %v.", bucketName)
-		}
 		attrs, err := buckets.Next()
 		// Assume bucket not found if at Iterator end and create
 		if err == iterator.Done {
@@ -41,7 +55,7 @@ func CreateGCSBucket(projectID string, e string) error {
 			return nil
 		}
 		if err != nil {
-			return fmt.Errorf("Issues setting up Bucket(%q).Objects(): %v. Double check project id.", attrs.Name, err)
+			return fmt.Errorf("Issues listing buckets looking for %q: %v. Double check project id.", bucketName, err)
 		}
 		if attrs.Name == bucketName {
 			log.Printf("Bucket %v exists.\n", bucketName)
@@ -50,16 +64,35 @@ func CreateGCSBucket(projectID string, e string) error {
 	}
 }
 
+// DeleteGCSBucket deletes the state bucket of an environment. A bucket can only be removed once empty,
+// so every object is deleted first, including the noncurrent versions kept by object versioning
 func DeleteGCSBucket(projectID string, e string) error {
 	// Setup context, client and bucket name
-	bucketName := projectID + tfstate + e
+	bucketName := StateBucketName(projectID, e)
 	fmt.Printf("Deleting Bucket: %v\n", bucketName)
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
 		return fmt.Errorf("GCP: Could not connect to Google Cloud. Error: %v", err)
 	}
-	if err := client.Bucket(bucketName).Delete(ctx); err != nil {
+
+	bucket := client.Bucket(bucketName)
+	objects := bucket.Objects(ctx, &storage.Query{Versions: true})
+	for {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("GCP: Storage: Could not list objects of Bucket: %v. Error: %v", bucketName, err)
+		}
+		if err := bucket.Object(attrs.Name).Generation(attrs.Generation).Delete(ctx); err != nil {
+			return fmt.Errorf("GCP: Storage: Could not delete object %v (generation %d). Error: %v", attrs.Name,
+				attrs.Generation, err)
+		}
+	}
+
+	if err := bucket.Delete(ctx); err != nil {
 		return fmt.Errorf("GCP: Stroage: Could not delete Bucket: %v.Error: %v", bucketName, err)
 	}
 	fmt.Printf("Bucket %v deleted!\n", bucketName)
@@ -39,6 +39,9 @@ func NewDNSChangeRequest(dnsProvider string, domainName string, serviceName stri
 		return nil, fmt.Errorf("error: unknown dns provider %s", dnsProvider)
 	}
 	serviceProps := services.NewServiceProps(serviceName)
+	if serviceProps == nil {
+		return nil, fmt.Errorf("error: no DNS settings for %s", serviceName)
+	}
 	if !strings.HasSuffix(domainName, serviceProps.ClusterDomainName) {
 		return nil, fmt.Errorf("error: domain name not supported %s", domainName)
 	}
@@ -63,6 +66,9 @@ type GoogleCloudDNSService struct {
 
 func NewDNSService(dnsProvider string, serviceName string) (DNSService, error) {
 	props := services.NewServiceProps(serviceName)
+	if props == nil {
+		return nil, fmt.Errorf("error: no DNS settings for %s", serviceName)
+	}
 	if dnsProvider == dnsProviderGcp {
 		log.Printf("Setting up GCP DNS provider")
 		return NewGoogleCloudDNSService(props.GcpProjectId, props.GkeClusterZone), nil
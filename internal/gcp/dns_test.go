@@ -51,3 +51,9 @@ func TestDNSServiceCreate(t *testing.T) {
 
 	dnsService.UpsertDNSEntry("collections.moneycol.net", "2.2.2.2")
 }
+
+func TestDNSChangeRequestOfProjectWithoutDnsSettingsFails(t *testing.T) {
+	_, err := NewDNSChangeRequest(dnsProviderGcp, "example.com", "other-project")
+
+	assert.NotNil(t, err)
+}
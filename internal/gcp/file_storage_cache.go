@@ -0,0 +1,167 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileObjectStorageCache implements 'ObjectStorageCache' on the local filesystem: buckets are
+// directories under 'baseDir' and keys are paths relative to them. Useful for tests and local runs
+type fileObjectStorageCache struct {
+	baseDir string
+}
+
+// NewFileObjectStorageCache creates an ObjectStorageCache storing objects under 'baseDir'
+func NewFileObjectStorageCache(baseDir string) ObjectStorageCache {
+	return &fileObjectStorageCache{baseDir: baseDir}
+}
+
+func (fosc *fileObjectStorageCache) Write(ctx context.Context, bucketName string, key string, content interface{}) error {
+	objectPath := fosc.objectPath(bucketName, key)
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0700); err != nil {
+		return fmt.Errorf("error creating dir for %s: %v", objectPath, err)
+	}
+	return ioutil.WriteFile(objectPath, []byte(fmt.Sprintf("%v", content)), 0600)
+}
+
+func (fosc *fileObjectStorageCache) Read(ctx context.Context, bucketName string, key string) (interface{}, error) {
+	content, err := ioutil.ReadFile(fosc.objectPath(bucketName, key))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("getting object %s/%s: %w", bucketName, key, ErrObjectNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting object error %v", err)
+	}
+	return string(content), nil
+}
+
+// ReadGeneration reads like Read, the generation being the modification time of the file
+func (fosc *fileObjectStorageCache) ReadGeneration(ctx context.Context, bucketName string, key string) (interface{}, int64, error) {
+	content, err := fosc.Read(ctx, bucketName, key)
+	if err != nil {
+		return "", 0, err
+	}
+	generation, err := fosc.generation(bucketName, key)
+	return content, generation, err
+}
+
+// WriteIfGeneration creates the file exclusively when 'generation' is 0, so only one of several
+// concurrent writers succeeds. Replacing an existing file is only checked against its modification time
+func (fosc *fileObjectStorageCache) WriteIfGeneration(ctx context.Context, bucketName string, key string,
+	content interface{}, generation int64) (int64, error) {
+	current, err := fosc.generation(bucketName, key)
+	if err != nil {
+		return 0, err
+	}
+	if current != generation {
+		return 0, fmt.Errorf("writing object %s/%s: %w", bucketName, key, ErrPreconditionFailed)
+	}
+
+	objectPath := fosc.objectPath(bucketName, key)
+	if generation == 0 {
+		if err := os.MkdirAll(filepath.Dir(objectPath), 0700); err != nil {
+			return 0, fmt.Errorf("error creating dir for %s: %v", objectPath, err)
+		}
+		file, err := os.OpenFile(objectPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			return 0, fmt.Errorf("writing object %s/%s: %w", bucketName, key, ErrPreconditionFailed)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error creating %s: %v", objectPath, err)
+		}
+		_, err = fmt.Fprintf(file, "%v", content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error writing %s: %v", objectPath, err)
+		}
+	} else {
+		if err := fosc.Write(ctx, bucketName, key, content); err != nil {
+			return 0, err
+		}
+		// the new generation must differ from the replaced one even on filesystems with coarse timestamps
+		modTime := time.Now()
+		if modTime.UnixNano() <= generation {
+			modTime = time.Unix(0, generation+1)
+		}
+		if err := os.Chtimes(objectPath, modTime, modTime); err != nil {
+			return 0, fmt.Errorf("error updating %s: %v", objectPath, err)
+		}
+	}
+	return fosc.generation(bucketName, key)
+}
+
+func (fosc *fileObjectStorageCache) Delete(ctx context.Context, bucketName string, key string) error {
+	err := os.Remove(fosc.objectPath(bucketName, key))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting object %s/%s: %v", bucketName, key, err)
+	}
+	return nil
+}
+
+// DeleteIfGeneration deletes the file only if its modification time is still 'generation'
+func (fosc *fileObjectStorageCache) DeleteIfGeneration(ctx context.Context, bucketName string, key string,
+	generation int64) error {
+	current, err := fosc.generation(bucketName, key)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+	if current != generation {
+		return fmt.Errorf("deleting object %s/%s: %w", bucketName, key, ErrPreconditionFailed)
+	}
+	return fosc.Delete(ctx, bucketName, key)
+}
+
+func (fosc *fileObjectStorageCache) List(ctx context.Context, bucketName string, prefix string) ([]string, error) {
+	bucketDir := filepath.Join(fosc.baseDir, bucketName)
+	var keys []string
+
+	err := filepath.Walk(bucketDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relativePath, _ := filepath.Rel(bucketDir, path)
+		key := filepath.ToSlash(relativePath)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("listing objects %s/%s: %v", bucketName, prefix, err)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// generation the modification time of the file of the object, 0 when it doesn't exist
+func (fosc *fileObjectStorageCache) generation(bucketName string, key string) (int64, error) {
+	info, err := os.Stat(fosc.objectPath(bucketName, key))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting object error %v", err)
+	}
+	return info.ModTime().UnixNano(), nil
+}
+
+func (fosc *fileObjectStorageCache) objectPath(bucketName string, key string) string {
+	return filepath.Join(fosc.baseDir, bucketName, filepath.FromSlash(key))
+}
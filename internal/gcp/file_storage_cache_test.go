@@ -0,0 +1,36 @@
+package gcp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileObjectStorageCache(t *testing.T) {
+	baseDir, _ := ioutil.TempDir("", "storage")
+	defer os.RemoveAll(baseDir)
+	storage := NewFileObjectStorageCache(baseDir)
+
+	_ = storage.Write(nil, "bucket", "history/dev/app/1.json", "first")
+	_ = storage.Write(nil, "bucket", "history/dev/app/2.json", "second")
+	_ = storage.Write(nil, "bucket", "history/prod/app/1.json", "third")
+
+	content, err := storage.Read(nil, "bucket", "history/dev/app/2.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", content)
+
+	keys, err := storage.List(nil, "bucket", "history/dev/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"history/dev/app/1.json", "history/dev/app/2.json"}, keys)
+
+	assert.Nil(t, storage.Delete(nil, "bucket", "history/dev/app/1.json"))
+	_, err = storage.Read(nil, "bucket", "history/dev/app/1.json")
+	assert.True(t, errors.Is(err, ErrObjectNotFound))
+
+	keys, err = storage.List(nil, "missing-bucket", "")
+	assert.Nil(t, err)
+	assert.Empty(t, keys)
+}
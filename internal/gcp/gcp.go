@@ -9,11 +9,45 @@ import (
 	"strings"
 
 	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/kubeconfig"
+	"github.com/iac-io/myiac/internal/naming"
 	"github.com/iac-io/myiac/internal/util"
 )
 
 type nodePoolList []map[string]interface{}
 
+// NodePool the settings of a live GKE node pool, as listed by gcloud
+type NodePool struct {
+	Name             string `json:"name"`
+	InitialNodeCount int    `json:"initialNodeCount"`
+	Config           struct {
+		MachineType string            `json:"machineType"`
+		DiskType    string            `json:"diskType"`
+		DiskSizeGb  int               `json:"diskSizeGb"`
+		Preemptible bool              `json:"preemptible"`
+		Spot        bool              `json:"spot"`
+		Labels      map[string]string `json:"labels"`
+		Taints      []NodeTaint       `json:"taints"`
+	} `json:"config"`
+	Autoscaling struct {
+		Enabled      bool `json:"enabled"`
+		MinNodeCount int  `json:"minNodeCount"`
+		MaxNodeCount int  `json:"maxNodeCount"`
+	} `json:"autoscaling"`
+	// InstanceGroupUrls the managed instance groups with the nodes of the pool, one per zone
+	InstanceGroupUrls []string `json:"instanceGroupUrls"`
+	// Locations the zones of the nodes
+	Locations []string `json:"locations"`
+	Status    string   `json:"status"`
+}
+
+// NodeTaint a taint of the nodes of a pool, the effect as the GKE API names it (i.e. NO_SCHEDULE)
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Effect string `json:"effect"`
+}
+
 // Deprecated
 // see provider.go / setup_environment.go
 func SetupEnvironment(projectId string) {
@@ -33,13 +67,16 @@ func ConfigureDocker() {
 }
 
 // Deprecated
-func SetupKubernetes(project string, zone string, environment string) {
+func SetupKubernetes(project string, location Location, environment string, prefix string) {
 	action := "container clusters get-credentials"
 	// gcloud container clusters get-credentials [cluster-name]
-	cmdTpl := "%s %s --zone %s --project %s"
-	clusterName := fmt.Sprintf("%s-%s", project, environment)
+	cmdTpl := "%s %s"
+	clusterName := naming.MustFor(project).ClusterName(project, environment, prefix)
+	if _, err := kubeconfig.Prepare(kubeconfig.GkeContext(project, location.String(), clusterName)); err != nil {
+		log.Fatal(err)
+	}
 
-	argsArray := util.StringTemplateToArgsArray(cmdTpl, action, clusterName, zone, project)
+	argsArray := locationArgs(util.StringTemplateToArgsArray(cmdTpl, action, clusterName), location, project)
 	cmd := commandline.New("gcloud", argsArray)
 	cmd.Run()
 	fmt.Println("Kubernetes setup completed")
@@ -64,10 +101,9 @@ func parseNodePoolList(jsonString string) nodePoolList {
 }
 
 // ListClusterNodePools list the node pools in the GKE cluster
-func ListClusterNodePools(project string, zone string, environment string) nodePoolList {
-	cmdTpl := "container node-pools list --cluster %s --zone %s --project %s --format json"
-	clusterName := fmt.Sprintf("%s-%s", project, environment)
-	argsArray := util.StringTemplateToArgsArray(cmdTpl, clusterName, zone, project)
+func ListClusterNodePools(project string, location Location, environment string, prefix string) nodePoolList {
+	clusterName := naming.MustFor(project).ClusterName(project, environment, prefix)
+	argsArray := nodePoolListArgs(project, clusterName, location)
 	cmd := commandline.New("gcloud", argsArray)
 	cmd.Run()
 	nodePools := parseNodePoolList(cmd.Output())
@@ -75,48 +111,175 @@ func ListClusterNodePools(project string, zone string, environment string) nodeP
 	return nodePools
 }
 
-// ResizePool change node-pool size
-func ResizePool(project string, env string, poolName string, poolSize string, zone string) {
-	log.Printf("Resizing Node Pool: %s to %s nodes", poolName, poolSize)
-	clusterName := fmt.Sprintf("%s-%s", project, env)
-	action := fmt.Sprintf("container clusters resize %s --node-pool %s --num-nodes %s --zone %s -q --verbosity info",
+// ListNodePools the node pools of the cluster 'clusterName', unlike ListClusterNodePools the name
+// isn't derived from the project and environment, so prefixed clusters can be listed too
+func ListNodePools(cmdRunner commandline.CommandRunner, project string, clusterName string,
+	location Location) ([]NodePool, error) {
+	cmdRunner.Setup("gcloud", nodePoolListArgs(project, clusterName, location))
+	cmdRunner.SetSuppressOutput(true)
+	output, err := cmdRunner.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error listing node pools of %s: %v", clusterName, err)
+	}
+	return parseNodePools(output.Output)
+}
+
+func nodePoolListArgs(project string, clusterName string, location Location) []string {
+	args := util.StringTemplateToArgsArray("container node-pools list --cluster %s", clusterName)
+	return append(locationArgs(args, location, project), "--format", "json")
+}
+
+func parseNodePools(jsonString string) ([]NodePool, error) {
+	var nodePools []NodePool
+	if strings.TrimSpace(jsonString) == "" {
+		return nodePools, nil
+	}
+	if err := json.Unmarshal([]byte(jsonString), &nodePools); err != nil {
+		return nil, fmt.Errorf("error parsing node pools: %v", err)
+	}
+	return nodePools, nil
+}
+
+// DescribeNodePool the settings of a node pool of the cluster
+func DescribeNodePool(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	poolName string) (*NodePool, error) {
+	args := util.StringTemplateToArgsArray("container node-pools describe %s --cluster %s", poolName, clusterName)
+	cmdRunner.Setup("gcloud", append(locationArgs(args, location, project), "--format", "json"))
+	cmdRunner.SetSuppressOutput(true)
+	defer cmdRunner.SetSuppressOutput(false)
+	output, err := cmdRunner.RunWithError()
+	if err != nil {
+		return nil, fmt.Errorf("error describing node pool %s: %v", poolName, err)
+	}
+
+	var nodePool NodePool
+	if err := json.Unmarshal([]byte(output.Output), &nodePool); err != nil {
+		return nil, fmt.Errorf("error parsing node pool %s: %v", poolName, err)
+	}
+	return &nodePool, nil
+}
+
+// NodePoolSize the current number of nodes per zone of a pool, the size ResizeNodePool takes: the
+// target size of its instance groups (one per zone), as the node pool only knows its initial node count
+func NodePoolSize(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	poolName string) (int, error) {
+	nodePool, err := DescribeNodePool(cmdRunner, project, clusterName, location, poolName)
+	if err != nil {
+		return 0, err
+	}
+
+	size := 0
+	for _, instanceGroupUrl := range nodePool.InstanceGroupUrls {
+		cmdRunner.Setup("gcloud", []string{"compute", "instance-groups", "managed", "describe", instanceGroupUrl,
+			"--format", "value(targetSize)"})
+		output, err := cmdRunner.RunWithError()
+		if err != nil {
+			return 0, fmt.Errorf("error describing instance group of node pool %s: %v", poolName, err)
+		}
+		targetSize, err := strconv.Atoi(strings.TrimSpace(output.Output))
+		if err != nil {
+			return 0, fmt.Errorf("invalid size of instance group %s: %v", instanceGroupUrl, err)
+		}
+		// every zone of the pool has the same number of nodes
+		if targetSize > size {
+			size = targetSize
+		}
+	}
+	return size, nil
+}
+
+// ResizeNodePool like ResizePool, returning the error instead of exiting. 'size' is the number of
+// nodes per zone
+func ResizeNodePool(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	poolName string, size int) error {
+	zones := 1
+	if location.IsRegional() {
+		nodePool, err := DescribeNodePool(cmdRunner, project, clusterName, location, poolName)
+		if err != nil {
+			return err
+		}
+		zones = len(nodePool.Locations)
+	}
+
+	log.Printf("Resizing Node Pool: %s to %s", poolName, describeSize(size, zones))
+	cmdTpl := "container clusters resize %s --node-pool %s --num-nodes %s"
+	args := util.StringTemplateToArgsArray(cmdTpl, clusterName, poolName, strconv.Itoa(size))
+	cmdRunner.Setup("gcloud", append(locationArgs(args, location, project), "-q"))
+	if _, err := cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error resizing node pool %s: %v", poolName, err)
+	}
+	log.Printf("Node Pool: %s resized to %s", poolName, describeSize(size, zones))
+	return nil
+}
+
+// describeSize explains node counts of pools spanning several zones, --num-nodes is per zone
+func describeSize(nodesPerZone int, zones int) string {
+	if zones <= 1 {
+		return fmt.Sprintf("%d nodes", nodesPerZone)
+	}
+	return fmt.Sprintf("%d nodes per zone in %d zones (%d nodes in total)", nodesPerZone, zones, nodesPerZone*zones)
+}
+
+// ResizePool change node-pool size, 'poolSize' nodes per zone
+func ResizePool(cmdRunner commandline.CommandRunner, clusterName string, poolName string, poolSize string,
+	location Location) error {
+	zoneNote := ""
+	if location.IsRegional() {
+		zoneNote = " per zone"
+	}
+	log.Printf("Resizing Node Pool: %s to %s nodes%s", poolName, poolSize, zoneNote)
+	action := fmt.Sprintf("container clusters resize %s --node-pool %s --num-nodes %s",
 		clusterName,
 		poolName,
-		poolSize, zone)
-	cmdArray := util.StringTemplateToArgsArray(action)
-	cmd := commandline.New("gcloud", cmdArray)
-	cmd.Run()
-	log.Printf("Node Pool: %s resized to %s nodes", poolName, poolSize)
+		poolSize)
+	cmdArray := append(append(util.StringTemplateToArgsArray(action), location.Args()...), "-q", "--verbosity", "info")
+	cmdRunner.Setup("gcloud", cmdArray)
+	if _, err := cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error resizing node pool %s: %v", poolName, err)
+	}
+	log.Printf("Node Pool: %s resized to %s nodes%s", poolName, poolSize, zoneNote)
+	return nil
 }
 
-// ResizeCluster change the size of GKE cluster node pools to the provided values
+// ResizeCluster change the size of the node pools of the GKE cluster of 'environment' (named with
+// 'prefix', when given) to the provided values
 //
 // The resize command needs to be executed once per node pool:
 //
 // gcloud container clusters resize NAME (--num-nodes=NUM_NODES | --size=NUM_NODES) [--async]
 // [--node-pool=NODE_POOL] [--region=REGION | --zone=ZONE, -z ZONE]
-func ResizeCluster(project string, zone string, environment string, targetSize int) {
-	clusterName := fmt.Sprintf("%s-%s", project, environment)
+func ResizeCluster(cmdRunner commandline.CommandRunner, project string, location Location, environment string,
+	prefix string, targetSize int) error {
+	clusterName := naming.MustFor(project).ClusterName(project, environment, prefix)
 	action := "container clusters resize"
 
-	nodePools := ListClusterNodePools(project, zone, environment)
+	nodePools, err := ListNodePools(cmdRunner, project, clusterName, location)
+	if err != nil {
+		return err
+	}
+	cmdRunner.SetSuppressOutput(false)
 	nodePoolResizeTpl := "--node-pool %s --num-nodes %s"
 
 	for _, np := range nodePools {
-		nodePoolName := np["name"]
-		nodePoolNameStr, _ := nodePoolName.(string)
-		fmt.Printf("Resizing node pool %s to %d\n", nodePoolName, targetSize)
+		zones := 1
+		if location.IsRegional() && len(np.Locations) > 0 {
+			zones = len(np.Locations)
+		}
+		fmt.Printf("Resizing node pool %s to %s\n", np.Name, describeSize(targetSize, zones))
 		targetSizeStr := strconv.Itoa(targetSize)
-		nodePoolResizeArray := util.StringTemplateToArgsArray(nodePoolResizeTpl, nodePoolNameStr, targetSizeStr)
+		nodePoolResizeArray := util.StringTemplateToArgsArray(nodePoolResizeTpl, np.Name, targetSizeStr)
 		nodePoolResizePart := strings.Join(nodePoolResizeArray, " ")
 
-		cmdTpl := "%s %s %s --zone %s --project %s -q"
-		argsArray := util.StringTemplateToArgsArray(cmdTpl, action, clusterName, nodePoolResizePart, zone, project)
-		cmd := commandline.New("gcloud", argsArray)
-		cmd.Run()
-		fmt.Printf("Node Pool %s resized", nodePoolName)
+		cmdTpl := "%s %s %s"
+		argsArray := util.StringTemplateToArgsArray(cmdTpl, action, clusterName, nodePoolResizePart)
+		cmdRunner.Setup("gcloud", append(locationArgs(argsArray, location, project), "-q"))
+		if _, err := cmdRunner.RunWithError(); err != nil {
+			return fmt.Errorf("error resizing node pool %s: %v", np.Name, err)
+		}
+		fmt.Printf("Node Pool %s resized\n", np.Name)
 	}
 	fmt.Println("Cluster resized")
+	return nil
 }
 
 // Set OS Environment Variable so the key file is available gor gcloud cli
@@ -0,0 +1,109 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const nodePoolsJson = `[
+  {
+    "name": "default-pool",
+    "initialNodeCount": 1,
+    "config": {"machineType": "n1-standard-2", "preemptible": true, "diskSizeGb": 10},
+    "autoscaling": {"enabled": true, "minNodeCount": 1, "maxNodeCount": 3},
+    "status": "RUNNING"
+  }
+]`
+
+func TestListNodePoolsOfCluster(t *testing.T) {
+	cmdLine := "gcloud container node-pools list --cluster app-moneycol-dev --zone europe-west2-b --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(cmdLine, nodePoolsJson)
+
+	nodePools, err := ListNodePools(runner, "moneycol", "app-moneycol-dev", "europe-west2-b")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{cmdLine}, runner.GetCmdLines())
+	assert.Len(t, nodePools, 1)
+	assert.Equal(t, "default-pool", nodePools[0].Name)
+	assert.Equal(t, "n1-standard-2", nodePools[0].Config.MachineType)
+	assert.True(t, nodePools[0].Config.Preemptible)
+	assert.Equal(t, 3, nodePools[0].Autoscaling.MaxNodeCount)
+}
+
+func TestListNodePoolsFailure(t *testing.T) {
+	cmdLine := "gcloud container node-pools list --cluster moneycol-dev --zone europe-west2-b --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommandError(cmdLine, 1)
+
+	_, err := ListNodePools(runner, "moneycol", "moneycol-dev", "europe-west2-b")
+
+	assert.NotNil(t, err)
+}
+
+func TestNodePoolSizeSumsItsInstanceGroups(t *testing.T) {
+	describeCmd := "gcloud container node-pools describe default-pool --cluster moneycol-dev --zone europe-west2-b --project moneycol --format json"
+	igUrl := "https://www.googleapis.com/compute/v1/projects/moneycol/zones/europe-west2-b/instanceGroupManagers/gke-pool-grp"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(describeCmd, `{"name": "default-pool", "initialNodeCount": 1, "instanceGroupUrls": ["`+igUrl+`"]}`)
+	runner.FakeCommand("gcloud compute instance-groups managed describe "+igUrl+" --format value(targetSize)", "3\n")
+
+	size, err := NodePoolSize(runner, "moneycol", "moneycol-dev", "europe-west2-b", "default-pool")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, size)
+}
+
+func TestResizeNodePool(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+
+	err := ResizeNodePool(runner, "moneycol", "moneycol-dev", "europe-west2-b", "default-pool", 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"gcloud container clusters resize moneycol-dev --node-pool default-pool --num-nodes 0 " +
+		"--zone europe-west2-b --project moneycol -q"}, runner.GetCmdLines())
+}
+
+func TestResizeNodePoolOfRegionalCluster(t *testing.T) {
+	describeCmd := "gcloud container node-pools describe default-pool --cluster moneycol-prod --region europe-west2 --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(describeCmd, `{"name": "default-pool", "locations": ["europe-west2-a", "europe-west2-b", "europe-west2-c"]}`)
+
+	err := ResizeNodePool(runner, "moneycol", "moneycol-prod", "europe-west2", "default-pool", 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{describeCmd, "gcloud container clusters resize moneycol-prod --node-pool default-pool " +
+		"--num-nodes 2 --region europe-west2 --project moneycol -q"}, runner.GetCmdLines())
+}
+
+func TestNodePoolSizeIsPerZone(t *testing.T) {
+	describeCmd := "gcloud container node-pools describe default-pool --cluster moneycol-prod --region europe-west2 --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(describeCmd, `{"name": "default-pool", "instanceGroupUrls": ["zone-a-grp", "zone-b-grp"]}`)
+	runner.FakeCommand("gcloud compute instance-groups managed describe zone-a-grp --format value(targetSize)", "2")
+	runner.FakeCommand("gcloud compute instance-groups managed describe zone-b-grp --format value(targetSize)", "2")
+
+	size, err := NodePoolSize(runner, "moneycol", "moneycol-prod", "europe-west2", "default-pool")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, size)
+}
+
+func TestDescribeSizeExplainsNodesPerZone(t *testing.T) {
+	assert.Equal(t, "3 nodes", describeSize(3, 1))
+	assert.Equal(t, "2 nodes per zone in 3 zones (6 nodes in total)", describeSize(2, 3))
+}
+
+func TestResizeClusterOfPrefixedCluster(t *testing.T) {
+	listCmd := "gcloud container node-pools list --cluster app-moneycol-dev --zone europe-west2-b --project moneycol --format json"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(listCmd, nodePoolsJson)
+
+	err := ResizeCluster(runner, "moneycol", "europe-west2-b", "dev", "app", 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{listCmd, "gcloud container clusters resize app-moneycol-dev --node-pool default-pool " +
+		"--num-nodes 0 --zone europe-west2-b --project moneycol -q"}, runner.GetCmdLines())
+}
@@ -3,16 +3,27 @@ package gcp
 //TODO: move this into gcp package as subpackage?
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net/http"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/iterator"
 )
 
 const KeysCacheBucketName = "moneycol-keys"
 
+// ErrObjectNotFound is returned (wrapped) by ObjectStorageCache reads when the key does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrPreconditionFailed is returned (wrapped) by conditional writes when the object changed since it was read
+var ErrPreconditionFailed = errors.New("object precondition failed")
+
 // IamClient wrapper interface around GCP Service Account Client that allows creation and listing of Service Account keys.
 // Instead of extracting the exact methods from GCP *iam.Service, they are wrapped on these 2 operations (CreateKey, ListKeys).
 // This is due to the complicated call chains inside *iam.Service (i.e. 'Projects.ServiceAccounts.Keys.Create(...)')
@@ -61,11 +72,21 @@ type ObjectStorageGcpClient interface {
 	Bucket(bucketName string) *storage.BucketHandle
 }
 
-// ObjectStorageCache allows management of basic object storage operations in GCP (write, read).
+// ObjectStorageCache allows management of basic object storage operations in GCP (write, read, delete, list).
 // Abstracts the operations needed from the GCP SDK
 type ObjectStorageCache interface {
 	Write(ctx context.Context, bucketName string, key string, content interface{}) error
 	Read(ctx context.Context, bucketName string, objectKey string) (interface{}, error)
+	Delete(ctx context.Context, bucketName string, objectKey string) error
+	List(ctx context.Context, bucketName string, prefix string) ([]string, error)
+	// ReadGeneration reads like Read and also returns the generation of the object, to update it with WriteIfGeneration
+	ReadGeneration(ctx context.Context, bucketName string, objectKey string) (interface{}, int64, error)
+	// WriteIfGeneration writes only while the object is still at 'generation', 0 meaning it must not exist yet,
+	// failing with ErrPreconditionFailed otherwise. Returns the generation written
+	WriteIfGeneration(ctx context.Context, bucketName string, key string, content interface{}, generation int64) (int64, error)
+	// DeleteIfGeneration deletes only while the object is still at 'generation', failing with
+	// ErrPreconditionFailed otherwise
+	DeleteIfGeneration(ctx context.Context, bucketName string, objectKey string, generation int64) error
 }
 
 // gcpObjectStorageCache Implements 'ObjectStorageCache' and uses the interface
@@ -122,6 +143,12 @@ func (gosc *gcpObjectStorageCache) Write(ctx context.Context, bucketName string,
 // Read performs a 'read' from bucket 'bucketName' on key 'key'. A passed Context could be used
 // to regenerate the client
 func (gosc *gcpObjectStorageCache) Read(ctx context.Context, bucketName string, key string) (interface{}, error) {
+	content, _, err := gosc.ReadGeneration(ctx, bucketName, key)
+	return content, err
+}
+
+// ReadGeneration reads like Read, returning as well the generation of the object read
+func (gosc *gcpObjectStorageCache) ReadGeneration(ctx context.Context, bucketName string, key string) (interface{}, int64, error) {
 	if ctx != nil {
 		gosc.client = getObjectStorageClient(ctx)
 	} else {
@@ -134,8 +161,11 @@ func (gosc *gcpObjectStorageCache) Read(ctx context.Context, bucketName string,
 	//TODO: should list the bucket first, see:
 	// https://godoc.org/cloud.google.com/go/storage
 	r, err := obj.NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return "", 0, fmt.Errorf("getting object %s/%s: %w", bucketName, key, ErrObjectNotFound)
+	}
 	if err != nil {
-		return "", fmt.Errorf("getting object error %v", err)
+		return "", 0, fmt.Errorf("getting object error %v", err)
 	}
 
 	defer r.Close()
@@ -143,12 +173,107 @@ func (gosc *gcpObjectStorageCache) Read(ctx context.Context, bucketName string,
 	buf := new(strings.Builder)
 
 	if _, copyErr := io.Copy(buf, r); copyErr != nil {
-		return "", fmt.Errorf("error copying to stdout %v", copyErr)
+		return "", 0, fmt.Errorf("error copying to stdout %v", copyErr)
 	}
 
 	keyString := buf.String()
-	fmt.Printf("Found value %s\n", keyString)
-	return keyString, nil
+	log.Printf("Found object %s/%s\n", bucketName, key)
+	return keyString, r.Attrs.Generation, nil
+}
+
+// WriteIfGeneration writes 'objectContent' with a GCS precondition on the generation of the object, so
+// the write only happens if nobody else wrote it since it was read
+func (gosc *gcpObjectStorageCache) WriteIfGeneration(ctx context.Context, bucketName string, key string,
+	objectContent interface{}, generation int64) (int64, error) {
+	if ctx != nil {
+		gosc.client = getObjectStorageClient(ctx)
+	} else {
+		ctx = context.Background()
+	}
+
+	conditions := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+	w := gosc.client.Bucket(bucketName).Object(key).If(conditions).NewWriter(ctx)
+
+	if _, err := fmt.Fprintf(w, "%v", objectContent); err != nil {
+		return 0, err
+	}
+
+	if err := w.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return 0, fmt.Errorf("writing object %s/%s: %w", bucketName, key, ErrPreconditionFailed)
+		}
+		return 0, err
+	}
+
+	return w.Attrs().Generation, nil
+}
+
+// Delete removes the object stored under 'key' in bucket 'bucketName'. Deleting a key
+// that does not exist is not considered an error
+func (gosc *gcpObjectStorageCache) Delete(ctx context.Context, bucketName string, key string) error {
+	if ctx != nil {
+		gosc.client = getObjectStorageClient(ctx)
+	} else {
+		ctx = context.Background()
+	}
+
+	obj := gosc.client.Bucket(bucketName).Object(key)
+	if err := obj.Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+		return fmt.Errorf("deleting object %s/%s: %v", bucketName, key, err)
+	}
+
+	return nil
+}
+
+// DeleteIfGeneration deletes the object with a GCS precondition on its generation, so it isn't deleted
+// if somebody else wrote it since it was read. Deleting a key that does not exist is not an error
+func (gosc *gcpObjectStorageCache) DeleteIfGeneration(ctx context.Context, bucketName string, key string,
+	generation int64) error {
+	if ctx != nil {
+		gosc.client = getObjectStorageClient(ctx)
+	} else {
+		ctx = context.Background()
+	}
+
+	obj := gosc.client.Bucket(bucketName).Object(key).If(storage.Conditions{GenerationMatch: generation})
+	err := obj.Delete(ctx)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("deleting object %s/%s: %w", bucketName, key, ErrPreconditionFailed)
+	}
+	if err != nil && err != storage.ErrObjectNotExist {
+		return fmt.Errorf("deleting object %s/%s: %v", bucketName, key, err)
+	}
+
+	return nil
+}
+
+// List returns the keys of the objects in bucket 'bucketName' starting with 'prefix'
+func (gosc *gcpObjectStorageCache) List(ctx context.Context, bucketName string, prefix string) ([]string, error) {
+	if ctx != nil {
+		gosc.client = getObjectStorageClient(ctx)
+	} else {
+		ctx = context.Background()
+	}
+
+	var keys []string
+	objects := gosc.client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing objects %s/%s: %v", bucketName, prefix, err)
+		}
+		keys = append(keys, attrs.Name)
+	}
+
+	return keys, nil
 }
 
 func getObjectStorageClient(ctx context.Context) ObjectStorageGcpClient {
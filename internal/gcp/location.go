@@ -0,0 +1,40 @@
+package gcp
+
+import (
+	"strings"
+)
+
+// Location where a GKE cluster lives: a zone (europe-west2-b) for zonal clusters or a region
+// (europe-west2) for regional ones, whose node pools have nodes in several zones
+type Location string
+
+// IsRegional a region has no zone suffix: europe-west2 vs europe-west2-b
+func (l Location) IsRegional() bool {
+	return strings.Count(string(l), "-") == 1
+}
+
+// Region the region of the location
+func (l Location) Region() string {
+	i := strings.LastIndex(string(l), "-")
+	if l.IsRegional() || i < 0 {
+		return string(l)
+	}
+	return string(l)[:i]
+}
+
+// Args the gcloud flag selecting the location, --region or --zone
+func (l Location) Args() []string {
+	if l.IsRegional() {
+		return []string{"--region", string(l)}
+	}
+	return []string{"--zone", string(l)}
+}
+
+func (l Location) String() string {
+	return string(l)
+}
+
+// locationArgs 'args' followed by the location and project flags
+func locationArgs(args []string, location Location, project string) []string {
+	return append(append(args, location.Args()...), "--project", project)
+}
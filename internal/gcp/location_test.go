@@ -0,0 +1,23 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneLocation(t *testing.T) {
+	location := Location("europe-west2-b")
+
+	assert.False(t, location.IsRegional())
+	assert.Equal(t, "europe-west2", location.Region())
+	assert.Equal(t, []string{"--zone", "europe-west2-b"}, location.Args())
+}
+
+func TestRegionLocation(t *testing.T) {
+	location := Location("europe-west2")
+
+	assert.True(t, location.IsRegional())
+	assert.Equal(t, "europe-west2", location.Region())
+	assert.Equal(t, []string{"--region", "europe-west2"}, location.Args())
+}
@@ -0,0 +1,241 @@
+package gcp
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/util"
+)
+
+// taint effects as gcloud takes them, with the names the GKE API returns
+var taintEffects = map[string]string{
+	"NoSchedule":       "NO_SCHEDULE",
+	"PreferNoSchedule": "PREFER_NO_SCHEDULE",
+	"NoExecute":        "NO_EXECUTE",
+}
+
+// NodePoolSpec the settings of a node pool to create
+type NodePoolSpec struct {
+	Name        string
+	MachineType string
+	NodeCount   int
+	Preemptible bool
+	Spot        bool
+	Labels      map[string]string
+	// Taints as key=value:effect, the effect one of NoSchedule, PreferNoSchedule or NoExecute
+	Taints []string
+	// MinNodes and MaxNodes enable autoscaling when MaxNodes is set
+	MinNodes int
+	MaxNodes int
+}
+
+// Validate checks the spec before any GKE operation is started
+func (s NodePoolSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("node pool name is required")
+	}
+	if s.Preemptible && s.Spot {
+		return fmt.Errorf("node pool %s can't be both preemptible and spot", s.Name)
+	}
+	if s.NodeCount < 0 {
+		return fmt.Errorf("invalid node count %d for node pool %s", s.NodeCount, s.Name)
+	}
+	if s.MaxNodes != 0 {
+		if err := validateAutoscaling(s.MinNodes, s.MaxNodes); err != nil {
+			return err
+		}
+	}
+	for _, taint := range s.Taints {
+		if _, err := ParseTaint(taint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAutoscaling(minNodes int, maxNodes int) error {
+	if minNodes < 0 || maxNodes < 1 || minNodes > maxNodes {
+		return fmt.Errorf("invalid autoscaling limits min %d, max %d", minNodes, maxNodes)
+	}
+	return nil
+}
+
+// ParseTaint parses a key=value:effect taint
+func ParseTaint(taint string) (NodeTaint, error) {
+	i := strings.LastIndex(taint, ":")
+	if i < 0 {
+		return NodeTaint{}, fmt.Errorf("invalid taint '%s', expected key=value:effect", taint)
+	}
+	keyValue, effect := taint[:i], taint[i+1:]
+	apiEffect, ok := taintEffects[effect]
+	if !ok {
+		return NodeTaint{}, fmt.Errorf("invalid taint effect '%s', use NoSchedule, PreferNoSchedule or NoExecute", effect)
+	}
+
+	parts := strings.SplitN(keyValue, "=", 2)
+	if parts[0] == "" {
+		return NodeTaint{}, fmt.Errorf("invalid taint '%s', the key is empty", taint)
+	}
+	nodeTaint := NodeTaint{Key: parts[0], Effect: apiEffect}
+	if len(parts) == 2 {
+		nodeTaint.Value = parts[1]
+	}
+	return nodeTaint, nil
+}
+
+// ParseLabels parses key=value labels
+func ParseLabels(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label '%s', expected key=value", label)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+// CreateNodePool creates a node pool in the cluster and waits for it to be running
+func CreateNodePool(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	spec NodePoolSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	args := locationArgs(util.StringTemplateToArgsArray("container node-pools create %s --cluster %s", spec.Name,
+		clusterName), location, project)
+	// per zone on regional clusters
+	args = append(args, "--num-nodes", strconv.Itoa(spec.NodeCount))
+	// without it GKE uses its default machine type
+	if spec.MachineType != "" {
+		args = append(args, "--machine-type", spec.MachineType)
+	}
+	if spec.Preemptible {
+		args = append(args, "--preemptible")
+	}
+	if spec.Spot {
+		args = append(args, "--spot")
+	}
+	if len(spec.Labels) > 0 {
+		args = append(args, "--node-labels", joinLabels(spec.Labels))
+	}
+	if len(spec.Taints) > 0 {
+		args = append(args, "--node-taints", strings.Join(spec.Taints, ","))
+	}
+	if spec.MaxNodes != 0 {
+		args = append(args, autoscalingArgs(spec.MinNodes, spec.MaxNodes)...)
+	}
+
+	log.Printf("Creating node pool %s in %s\n", spec.Name, clusterName)
+	if err := runOperation(cmdRunner, project, clusterName, location, args); err != nil {
+		return fmt.Errorf("error creating node pool %s: %v", spec.Name, err)
+	}
+	log.Printf("Node pool %s created\n", spec.Name)
+	return nil
+}
+
+// DeleteNodePool deletes a node pool of the cluster and waits for its nodes to be gone
+func DeleteNodePool(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	poolName string) error {
+	args := util.StringTemplateToArgsArray("container node-pools delete %s --cluster %s", poolName, clusterName)
+	args = append(locationArgs(args, location, project), "-q")
+
+	log.Printf("Deleting node pool %s from %s\n", poolName, clusterName)
+	if err := runOperation(cmdRunner, project, clusterName, location, args); err != nil {
+		return fmt.Errorf("error deleting node pool %s: %v", poolName, err)
+	}
+	log.Printf("Node pool %s deleted\n", poolName)
+	return nil
+}
+
+// SetNodePoolAutoscaling enables autoscaling of a node pool between 'minNodes' and 'maxNodes', or
+// disables it when 'maxNodes' is 0
+func SetNodePoolAutoscaling(cmdRunner commandline.CommandRunner, project string, clusterName string,
+	location Location, poolName string, minNodes int, maxNodes int) error {
+	args := util.StringTemplateToArgsArray("container clusters update %s --node-pool %s", clusterName, poolName)
+	args = locationArgs(args, location, project)
+	if maxNodes == 0 {
+		args = append(args, "--no-enable-autoscaling")
+	} else {
+		if err := validateAutoscaling(minNodes, maxNodes); err != nil {
+			return err
+		}
+		args = append(args, autoscalingArgs(minNodes, maxNodes)...)
+	}
+
+	if err := runOperation(cmdRunner, project, clusterName, location, args); err != nil {
+		return fmt.Errorf("error updating autoscaling of node pool %s: %v", poolName, err)
+	}
+	return nil
+}
+
+func autoscalingArgs(minNodes int, maxNodes int) []string {
+	return []string{"--enable-autoscaling", "--min-nodes", strconv.Itoa(minNodes), "--max-nodes", strconv.Itoa(maxNodes)}
+}
+
+func joinLabels(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// runOperation GKE runs one operation at a time per cluster: the running ones are waited for, then
+// the operation is started asynchronously and waited for until it finishes
+func runOperation(cmdRunner commandline.CommandRunner, project string, clusterName string, location Location,
+	args []string) error {
+	if err := WaitForOperations(cmdRunner, project, clusterName, location); err != nil {
+		return err
+	}
+
+	cmdRunner.Setup("gcloud", append(args, "--async", "--format", "value(name)"))
+	output, err := cmdRunner.RunWithError()
+	if err != nil {
+		return err
+	}
+
+	for _, operation := range strings.Fields(output.Output) {
+		if err := waitForOperation(cmdRunner, project, location, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForOperations waits for the running operations of the cluster to finish
+func WaitForOperations(cmdRunner commandline.CommandRunner, project string, clusterName string,
+	location Location) error {
+	filter := fmt.Sprintf("--filter=status=RUNNING AND targetLink~/clusters/%s(/|$)", clusterName)
+	args := locationArgs([]string{"container", "operations", "list"}, location, project)
+	cmdRunner.Setup("gcloud", append(args, "--format", "value(name)", filter))
+	cmdRunner.SetSuppressOutput(true)
+	output, err := cmdRunner.RunWithError()
+	cmdRunner.SetSuppressOutput(false)
+	if err != nil {
+		return fmt.Errorf("error listing operations of %s: %v", clusterName, err)
+	}
+
+	for _, operation := range strings.Fields(output.Output) {
+		log.Printf("Waiting for running operation %s of %s\n", operation, clusterName)
+		if err := waitForOperation(cmdRunner, project, location, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitForOperation(cmdRunner commandline.CommandRunner, project string, location Location, operation string) error {
+	args := []string{"container", "operations", "wait", operation}
+	cmdRunner.Setup("gcloud", locationArgs(args, location, project))
+	if _, err := cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error waiting for operation %s: %v", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const operationsListCmd = "gcloud container operations list --zone europe-west2-b --project moneycol " +
+	"--format value(name) --filter=status=RUNNING AND targetLink~/clusters/moneycol-dev(/|$)"
+
+func TestCreateNodePoolWaitsForItsOperation(t *testing.T) {
+	createCmd := "gcloud container node-pools create batch-pool --cluster moneycol-dev --zone europe-west2-b " +
+		"--project moneycol --num-nodes 1 --machine-type e2-standard-4 --spot --node-labels tier=batch,workload=jobs " +
+		"--node-taints dedicated=batch:NoSchedule --enable-autoscaling --min-nodes 0 --max-nodes 3 --async --format value(name)"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(createCmd, "operation-123\n")
+	spec := NodePoolSpec{
+		Name:        "batch-pool",
+		MachineType: "e2-standard-4",
+		NodeCount:   1,
+		Spot:        true,
+		Labels:      map[string]string{"workload": "jobs", "tier": "batch"},
+		Taints:      []string{"dedicated=batch:NoSchedule"},
+		MaxNodes:    3,
+	}
+
+	err := CreateNodePool(runner, "moneycol", "moneycol-dev", "europe-west2-b", spec)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		operationsListCmd,
+		createCmd,
+		"gcloud container operations wait operation-123 --zone europe-west2-b --project moneycol",
+	}, runner.GetCmdLines())
+}
+
+func TestDeleteNodePoolWaitsForRunningOperations(t *testing.T) {
+	deleteCmd := "gcloud container node-pools delete batch-pool --cluster moneycol-dev --zone europe-west2-b " +
+		"--project moneycol -q --async --format value(name)"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommand(operationsListCmd, "operation-upgrade\n")
+	runner.FakeCommand(deleteCmd, "operation-delete\n")
+
+	err := DeleteNodePool(runner, "moneycol", "moneycol-dev", "europe-west2-b", "batch-pool")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		operationsListCmd,
+		"gcloud container operations wait operation-upgrade --zone europe-west2-b --project moneycol",
+		deleteCmd,
+		"gcloud container operations wait operation-delete --zone europe-west2-b --project moneycol",
+	}, runner.GetCmdLines())
+}
+
+func TestCreateNodePoolFailure(t *testing.T) {
+	createCmd := "gcloud container node-pools create batch-pool --cluster moneycol-dev --zone europe-west2-b " +
+		"--project moneycol --num-nodes 1 --async --format value(name)"
+	runner := testutil.FakeCommandRunner("")
+	runner.FakeCommandError(createCmd, 1)
+
+	err := CreateNodePool(runner, "moneycol", "moneycol-dev", "europe-west2-b", NodePoolSpec{Name: "batch-pool", NodeCount: 1})
+
+	assert.NotNil(t, err)
+}
+
+func TestSetNodePoolAutoscaling(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+
+	err := SetNodePoolAutoscaling(runner, "moneycol", "moneycol-dev", "europe-west2-b", "default-pool", 0, 0)
+
+	assert.Nil(t, err)
+	assert.Contains(t, runner.GetCmdLines(), "gcloud container clusters update moneycol-dev --node-pool default-pool "+
+		"--zone europe-west2-b --project moneycol --no-enable-autoscaling --async --format value(name)")
+	assert.NotNil(t, SetNodePoolAutoscaling(runner, "moneycol", "moneycol-dev", "europe-west2-b", "default-pool", 3, 1))
+}
+
+func TestInvalidNodePoolSpecs(t *testing.T) {
+	assert.NotNil(t, NodePoolSpec{}.Validate())
+	assert.NotNil(t, NodePoolSpec{Name: "pool", Preemptible: true, Spot: true}.Validate())
+	assert.NotNil(t, NodePoolSpec{Name: "pool", Taints: []string{"dedicated=batch"}}.Validate())
+	assert.NotNil(t, NodePoolSpec{Name: "pool", Taints: []string{"dedicated=batch:Never"}}.Validate())
+	assert.Nil(t, NodePoolSpec{Name: "pool", Taints: []string{"dedicated=batch:NoExecute"}}.Validate())
+}
+
+func TestParseTaintAndLabels(t *testing.T) {
+	taint, err := ParseTaint("dedicated=batch:PreferNoSchedule")
+	assert.Nil(t, err)
+	assert.Equal(t, NodeTaint{Key: "dedicated", Value: "batch", Effect: "PREFER_NO_SCHEDULE"}, taint)
+
+	labels, err := ParseLabels([]string{"workload=jobs", "empty="})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"workload": "jobs", "empty": ""}, labels)
+	_, err = ParseLabels([]string{"workload"})
+	assert.NotNil(t, err)
+}
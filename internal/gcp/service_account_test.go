@@ -79,11 +79,38 @@ func (m *fakeObjectStorageCache) Write(ctx context.Context, bucketName string, k
 	return args.Error(0)
 }
 
-func (m fakeObjectStorageCache) Read(ctx context.Context, bucketName string, objectKey string) (interface{}, error) {
+func (m *fakeObjectStorageCache) Read(ctx context.Context, bucketName string, objectKey string) (interface{}, error) {
 	args := m.Called(ctx, bucketName, objectKey)
 	return args.Get(0).(interface{}), args.Error(1)
 }
 
+func (m *fakeObjectStorageCache) List(ctx context.Context, bucketName string, prefix string) ([]string, error) {
+	args := m.Called(ctx, bucketName, prefix)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *fakeObjectStorageCache) Delete(ctx context.Context, bucketName string, objectKey string) error {
+	args := m.Called(ctx, bucketName, objectKey)
+	return args.Error(0)
+}
+
+func (m *fakeObjectStorageCache) DeleteIfGeneration(ctx context.Context, bucketName string, objectKey string,
+	generation int64) error {
+	args := m.Called(ctx, bucketName, objectKey, generation)
+	return args.Error(0)
+}
+
+func (m *fakeObjectStorageCache) ReadGeneration(ctx context.Context, bucketName string, objectKey string) (interface{}, int64, error) {
+	args := m.Called(ctx, bucketName, objectKey)
+	return args.Get(0).(interface{}), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *fakeObjectStorageCache) WriteIfGeneration(ctx context.Context, bucketName string, key string,
+	content interface{}, generation int64) (int64, error) {
+	args := m.Called(ctx, bucketName, key, content, generation)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestCreateNewKey(t *testing.T) {
 	kg := newKeyGenerator("testKey", "testData")
 	mockIamClient := newFakeIamClient(kg)
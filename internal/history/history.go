@@ -0,0 +1,146 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const (
+	ResultStarted   = "started"
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+
+	historyPrefix = "history"
+)
+
+// Record what was deployed, where, by whom and how it went
+type Record struct {
+	Project      string        `json:"project"`
+	App          string        `json:"app"`
+	Env          string        `json:"env"`
+	ChartVersion string        `json:"chartVersion"`
+	ImageTag     string        `json:"imageTag"`
+	ValuesHash   string        `json:"valuesHash"`
+	GitCommit    string        `json:"gitCommit"`
+	User         string        `json:"user"`
+	Result       string        `json:"result"`
+	Error        string        `json:"error,omitempty"`
+	StartedAt    time.Time     `json:"startedAt"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// NewRecord starts the record of a deployment of 'app' into 'env' by the current user
+func NewRecord(project string, app string, env string) *Record {
+	return &Record{
+		Project:   project,
+		App:       app,
+		Env:       env,
+		User:      util.CurrentUserIdentity(),
+		Result:    ResultStarted,
+		StartedAt: time.Now().UTC(),
+	}
+}
+
+// Finish sets the result and duration of the deployment from the error it ended with (if any)
+func (r *Record) Finish(err error) {
+	r.Duration = time.Since(r.StartedAt).Round(time.Second)
+	if err != nil {
+		r.Result = ResultFailed
+		r.Error = err.Error()
+	} else {
+		r.Result = ResultSucceeded
+	}
+}
+
+// Store persists and queries the deployment records of a project
+type Store interface {
+	Save(record *Record) error
+	Query(env string, app string, limit int) ([]*Record, error)
+}
+
+type objectStorageStore struct {
+	storage    gcp.ObjectStorageCache
+	bucketName string
+}
+
+// NewObjectStorageStore creates a Store keeping a JSON object per deployment in 'bucketName'
+// under 'history/{env}/{app}/'
+func NewObjectStorageStore(storage gcp.ObjectStorageCache, bucketName string) Store {
+	return &objectStorageStore{storage: storage, bucketName: bucketName}
+}
+
+// NewDefaultStore creates a GCS based Store in the project bucket
+func NewDefaultStore(project string) Store {
+	return NewObjectStorageStore(gcp.NewDefaultObjectStorageCache(), gcp.ProjectBucketName(project))
+}
+
+// Save writes the record. Saving the same record again (i.e. once finished) overwrites it
+func (oss *objectStorageStore) Save(record *Record) error {
+	recordJson, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error serializing deployment record %v", err)
+	}
+
+	key := recordKey(record)
+	if err := oss.storage.Write(nil, oss.bucketName, key, string(recordJson)); err != nil {
+		return fmt.Errorf("error saving deployment record %s: %v", key, err)
+	}
+
+	log.Printf("Saved deployment record %s (%s)\n", key, record.Result)
+	return nil
+}
+
+// Query returns the records of an environment, optionally only for 'app', most recent first.
+// A 'limit' of 0 returns all of them
+func (oss *objectStorageStore) Query(env string, app string, limit int) ([]*Record, error) {
+	prefix := fmt.Sprintf("%s/%s/", historyPrefix, env)
+	if app != "" {
+		prefix += app + "/"
+	}
+
+	keys, err := oss.storage.List(nil, oss.bucketName, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+
+		content, err := oss.storage.Read(nil, oss.bucketName, key)
+		if err != nil {
+			return nil, err
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(fmt.Sprintf("%v", content)), &record); err != nil {
+			log.Printf("[WARN] skipping invalid deployment record %s: %v", key, err)
+			continue
+		}
+		records = append(records, &record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartedAt.After(records[j].StartedAt)
+	})
+
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func recordKey(record *Record) string {
+	// the timestamp goes first in the object name so keys of an app sort chronologically
+	return fmt.Sprintf("%s/%s/%s/%s-%s.json", historyPrefix, record.Env, record.App,
+		record.StartedAt.Format("20060102T150405.000000000Z"), strings.Split(record.User, "@")[0])
+}
@@ -0,0 +1,60 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) Store {
+	baseDir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("error creating storage dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(baseDir) })
+	return NewObjectStorageStore(gcp.NewFileObjectStorageCache(baseDir), "test-myiac")
+}
+
+func recordStartedAt(app string, env string, startedAt time.Time) *Record {
+	record := NewRecord("test", app, env)
+	record.StartedAt = startedAt
+	return record
+}
+
+func TestFinishedRecordOverwritesStartedOne(t *testing.T) {
+	store := newTestStore(t)
+	record := NewRecord("test", "moneycol-server", "dev")
+	_ = store.Save(record)
+
+	record.Finish(errors.New("helm upgrade failed"))
+	_ = store.Save(record)
+
+	records, err := store.Query("dev", "", 0)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, ResultFailed, records[0].Result)
+	assert.Equal(t, "helm upgrade failed", records[0].Error)
+}
+
+func TestQueryFiltersByAppMostRecentFirst(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now().UTC()
+	_ = store.Save(recordStartedAt("moneycol-server", "dev", now.Add(-2*time.Hour)))
+	_ = store.Save(recordStartedAt("moneycol-server", "dev", now.Add(-1*time.Hour)))
+	_ = store.Save(recordStartedAt("traefik", "dev", now))
+	_ = store.Save(recordStartedAt("moneycol-server", "prod", now))
+
+	records, err := store.Query("dev", "moneycol-server", 0)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.True(t, records[0].StartedAt.After(records[1].StartedAt))
+
+	latest, _ := store.Query("dev", "", 1)
+	assert.Len(t, latest, 1)
+	assert.Equal(t, "traefik", latest[0].App)
+}
@@ -0,0 +1,84 @@
+// Package kubeconfig keeps a kubeconfig file per cluster under ~/.myiac/kubeconfig, so getting the
+// credentials of a cluster doesn't switch the global kubectl context, and tells kubectl and helm
+// which one to use through KUBECONFIG
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const EnvVar = "KUBECONFIG"
+
+// executables run with KUBECONFIG set, gcloud writes the credentials of 'get-credentials' into it
+var executables = map[string]bool{"kubectl": true, "helm": true, "gcloud": true}
+
+var (
+	activeMutex sync.RWMutex
+	activePath  string
+)
+
+// Dir where the kubeconfig files are kept
+func Dir() string {
+	return filepath.Join(util.GetHomeDir(), ".myiac", "kubeconfig")
+}
+
+// PathFor the kubeconfig file of the context 'kubeContext'
+func PathFor(kubeContext string) string {
+	return filepath.Join(Dir(), kubeContext)
+}
+
+// GkeContext the name gcloud gives to the context of a GKE cluster
+func GkeContext(project string, location string, clusterName string) string {
+	return fmt.Sprintf("gke_%s_%s_%s", project, location, clusterName)
+}
+
+// ParseGkeContext the project, location and cluster name of a GKE context, false when it's not one
+func ParseGkeContext(kubeContext string) (string, string, string, bool) {
+	parts := strings.SplitN(kubeContext, "_", 4)
+	if len(parts) != 4 || parts[0] != "gke" || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return "", "", "", false
+	}
+	return parts[1], parts[2], parts[3], true
+}
+
+// Prepare creates the directory of the kubeconfig of 'kubeContext' and uses it from now on, the
+// file itself is written by 'gcloud container clusters get-credentials'
+func Prepare(kubeContext string) (string, error) {
+	path := PathFor(kubeContext)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("error creating kubeconfig dir: %v", err)
+	}
+	Use(path)
+	return path, nil
+}
+
+// Use makes 'path' the kubeconfig of the kubectl, helm and Kubernetes API calls of this process
+func Use(path string) {
+	activeMutex.Lock()
+	defer activeMutex.Unlock()
+	activePath = path
+}
+
+// Active the kubeconfig in use, empty when it's the default one ($KUBECONFIG or ~/.kube/config)
+func Active() string {
+	activeMutex.RLock()
+	defer activeMutex.RUnlock()
+	return activePath
+}
+
+// Env the environment to run 'executable' with: the current one plus KUBECONFIG for kubectl, helm
+// and gcloud when a kubeconfig is in use. Nil keeps the current environment
+func Env(executable string) []string {
+	path := Active()
+	if path == "" || !executables[filepath.Base(executable)] {
+		return nil
+	}
+	// when KUBECONFIG is already set, the last value wins
+	return append(os.Environ(), EnvVar+"="+path)
+}
@@ -0,0 +1,37 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGkeContextsAreParsed(t *testing.T) {
+	kubeContext := GkeContext("moneycol", "europe-west2-b", "blue-moneycol-dev")
+	assert.Equal(t, "gke_moneycol_europe-west2-b_blue-moneycol-dev", kubeContext)
+
+	project, location, clusterName, ok := ParseGkeContext(kubeContext)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"moneycol", "europe-west2-b", "blue-moneycol-dev"}, []string{project, location, clusterName})
+
+	_, _, _, ok = ParseGkeContext("kind-local")
+	assert.False(t, ok)
+	_, _, _, ok = ParseGkeContext("gke_moneycol__moneycol-dev")
+	assert.False(t, ok)
+}
+
+func TestKubeconfigIsPassedToKubectlAndHelmOnly(t *testing.T) {
+	Use("")
+	assert.Nil(t, Env("kubectl"))
+
+	Use("/home/app/.myiac/kubeconfig/gke_moneycol_europe-west2-b_moneycol-dev")
+	defer Use("")
+
+	for _, executable := range []string{"kubectl", "/usr/local/bin/helm", "gcloud"} {
+		env := Env(executable)
+		assert.Equal(t, "KUBECONFIG=/home/app/.myiac/kubeconfig/gke_moneycol_europe-west2-b_moneycol-dev",
+			env[len(env)-1], executable)
+	}
+	assert.Nil(t, Env("terraform"))
+	assert.Nil(t, Env("docker"))
+}
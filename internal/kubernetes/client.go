@@ -0,0 +1,173 @@
+// Package kubernetes typed operations on the cluster of a kubeconfig context (pods, nodes, secrets,
+// namespaces and rollouts) through client-go, instead of parsing kubectl output
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iac-io/myiac/internal/kubeconfig"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Client operations on the cluster of a kubeconfig context
+type Client struct {
+	clientset k8s.Interface
+}
+
+// NewClient a client for 'kubeContext' of the kubeconfig in use (the one of the active cluster,
+// else $KUBECONFIG or ~/.kube/config), the current context when empty
+func NewClient(kubeContext string) (*Client, error) {
+	config, err := clientConfig(kubeContext).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig context '%s': %v", kubeContext, err)
+	}
+
+	clientset, err := k8s.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %v", err)
+	}
+	return NewClientFromClientset(clientset), nil
+}
+
+// NewDefaultClient a client for the current kubeconfig context
+func NewDefaultClient() (*Client, error) {
+	return NewClient("")
+}
+
+// CurrentContext the current context of the kubeconfig in use
+func CurrentContext() (string, error) {
+	rawConfig, err := clientConfig("").RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+	if rawConfig.CurrentContext == "" {
+		return "", fmt.Errorf("the kubeconfig has no current context")
+	}
+	return rawConfig.CurrentContext, nil
+}
+
+func clientConfig(kubeContext string) clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig.Active()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+}
+
+// NewClientFromClientset a client using 'clientset', i.e. the client-go fake one in tests
+func NewClientFromClientset(clientset k8s.Interface) *Client {
+	return &Client{clientset: clientset}
+}
+
+// RunningPods the running pods of 'namespace' (every namespace when empty) matching 'labelSelector'
+func (c *Client) RunningPods(ctx context.Context, namespace string, labelSelector string) ([]corev1.Pod, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fields.OneTermEqualSelector("status.phase", string(corev1.PodRunning)).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods %s: %v", labelSelector, err)
+	}
+
+	// the field selector is only a hint for the API server, clients like the fake one ignore it
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+	return running, nil
+}
+
+// ListNodes the nodes of the cluster
+func (c *Client) ListNodes(ctx context.Context) ([]corev1.Node, error) {
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+	return nodes.Items, nil
+}
+
+// GetNode the node named 'name'
+func (c *Client) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting node %s: %v", name, err)
+	}
+	return node, nil
+}
+
+// GetSecret the secret 'name' of 'namespace', nil if there's none
+func (c *Client) GetSecret(ctx context.Context, namespace string, name string) (*corev1.Secret, error) {
+	secret, err := c.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting secret %s/%s: %v", namespace, name, err)
+	}
+	return secret, nil
+}
+
+// ApplySecret creates the secret, replacing the data of an existing one with the same name
+func (c *Client) ApplySecret(ctx context.Context, secret *corev1.Secret) error {
+	secrets := c.clientset.CoreV1().Secrets(secret.Namespace)
+	existing, err := c.GetSecret(ctx, secret.Namespace, secret.Name)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	} else if existing.Type != secret.Type {
+		// the type of a secret can't be updated
+		if err = c.DeleteSecret(ctx, secret.Namespace, secret.Name); err == nil {
+			_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		}
+	} else {
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error saving secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+	return nil
+}
+
+// DeleteSecret deletes the secret, nothing is done if it doesn't exist
+func (c *Client) DeleteSecret(ctx context.Context, namespace string, name string) error {
+	err := c.clientset.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting secret %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
+
+// ListNamespaces the names of the namespaces of the cluster
+func (c *Client) ListNamespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %v", err)
+	}
+
+	var names []string
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
+
+// EnsureNamespace creates the namespace unless it already exists
+func (c *Client) EnsureNamespace(ctx context.Context, name string) error {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	_, err := c.clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating namespace %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pod(name string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestRunningPodsMatchingSelector(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset(
+		pod("traefik-1", corev1.PodRunning, map[string]string{"app": "traefik"}),
+		pod("traefik-2", corev1.PodPending, map[string]string{"app": "traefik"}),
+		pod("elasticsearch-1", corev1.PodRunning, map[string]string{"app": "elasticsearch"}),
+	))
+
+	pods, err := client.RunningPods(context.Background(), "", "app=traefik")
+
+	assert.Nil(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, "traefik-1", pods[0].Name)
+}
+
+func TestGetMissingSecretIsNil(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset())
+
+	secret, err := client.GetSecret(context.Background(), "default", "missing")
+
+	assert.Nil(t, err)
+	assert.Nil(t, secret)
+}
+
+func TestApplySecretReplacesExistingData(t *testing.T) {
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Type: corev1.SecretTypeOpaque, Data: map[string][]byte{"password": []byte("old")}}
+	client := NewClientFromClientset(fake.NewSimpleClientset(existing))
+
+	err := client.ApplySecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("new")},
+	})
+
+	assert.Nil(t, err)
+	secret, _ := client.GetSecret(context.Background(), "default", "db")
+	assert.Equal(t, map[string][]byte{"password": []byte("new")}, secret.Data)
+}
+
+func TestApplySecretOfAnotherTypeRecreatesIt(t *testing.T) {
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "moneycol.net", Namespace: "default"},
+		Type: corev1.SecretTypeOpaque}
+	client := NewClientFromClientset(fake.NewSimpleClientset(existing))
+
+	err := client.ApplySecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "moneycol.net", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+	})
+
+	assert.Nil(t, err)
+	secret, _ := client.GetSecret(context.Background(), "default", "moneycol.net")
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+}
+
+func TestDeleteMissingSecretIsNotAnError(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset())
+
+	assert.Nil(t, client.DeleteSecret(context.Background(), "default", "missing"))
+}
+
+func TestEnsureNamespaceIsIdempotent(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}))
+
+	assert.Nil(t, client.EnsureNamespace(context.Background(), "moneycol-dev"))
+	assert.Nil(t, client.EnsureNamespace(context.Background(), "moneycol-dev"))
+
+	namespaces, err := client.ListNamespaces(context.Background())
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"default", "moneycol-dev"}, namespaces)
+}
+
+func TestGetNode(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}))
+
+	node, err := client.GetNode(context.Background(), "node-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "node-1", node.Name)
+
+	_, err = client.GetNode(context.Background(), "node-2")
+	assert.NotNil(t, err)
+}
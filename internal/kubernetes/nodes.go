@@ -0,0 +1,109 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// addressTypes the address types by the name they're given in flags
+var addressTypes = map[string]corev1.NodeAddressType{
+	"external": corev1.NodeExternalIP,
+	"internal": corev1.NodeInternalIP,
+	"hostname": corev1.NodeHostName,
+}
+
+// NodeAddress an address of a node
+type NodeAddress struct {
+	Node    string `json:"node"`
+	Type    string `json:"type"`
+	Address string `json:"address"`
+	// Family ipv4 or ipv6, empty for hostnames
+	Family string `json:"family,omitempty"`
+}
+
+// ParseAddressType the node address type for external, internal or hostname
+func ParseAddressType(name string) (corev1.NodeAddressType, error) {
+	addressType, ok := addressTypes[name]
+	if !ok {
+		return "", fmt.Errorf("unknown address type %s, use external, internal or hostname", name)
+	}
+	return addressType, nil
+}
+
+// ValidateFamily checks 'family' is ipv4, ipv6 or empty (any)
+func ValidateFamily(family string) error {
+	if family != "" && family != FamilyIPv4 && family != FamilyIPv6 {
+		return fmt.Errorf("unknown IP family %s, use %s or %s", family, FamilyIPv4, FamilyIPv6)
+	}
+	return nil
+}
+
+// IsSchedulable a node is Ready and not cordoned
+func IsSchedulable(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// AddressesOf the addresses of 'node' of 'addressType' in 'family' (any when empty), in the order
+// the node reports them. Dual-stack nodes have an IPv4 and an IPv6 address of the same type
+func AddressesOf(node corev1.Node, addressType corev1.NodeAddressType, family string) []NodeAddress {
+	var addresses []NodeAddress
+	for _, address := range node.Status.Addresses {
+		if address.Type != addressType {
+			continue
+		}
+		addressFamily := ipFamily(address.Address)
+		if family != "" && addressFamily != family {
+			continue
+		}
+		addresses = append(addresses, NodeAddress{Node: node.Name, Type: string(address.Type),
+			Address: address.Address, Family: addressFamily})
+	}
+	return addresses
+}
+
+// ipFamily ipv4 or ipv6, empty when 'address' isn't an IP (hostnames)
+func ipFamily(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
+// NodeAddresses the addresses of 'addressType' in 'family' (any when empty) of the nodes that are
+// Ready and not cordoned
+func (c *Client) NodeAddresses(ctx context.Context, addressType corev1.NodeAddressType,
+	family string) ([]NodeAddress, error) {
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []NodeAddress
+	for _, node := range nodes {
+		if IsSchedulable(node) {
+			addresses = append(addresses, AddressesOf(node, addressType, family)...)
+		}
+	}
+	return addresses, nil
+}
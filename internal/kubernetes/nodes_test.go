@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func node(name string, ready bool, addresses ...corev1.NodeAddress) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			Addresses:  addresses,
+		},
+	}
+}
+
+func TestAddressesAreSelectedByType(t *testing.T) {
+	// hostname first and no external IP
+	n := node("pool-1", true,
+		corev1.NodeAddress{Type: corev1.NodeHostName, Address: "pool-1.c.moneycol.internal"},
+		corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.154.0.2"})
+
+	assert.Equal(t, []NodeAddress{{Node: "pool-1", Type: "InternalIP", Address: "10.154.0.2", Family: FamilyIPv4}},
+		AddressesOf(*n, corev1.NodeInternalIP, ""))
+	assert.Equal(t, []NodeAddress{{Node: "pool-1", Type: "Hostname", Address: "pool-1.c.moneycol.internal"}},
+		AddressesOf(*n, corev1.NodeHostName, ""))
+	assert.Empty(t, AddressesOf(*n, corev1.NodeExternalIP, ""))
+}
+
+func TestDualStackAddressesAreFilteredByFamily(t *testing.T) {
+	n := node("pool-1", true,
+		corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.154.0.2"},
+		corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "fd20:1::2"})
+
+	assert.Len(t, AddressesOf(*n, corev1.NodeInternalIP, ""), 2)
+	assert.Equal(t, "10.154.0.2", AddressesOf(*n, corev1.NodeInternalIP, FamilyIPv4)[0].Address)
+	ipv6 := AddressesOf(*n, corev1.NodeInternalIP, FamilyIPv6)
+	assert.Equal(t, []NodeAddress{{Node: "pool-1", Type: "InternalIP", Address: "fd20:1::2", Family: FamilyIPv6}}, ipv6)
+}
+
+func TestNotReadyAndCordonedNodesAreSkipped(t *testing.T) {
+	external := func(ip string) corev1.NodeAddress {
+		return corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: ip}
+	}
+	cordoned := node("pool-3", true, external("35.195.98.3"))
+	cordoned.Spec.Unschedulable = true
+	client := NewClientFromClientset(fake.NewSimpleClientset(
+		node("pool-1", true, external("35.195.98.1")),
+		node("pool-2", false, external("35.195.98.2")),
+		cordoned,
+	))
+
+	addresses, err := client.NodeAddresses(context.Background(), corev1.NodeExternalIP, FamilyIPv4)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []NodeAddress{{Node: "pool-1", Type: "ExternalIP", Address: "35.195.98.1", Family: FamilyIPv4}},
+		addresses)
+}
+
+func TestAddressTypesAndFamiliesAreValidated(t *testing.T) {
+	addressType, err := ParseAddressType("external")
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.NodeExternalIP, addressType)
+
+	_, err = ParseAddressType("ExternalIP")
+	assert.NotNil(t, err)
+	assert.Nil(t, ValidateFamily(""))
+	assert.NotNil(t, ValidateFamily("ipv5"))
+}
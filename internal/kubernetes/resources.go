@@ -0,0 +1,100 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListDeployments the deployments of 'namespace'
+func (c *Client) ListDeployments(ctx context.Context, namespace string) ([]appsv1.Deployment, error) {
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing deployments of %s: %v", namespace, err)
+	}
+	return deployments.Items, nil
+}
+
+// ListServices the services of 'namespace'
+func (c *Client) ListServices(ctx context.Context, namespace string) ([]corev1.Service, error) {
+	services, err := c.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing services of %s: %v", namespace, err)
+	}
+	return services.Items, nil
+}
+
+// ListConfigMaps the config maps of 'namespace'
+func (c *Client) ListConfigMaps(ctx context.Context, namespace string) ([]corev1.ConfigMap, error) {
+	configMaps, err := c.clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing config maps of %s: %v", namespace, err)
+	}
+	return configMaps.Items, nil
+}
+
+// ListSecrets the secrets of 'namespace'
+func (c *Client) ListSecrets(ctx context.Context, namespace string) ([]corev1.Secret, error) {
+	secrets, err := c.clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing secrets of %s: %v", namespace, err)
+	}
+	return secrets.Items, nil
+}
+
+// ApplyDeployment creates the deployment, replacing the spec of an existing one with the same name
+func (c *Client) ApplyDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
+	deployments := c.clientset.AppsV1().Deployments(deployment.Namespace)
+	_, err := deployments.Create(ctx, deployment, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		var existing *appsv1.Deployment
+		if existing, err = deployments.Get(ctx, deployment.Name, metav1.GetOptions{}); err == nil {
+			deployment.ResourceVersion = existing.ResourceVersion
+			_, err = deployments.Update(ctx, deployment, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error saving deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+	}
+	return nil
+}
+
+// ApplyService creates the service, replacing the spec of an existing one with the same name. The
+// cluster IP of an existing service is kept, it can't be changed
+func (c *Client) ApplyService(ctx context.Context, service *corev1.Service) error {
+	services := c.clientset.CoreV1().Services(service.Namespace)
+	_, err := services.Create(ctx, service, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		var existing *corev1.Service
+		if existing, err = services.Get(ctx, service.Name, metav1.GetOptions{}); err == nil {
+			service.ResourceVersion = existing.ResourceVersion
+			service.Spec.ClusterIP, service.Spec.ClusterIPs = existing.Spec.ClusterIP, existing.Spec.ClusterIPs
+			_, err = services.Update(ctx, service, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error saving service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	return nil
+}
+
+// ApplyConfigMap creates the config map, replacing the data of an existing one with the same name
+func (c *Client) ApplyConfigMap(ctx context.Context, configMap *corev1.ConfigMap) error {
+	configMaps := c.clientset.CoreV1().ConfigMaps(configMap.Namespace)
+	_, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		var existing *corev1.ConfigMap
+		if existing, err = configMaps.Get(ctx, configMap.Name, metav1.GetOptions{}); err == nil {
+			configMap.ResourceVersion = existing.ResourceVersion
+			_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error saving config map %s/%s: %v", configMap.Namespace, configMap.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyServiceKeepsClusterIpOfExistingOne(t *testing.T) {
+	existing := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "collections-api", Namespace: "default"},
+		Spec: corev1.ServiceSpec{ClusterIP: "10.0.4.12", Ports: []corev1.ServicePort{{Port: 80}}}}
+	client := NewClientFromClientset(fake.NewSimpleClientset(existing))
+
+	err := client.ApplyService(context.Background(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "collections-api", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}},
+	})
+
+	assert.Nil(t, err)
+	services, _ := client.ListServices(context.Background(), "default")
+	assert.Len(t, services, 1)
+	assert.Equal(t, "10.0.4.12", services[0].Spec.ClusterIP)
+	assert.Equal(t, int32(8080), services[0].Spec.Ports[0].Port)
+}
+
+func TestApplyDeploymentCreatesAndUpdates(t *testing.T) {
+	client := NewClientFromClientset(fake.NewSimpleClientset())
+	replicas := int32(1)
+	deployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "collections-api", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+	}
+
+	assert.Nil(t, client.ApplyDeployment(context.Background(), deployment()))
+	replicas = 3
+	assert.Nil(t, client.ApplyDeployment(context.Background(), deployment()))
+
+	deployments, err := client.ListDeployments(context.Background(), "default")
+	assert.Nil(t, err)
+	assert.Len(t, deployments, 1)
+	assert.Equal(t, int32(3), *deployments[0].Spec.Replicas)
+}
@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rolloutPollInterval how often a rollout is checked while waiting for it
+var rolloutPollInterval = 2 * time.Second
+
+// RolloutStatus the progress of the rollout of a deployment, as 'kubectl rollout status' reports it
+type RolloutStatus struct {
+	Deployment string
+	Done       bool
+	Message    string
+}
+
+// DeploymentRolloutStatus the rollout status of the deployment 'name' of 'namespace'
+func (c *Client) DeploymentRolloutStatus(ctx context.Context, namespace string, name string) (RolloutStatus, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return RolloutStatus{}, fmt.Errorf("error getting deployment %s/%s: %v", namespace, name, err)
+	}
+	return rolloutStatus(deployment)
+}
+
+// rolloutStatus same checks as kubectl: the controller has seen the last change, and every replica
+// is updated and available with none of the old ones left
+func rolloutStatus(deployment *appsv1.Deployment) (RolloutStatus, error) {
+	status := RolloutStatus{Deployment: deployment.Name}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status.Message = "waiting for the deployment spec update to be observed"
+		return status, nil
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return status, fmt.Errorf("deployment %s exceeded its progress deadline", deployment.Name)
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	updated, total, available := deployment.Status.UpdatedReplicas, deployment.Status.Replicas,
+		deployment.Status.AvailableReplicas
+	switch {
+	case updated < replicas:
+		status.Message = fmt.Sprintf("%d out of %d new replicas have been updated", updated, replicas)
+	case total > updated:
+		status.Message = fmt.Sprintf("%d old replicas are pending termination", total-updated)
+	case available < updated:
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available", available, updated)
+	default:
+		status.Done = true
+		status.Message = "successfully rolled out"
+	}
+	return status, nil
+}
+
+// WaitForRollout waits up to 'timeout' for the rollout of the deployment to finish
+func (c *Client) WaitForRollout(ctx context.Context, namespace string, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		status, err := c.DeploymentRolloutStatus(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		if status.Done {
+			return nil
+		}
+		log.Printf("Waiting for rollout of %s/%s: %s\n", namespace, name, status.Message)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("rollout of %s/%s not finished after %s: %s", namespace, name, timeout, status.Message)
+		case <-time.After(rolloutPollInterval):
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func deployment(replicas int32, status appsv1.DeploymentStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "moneycol-server", Namespace: "default", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     status,
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	for name, test := range map[string]struct {
+		status  appsv1.DeploymentStatus
+		done    bool
+		message string
+	}{
+		"not observed": {appsv1.DeploymentStatus{ObservedGeneration: 1}, false,
+			"waiting for the deployment spec update to be observed"},
+		"updating": {appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1}, false,
+			"1 out of 2 new replicas have been updated"},
+		"old replicas left": {appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2}, false,
+			"1 old replicas are pending termination"},
+		"not available": {appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2,
+			AvailableReplicas: 1}, false, "1 of 2 updated replicas are available"},
+		"done": {appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2,
+			AvailableReplicas: 2}, true, "successfully rolled out"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := NewClientFromClientset(fake.NewSimpleClientset(deployment(2, test.status)))
+
+			status, err := client.DeploymentRolloutStatus(context.Background(), "default", "moneycol-server")
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.done, status.Done)
+			assert.Equal(t, test.message, status.Message)
+		})
+	}
+}
+
+func TestRolloutPastItsDeadlineFails(t *testing.T) {
+	status := appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"}}}
+	client := NewClientFromClientset(fake.NewSimpleClientset(deployment(2, status)))
+
+	_, err := client.DeploymentRolloutStatus(context.Background(), "default", "moneycol-server")
+
+	assert.NotNil(t, err)
+}
+
+func TestWaitForRolloutTimesOut(t *testing.T) {
+	rolloutPollInterval = 10 * time.Millisecond
+	status := appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1}
+	client := NewClientFromClientset(fake.NewSimpleClientset(deployment(2, status)))
+
+	err := client.WaitForRollout(context.Background(), "default", "moneycol-server", 50*time.Millisecond)
+
+	assert.NotNil(t, err)
+}
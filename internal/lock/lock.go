@@ -0,0 +1,244 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/util"
+)
+
+const (
+	// DefaultTTL is how long a lock is honoured before another owner can take it over, unless renewed
+	DefaultTTL = 30 * time.Minute
+
+	ownerEnvVar = "MYIAC_LOCK_OWNER"
+	locksPrefix = "locks"
+)
+
+// Lock is the record stored while a mutating command runs against an environment
+type Lock struct {
+	Project    string    `json:"project"`
+	Env        string    `json:"env"`
+	Owner      string    `json:"owner"`
+	Operation  string    `json:"operation"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// IsStale tells if the lock TTL has passed at the given time, so it can be taken over
+func (l Lock) IsStale(now time.Time) bool {
+	return now.After(l.ExpiresAt)
+}
+
+func (l Lock) String() string {
+	return fmt.Sprintf("%s/%s locked by %s for '%s' since %s (expires %s)", l.Project, l.Env, l.Owner,
+		l.Operation, l.AcquiredAt.Format(time.RFC3339), l.ExpiresAt.Format(time.RFC3339))
+}
+
+// LockedError is returned when acquiring a lock currently held by a different owner
+type LockedError struct {
+	Lock *Lock
+}
+
+func (le *LockedError) Error() string {
+	return fmt.Sprintf("environment is locked: %s. Once it's done or if it crashed, 'myiac lock release' frees it", le.Lock)
+}
+
+// Locker manages the lock of a single project environment
+type Locker interface {
+	Acquire(operation string) (*Lock, error)
+	Renew() (*Lock, error)
+	Release(force bool) error
+	Status() (*Lock, error)
+}
+
+type objectStorageLocker struct {
+	storage    gcp.ObjectStorageCache
+	bucketName string
+	project    string
+	env        string
+	owner      string
+	ttl        time.Duration
+	now        func() time.Time
+	// held the lock acquired by this locker and the generation of its object, to renew it
+	held       *Lock
+	generation int64
+}
+
+// NewObjectStorageLocker creates a Locker that keeps the lock as a JSON object in 'bucketName'.
+//
+// The lock is written with a precondition on the generation read (or on its absence), so of two
+// concurrent commands only one acquires it
+func NewObjectStorageLocker(storage gcp.ObjectStorageCache, bucketName string, project string, env string,
+	owner string, ttl time.Duration) Locker {
+	return &objectStorageLocker{
+		storage:    storage,
+		bucketName: bucketName,
+		project:    project,
+		env:        env,
+		owner:      owner,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+// NewDefaultLocker creates a GCS based Locker in the project bucket, owned by the current user
+func NewDefaultLocker(project string, env string) Locker {
+	return NewObjectStorageLocker(gcp.NewDefaultObjectStorageCache(), gcp.ProjectBucketName(project),
+		project, env, DefaultOwner(), DefaultTTL)
+}
+
+// DefaultOwner identifies who holds a lock: MYIAC_LOCK_OWNER if set (i.e. a CI job id), user@host otherwise
+func DefaultOwner() string {
+	if owner := os.Getenv(ownerEnvVar); owner != "" {
+		return owner
+	}
+
+	return util.CurrentUserIdentity()
+}
+
+// Acquire takes the lock for 'operation'. A lock not yet stale can't be acquired again, not even
+// by its owner, as two commands of the same owner (i.e. CI jobs on one host) can't run at once either
+func (osl *objectStorageLocker) Acquire(operation string) (*Lock, error) {
+	existing, generation, err := osl.read()
+	if err != nil {
+		return nil, err
+	}
+
+	now := osl.now().UTC()
+	if existing != nil {
+		if !existing.IsStale(now) {
+			return nil, &LockedError{Lock: existing}
+		}
+		log.Printf("Taking over stale lock: %s\n", existing)
+	}
+
+	newLock := &Lock{
+		Project:    osl.project,
+		Env:        osl.env,
+		Owner:      osl.owner,
+		Operation:  operation,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(osl.ttl),
+	}
+
+	generation, err = osl.write(newLock, generation)
+	if errors.Is(err, gcp.ErrPreconditionFailed) {
+		// another command acquired the lock between the read and the write
+		current, _, readErr := osl.read()
+		if readErr != nil {
+			return nil, readErr
+		}
+		if current == nil {
+			return nil, fmt.Errorf("lock of %s/%s changed while acquiring it, try again", osl.project, osl.env)
+		}
+		return nil, &LockedError{Lock: current}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	osl.held, osl.generation = newLock, generation
+	log.Printf("Lock acquired: %s\n", newLock)
+	return newLock, nil
+}
+
+// Renew extends the expiry of the lock acquired by this locker by its TTL, so operations longer than
+// the TTL keep it. Fails if the lock isn't held anymore, i.e. it went stale and was taken over
+func (osl *objectStorageLocker) Renew() (*Lock, error) {
+	if osl.held == nil {
+		return nil, fmt.Errorf("lock of %s/%s is not held, it can't be renewed", osl.project, osl.env)
+	}
+
+	renewed := *osl.held
+	renewed.ExpiresAt = osl.now().UTC().Add(osl.ttl)
+	generation, err := osl.write(&renewed, osl.generation)
+	if errors.Is(err, gcp.ErrPreconditionFailed) {
+		osl.held = nil
+		return nil, fmt.Errorf("lock of %s/%s was released or taken over while running", osl.project, osl.env)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	osl.held, osl.generation = &renewed, generation
+	return &renewed, nil
+}
+
+// Release removes the lock. Only its owner can release it unless 'force' is set. The lock is only
+// removed if it's still the one read, not one taken over meanwhile
+func (osl *objectStorageLocker) Release(force bool) error {
+	existing, generation, err := osl.read()
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		log.Printf("No lock present for %s/%s\n", osl.project, osl.env)
+		return nil
+	}
+
+	if existing.Owner != osl.owner && !force {
+		return fmt.Errorf("lock is owned by %s, use force to release it", existing.Owner)
+	}
+
+	err = osl.storage.DeleteIfGeneration(nil, osl.bucketName, osl.objectKey(), generation)
+	if errors.Is(err, gcp.ErrPreconditionFailed) {
+		return fmt.Errorf("lock of %s/%s changed while releasing it, it was not released", osl.project, osl.env)
+	}
+	if err != nil {
+		return fmt.Errorf("error releasing lock %s: %v", osl.objectKey(), err)
+	}
+
+	osl.held = nil
+	log.Printf("Lock released: %s\n", existing)
+	return nil
+}
+
+// Status returns the current lock, or nil when the environment isn't locked
+func (osl *objectStorageLocker) Status() (*Lock, error) {
+	current, _, err := osl.read()
+	return current, err
+}
+
+// read the current lock and the generation of its object, nil and 0 when the environment isn't locked
+func (osl *objectStorageLocker) read() (*Lock, int64, error) {
+	content, generation, err := osl.storage.ReadGeneration(nil, osl.bucketName, osl.objectKey())
+	if errors.Is(err, gcp.ErrObjectNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading lock %s: %v", osl.objectKey(), err)
+	}
+
+	var current Lock
+	if err := json.Unmarshal([]byte(fmt.Sprintf("%v", content)), &current); err != nil {
+		return nil, 0, fmt.Errorf("error parsing lock %s: %v", osl.objectKey(), err)
+	}
+
+	return &current, generation, nil
+}
+
+// write the lock only if its object is still at 'generation', 0 meaning there must be no lock.
+// Returns the new generation of the object
+func (osl *objectStorageLocker) write(lock *Lock, generation int64) (int64, error) {
+	lockJson, err := json.Marshal(lock)
+	if err != nil {
+		return 0, fmt.Errorf("error serializing lock %v", err)
+	}
+
+	written, err := osl.storage.WriteIfGeneration(nil, osl.bucketName, osl.objectKey(), string(lockJson), generation)
+	if err != nil {
+		return 0, fmt.Errorf("error writing lock %s: %w", osl.objectKey(), err)
+	}
+	return written, nil
+}
+
+func (osl *objectStorageLocker) objectKey() string {
+	return fmt.Sprintf("%s/%s.json", locksPrefix, osl.env)
+}
@@ -0,0 +1,171 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStorage(t *testing.T) gcp.ObjectStorageCache {
+	baseDir, err := ioutil.TempDir("", "locks")
+	if err != nil {
+		t.Fatalf("error creating storage dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(baseDir) })
+	return gcp.NewFileObjectStorageCache(baseDir)
+}
+
+func newTestLocker(storage gcp.ObjectStorageCache, owner string, now time.Time) *objectStorageLocker {
+	locker := NewObjectStorageLocker(storage, "test-myiac", "test", "dev", owner, time.Minute).(*objectStorageLocker)
+	locker.now = func() time.Time { return now }
+	return locker
+}
+
+func TestAcquiresFreeLock(t *testing.T) {
+	storage := newTestStorage(t)
+	locker := newTestLocker(storage, "alice@host", time.Now())
+
+	acquired, err := locker.Acquire("deploy")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@host", acquired.Owner)
+	assert.Equal(t, "deploy", acquired.Operation)
+	stored, _ := storage.Read(nil, "test-myiac", "locks/dev.json")
+	assert.Contains(t, stored, "alice@host")
+}
+
+func TestCannotAcquireLockHeldByOtherOwner(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	_, _ = newTestLocker(storage, "alice@host", now).Acquire("deploy")
+
+	_, err := newTestLocker(storage, "bob@host", now.Add(30*time.Second)).Acquire("deploy")
+
+	assert.IsType(t, &LockedError{}, err)
+	assert.Equal(t, "alice@host", err.(*LockedError).Lock.Owner)
+}
+
+func TestSameOwnerCannotAcquireHeldLockAgain(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	_, _ = newTestLocker(storage, "ci@runner", now).Acquire("deploy")
+
+	_, err := newTestLocker(storage, "ci@runner", now.Add(time.Second)).Acquire("deploy")
+
+	assert.IsType(t, &LockedError{}, err)
+}
+
+func TestOnlyOneOfConcurrentAcquiresWins(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := newTestLocker(storage, "ci@runner", now).Acquire("deploy")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	acquired := 0
+	for err := range errs {
+		if err == nil {
+			acquired++
+		}
+	}
+	assert.Equal(t, 1, acquired)
+}
+
+func TestTakesOverStaleLock(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	_, _ = newTestLocker(storage, "alice@host", now).Acquire("deploy")
+
+	acquired, err := newTestLocker(storage, "bob@host", now.Add(2*time.Minute)).Acquire("createCluster")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "bob@host", acquired.Owner)
+}
+
+func TestRenewExtendsExpiry(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	locker := newTestLocker(storage, "alice@host", now)
+	acquired, _ := locker.Acquire("createCluster")
+
+	locker.now = func() time.Time { return now.Add(50 * time.Second) }
+	renewed, err := locker.Renew()
+
+	assert.Nil(t, err)
+	assert.Equal(t, acquired.AcquiredAt, renewed.AcquiredAt)
+	assert.True(t, renewed.ExpiresAt.After(acquired.ExpiresAt))
+	_, err = newTestLocker(storage, "bob@host", now.Add(90*time.Second)).Acquire("deploy")
+	assert.IsType(t, &LockedError{}, err)
+}
+
+func TestRenewFailsOnceTakenOver(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	locker := newTestLocker(storage, "alice@host", now)
+	_, _ = locker.Acquire("createCluster")
+	_, _ = newTestLocker(storage, "bob@host", now.Add(2*time.Minute)).Acquire("deploy")
+
+	_, err := locker.Renew()
+
+	assert.NotNil(t, err)
+}
+
+func TestReleaseRequiresOwnerUnlessForced(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	_, _ = newTestLocker(storage, "alice@host", now).Acquire("deploy")
+	other := newTestLocker(storage, "bob@host", now)
+
+	assert.NotNil(t, other.Release(false))
+	assert.Nil(t, other.Release(true))
+
+	current, err := other.Status()
+	assert.Nil(t, err)
+	assert.Nil(t, current)
+}
+
+// takeOverBeforeDelete storage where 'takeOver' runs right before a conditional delete, as another
+// process taking a stale lock between the read and the delete of Release
+type takeOverBeforeDelete struct {
+	gcp.ObjectStorageCache
+	takeOver func()
+}
+
+func (s *takeOverBeforeDelete) DeleteIfGeneration(ctx context.Context, bucketName string, key string,
+	generation int64) error {
+	s.takeOver()
+	return s.ObjectStorageCache.DeleteIfGeneration(ctx, bucketName, key, generation)
+}
+
+func TestReleaseKeepsLockTakenOverMeanwhile(t *testing.T) {
+	storage := newTestStorage(t)
+	now := time.Now()
+	owner := newTestLocker(storage, "alice@host", now)
+	_, _ = owner.Acquire("deploy")
+	takeOverStorage := &takeOverBeforeDelete{ObjectStorageCache: storage, takeOver: func() {
+		_, _ = newTestLocker(storage, "bob@host", now.Add(2*time.Minute)).Acquire("deploy")
+	}}
+	owner.storage = takeOverStorage
+
+	err := owner.Release(false)
+
+	assert.NotNil(t, err)
+	current, _ := newTestLocker(storage, "bob@host", now).Status()
+	assert.Equal(t, "bob@host", current.Owner)
+}
@@ -0,0 +1,228 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/iac-io/myiac/internal/util"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DeployerHelm      = "helm"
+	DeployerManifests = "manifests"
+)
+
+// Project the manifest of a project (projects/<project>.yaml): values shared by all apps,
+// per environment values and how each app is deployed
+//
+//	values:
+//	  domain: moneycol.net
+//	cluster:
+//	  zone: europe-west2-b
+//	  machineType: n1-standard-2
+//	naming:
+//	  cluster: "{{ .Project }}-{{ .Environment }}-gke"
+//	environments:
+//	  dev:
+//	    values:
+//	      replicas: 1
+//	    cluster:
+//	      maxNodeCount: 2
+//	  prod:
+//	    cluster:
+//	      region: europe-west2
+//	    schedule:
+//	      - nodePool: default-pool
+//	        scaleDown: "0 20 * * 1-5"
+//	        scaleUp: "0 7 * * 1-5"
+//	apps:
+//	  moneycol-server:
+//	    deployer: helm
+//	  dns-config:
+//	    deployer: manifests
+type Project struct {
+	Name         string                  `yaml:"name"`
+	Values       map[string]interface{}  `yaml:"values"`
+	Cluster      Cluster                 `yaml:"cluster"`
+	Environments map[string]*Environment `yaml:"environments"`
+	Apps         map[string]*App         `yaml:"apps"`
+	// Naming templates overriding the default names of resources, see the naming package
+	Naming map[string]string `yaml:"naming"`
+}
+
+type Environment struct {
+	Values   map[string]interface{} `yaml:"values"`
+	Cluster  Cluster                `yaml:"cluster"`
+	Schedule []ScheduleWindow       `yaml:"schedule"`
+}
+
+// ScheduleWindow off-hours of a node pool: it's scaled down to 'size' nodes at 'scaleDown' and back
+// to its original size at 'scaleUp'. Both are cron expressions in UTC
+type ScheduleWindow struct {
+	NodePool  string `yaml:"nodePool"`
+	ScaleDown string `yaml:"scaleDown"`
+	ScaleUp   string `yaml:"scaleUp"`
+	Size      int    `yaml:"size"`
+}
+
+// Cluster settings of the cluster of an environment. Unset (zero) values are left to the terraform config.
+// Zonal clusters set the zone, regional ones the region
+type Cluster struct {
+	Zone         string `yaml:"zone"`
+	Region       string `yaml:"region"`
+	Prefix       string `yaml:"prefix"`
+	MachineType  string `yaml:"machineType"`
+	NodeCount    int    `yaml:"nodeCount"`
+	MaxNodeCount int    `yaml:"maxNodeCount"`
+	Preemptible  *bool  `yaml:"preemptible"`
+}
+
+// Merge returns these settings overridden by the ones set in 'other'
+func (c Cluster) Merge(other Cluster) Cluster {
+	// a zone overrides a region and the other way around
+	if other.Zone != "" {
+		c.Zone, c.Region = other.Zone, ""
+	}
+	if other.Region != "" {
+		c.Zone, c.Region = "", other.Region
+	}
+	if other.Prefix != "" {
+		c.Prefix = other.Prefix
+	}
+	if other.MachineType != "" {
+		c.MachineType = other.MachineType
+	}
+	if other.NodeCount != 0 {
+		c.NodeCount = other.NodeCount
+	}
+	if other.MaxNodeCount != 0 {
+		c.MaxNodeCount = other.MaxNodeCount
+	}
+	if other.Preemptible != nil {
+		c.Preemptible = other.Preemptible
+	}
+	return c
+}
+
+// Location the region of a regional cluster or the zone of a zonal one
+func (c Cluster) Location() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	return c.Zone
+}
+
+func (c Cluster) validate() error {
+	if c.Zone != "" && c.Region != "" {
+		return fmt.Errorf("cluster has both zone %s and region %s, set only one", c.Zone, c.Region)
+	}
+	return nil
+}
+
+type App struct {
+	Deployer string `yaml:"deployer"`
+	// Path of the manifests directory, relative to MANIFESTS_PATH. Defaults to the app name
+	Path string `yaml:"path"`
+}
+
+// DeployerFor the deployer type of an app, helm unless the manifest says otherwise
+func (p *Project) DeployerFor(appName string) string {
+	if app, ok := p.Apps[appName]; ok && app.Deployer != "" {
+		return app.Deployer
+	}
+	return DeployerHelm
+}
+
+// ManifestsPathFor the directory with the manifests of an app, relative to MANIFESTS_PATH
+func (p *Project) ManifestsPathFor(appName string) string {
+	if app, ok := p.Apps[appName]; ok && app.Path != "" {
+		return app.Path
+	}
+	return appName
+}
+
+// ValuesFor the project values overridden by the ones of 'environment'. Nested maps are merged
+func (p *Project) ValuesFor(environment string) map[string]interface{} {
+	values := make(map[string]interface{})
+	util.MergeValues(values, p.Values)
+	if env, ok := p.Environments[environment]; ok && env != nil {
+		util.MergeValues(values, env.Values)
+	}
+	return values
+}
+
+// ScheduleFor the off-hours windows of the node pools of 'environment'
+func (p *Project) ScheduleFor(environment string) []ScheduleWindow {
+	if env, ok := p.Environments[environment]; ok && env != nil {
+		return env.Schedule
+	}
+	return nil
+}
+
+// ClusterFor the project cluster settings overridden by the ones of 'environment'
+func (p *Project) ClusterFor(environment string) Cluster {
+	if env, ok := p.Environments[environment]; ok && env != nil {
+		return p.Cluster.Merge(env.Cluster)
+	}
+	return p.Cluster
+}
+
+// Load reads the manifest of 'project' from PROJECTS_PATH (the projects folder next to the
+// executable by default). Manifests are optional: a project without one gets an empty manifest
+func Load(project string) (*Project, error) {
+	manifestPath := filepath.Join(getBaseProjectsPath(), project+".yaml")
+	if !util.FileExists(manifestPath) {
+		log.Printf("No manifest found for project %s in %s, using defaults\n", project, manifestPath)
+		return &Project{Name: project}, nil
+	}
+	return LoadFile(manifestPath)
+}
+
+// LoadFile reads a project manifest from 'manifestPath'
+func LoadFile(manifestPath string) (*Project, error) {
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading project manifest %s: %v", manifestPath, err)
+	}
+
+	var project Project
+	if err := yaml.Unmarshal(content, &project); err != nil {
+		return nil, fmt.Errorf("invalid project manifest %s: %v", manifestPath, err)
+	}
+
+	project.Values, _ = util.NormalizeYaml(project.Values).(map[string]interface{})
+	if err := project.Cluster.validate(); err != nil {
+		return nil, fmt.Errorf("invalid project manifest %s: %v", manifestPath, err)
+	}
+	for name, env := range project.Environments {
+		if env != nil {
+			env.Values, _ = util.NormalizeYaml(env.Values).(map[string]interface{})
+			if err := env.Cluster.validate(); err != nil {
+				return nil, fmt.Errorf("invalid project manifest %s, environment %s: %v", manifestPath, name, err)
+			}
+		}
+	}
+
+	for name, app := range project.Apps {
+		if app != nil && app.Deployer != "" && app.Deployer != DeployerHelm && app.Deployer != DeployerManifests {
+			return nil, fmt.Errorf("invalid deployer '%s' for app %s in %s", app.Deployer, name, manifestPath)
+		}
+	}
+
+	if project.Name == "" {
+		project.Name = filepath.Base(manifestPath[:len(manifestPath)-len(filepath.Ext(manifestPath))])
+	}
+	return &project, nil
+}
+
+func getBaseProjectsPath() string {
+	projectsPath := os.Getenv("PROJECTS_PATH")
+	if projectsPath != "" {
+		return projectsPath
+	}
+	return util.CurrentExecutableDir() + "/projects"
+}
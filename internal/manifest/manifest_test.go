@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `
+values:
+  domain: moneycol.net
+  server:
+    replicas: 1
+    memory: 512Mi
+environments:
+  prod:
+    values:
+      server:
+        replicas: 3
+apps:
+  dns-config:
+    deployer: manifests
+    path: dns
+  moneycol-server:
+    deployer: helm
+`
+
+func writeManifest(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatalf("error creating projects dir %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	manifestPath := filepath.Join(dir, "moneycol.yaml")
+	if err := ioutil.WriteFile(manifestPath, []byte(content), 0644); err != nil {
+		t.Fatalf("error writing manifest %v", err)
+	}
+	return manifestPath
+}
+
+func TestLoadsDeployerPerApp(t *testing.T) {
+	project, err := LoadFile(writeManifest(t, testManifest))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "moneycol", project.Name)
+	assert.Equal(t, DeployerManifests, project.DeployerFor("dns-config"))
+	assert.Equal(t, "dns", project.ManifestsPathFor("dns-config"))
+	assert.Equal(t, DeployerHelm, project.DeployerFor("moneycol-server"))
+	assert.Equal(t, DeployerHelm, project.DeployerFor("not-in-manifest"))
+}
+
+func TestEnvironmentValuesOverrideProjectValues(t *testing.T) {
+	project, _ := LoadFile(writeManifest(t, testManifest))
+
+	prodValues := project.ValuesFor("prod")
+	devValues := project.ValuesFor("dev")
+
+	assert.Equal(t, 3, prodValues["server"].(map[string]interface{})["replicas"])
+	assert.Equal(t, "512Mi", prodValues["server"].(map[string]interface{})["memory"])
+	assert.Equal(t, 1, devValues["server"].(map[string]interface{})["replicas"])
+}
+
+func TestInvalidDeployerIsRejected(t *testing.T) {
+	_, err := LoadFile(writeManifest(t, "apps:\n  dns-config:\n    deployer: kustomize\n"))
+
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentClusterSettingsOverrideProjectOnes(t *testing.T) {
+	project, err := LoadFile(writeManifest(t, `
+cluster:
+  zone: europe-west2-b
+  machineType: n1-standard-2
+  preemptible: true
+environments:
+  prod:
+    cluster:
+      machineType: n1-standard-4
+      preemptible: false
+`))
+	assert.Nil(t, err)
+
+	prod := project.ClusterFor("prod")
+	dev := project.ClusterFor("dev")
+
+	assert.Equal(t, "europe-west2-b", prod.Zone)
+	assert.Equal(t, "n1-standard-4", prod.MachineType)
+	assert.False(t, *prod.Preemptible)
+	assert.Equal(t, "n1-standard-2", dev.MachineType)
+	assert.True(t, *dev.Preemptible)
+}
+
+func TestScheduleOfEnvironment(t *testing.T) {
+	project, err := LoadFile(writeManifest(t, `
+environments:
+  dev:
+    schedule:
+      - nodePool: default-pool
+        scaleDown: "0 20 * * 1-5"
+        scaleUp: "0 7 * * 1-5"
+        size: 1
+  prod: {}
+`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5", Size: 1}},
+		project.ScheduleFor("dev"))
+	assert.Empty(t, project.ScheduleFor("prod"))
+	assert.Empty(t, project.ScheduleFor("qa"))
+}
+
+func TestEnvironmentRegionOverridesProjectZone(t *testing.T) {
+	project, err := LoadFile(writeManifest(t, `
+cluster:
+  zone: europe-west2-b
+environments:
+  prod:
+    cluster:
+      region: europe-west2
+`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "europe-west2", project.ClusterFor("prod").Location())
+	assert.Equal(t, "", project.ClusterFor("prod").Zone)
+	assert.Equal(t, "europe-west2-b", project.ClusterFor("dev").Location())
+}
+
+func TestClusterWithZoneAndRegionIsRejected(t *testing.T) {
+	_, err := LoadFile(writeManifest(t, "cluster:\n  zone: europe-west2-b\n  region: europe-west2\n"))
+
+	assert.NotNil(t, err)
+}
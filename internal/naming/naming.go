@@ -0,0 +1,202 @@
+// Package naming derives the names of the resources myiac creates or looks up (cluster, buckets,
+// KMS keys, releases, namespace) from the project, environment and cluster prefix, so every command
+// agrees on them. The default templates can be overridden in the 'naming' section of the project manifest
+package naming
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/iac-io/myiac/internal/manifest"
+)
+
+const (
+	ResourceCluster       = "cluster"
+	ResourceStateBucket   = "stateBucket"
+	ResourceProjectBucket = "projectBucket"
+	ResourceKeyRing       = "keyRing"
+	ResourceKey           = "key"
+	ResourceRelease       = "release"
+	ResourceNamespace     = "namespace"
+)
+
+// Resources every named resource, in the order they're printed
+var Resources = []string{ResourceCluster, ResourceStateBucket, ResourceProjectBucket, ResourceKeyRing, ResourceKey,
+	ResourceRelease, ResourceNamespace}
+
+// DefaultTemplates the names myiac has always used
+var DefaultTemplates = map[string]string{
+	ResourceCluster:       "{{ with .Prefix }}{{ . }}-{{ end }}{{ .Project }}-{{ .Environment }}",
+	ResourceStateBucket:   "{{ .Project }}-tfstate-{{ .Environment }}",
+	ResourceProjectBucket: "{{ .Project }}-myiac",
+	ResourceKeyRing:       "{{ .Project }}-keyring",
+	ResourceKey:           "{{ .Project }}-infra-key",
+	ResourceRelease:       "{{ .App }}",
+	ResourceNamespace:     "default",
+}
+
+// valid names for every resource type: lowercase letters, digits and dashes (dots and underscores
+// inside for buckets), starting and ending with a letter or digit
+var nameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9_.]*[a-z0-9])?$`)
+
+// Context what names are derived from, templates use its fields: {{ .Project }}, {{ .Environment }},
+// {{ .Prefix }} and {{ .App }}
+type Context struct {
+	Project     string
+	Environment string
+	Prefix      string
+	App         string
+}
+
+// Convention the templates of every resource
+type Convention struct {
+	sources   map[string]string
+	templates map[string]*template.Template
+	// prefixFor the cluster prefix of an environment when none is given, from the project manifest
+	prefixFor func(env string) string
+}
+
+// New a convention with 'overrides' (resource -> template) over the default templates
+func New(overrides map[string]string) (*Convention, error) {
+	convention := &Convention{sources: make(map[string]string), templates: make(map[string]*template.Template)}
+	for resource, source := range DefaultTemplates {
+		convention.sources[resource] = source
+	}
+	for resource, source := range overrides {
+		if _, known := DefaultTemplates[resource]; !known {
+			return nil, fmt.Errorf("unknown resource '%s' in naming, use one of %s", resource,
+				strings.Join(Resources, ", "))
+		}
+		convention.sources[resource] = source
+	}
+
+	sample := Context{Project: "project", Environment: "env", Prefix: "prefix", App: "app"}
+	for resource, source := range convention.sources {
+		tpl, err := template.New(resource).Option("missingkey=error").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s naming template '%s': %v", resource, source, err)
+		}
+		convention.templates[resource] = tpl
+
+		name, err := convention.execute(resource, sample)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s naming template '%s': %v", resource, source, err)
+		}
+		if !nameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%s naming template '%s' gives the invalid name '%s'", resource, source, name)
+		}
+	}
+	return convention, nil
+}
+
+// Default the convention with the default templates
+func Default() *Convention {
+	convention, _ := New(nil)
+	return convention
+}
+
+var (
+	projectConventions = make(map[string]*Convention)
+	conventionsMutex   sync.Mutex
+)
+
+// For the convention of 'project': its manifest naming templates over the default ones, and its
+// cluster prefixes. Loaded once per project
+func For(project string) (*Convention, error) {
+	conventionsMutex.Lock()
+	defer conventionsMutex.Unlock()
+	if convention, ok := projectConventions[project]; ok {
+		return convention, nil
+	}
+
+	projectManifest, err := manifest.Load(project)
+	if err != nil {
+		return nil, err
+	}
+	convention, err := New(projectManifest.Naming)
+	if err != nil {
+		return nil, fmt.Errorf("project %s: %v", project, err)
+	}
+	convention.prefixFor = func(env string) string {
+		return projectManifest.ClusterFor(env).Prefix
+	}
+
+	projectConventions[project] = convention
+	return convention, nil
+}
+
+// MustFor like For, exiting when the project manifest or its templates are invalid: a wrong name
+// would point commands to a different resource
+func MustFor(project string) *Convention {
+	convention, err := For(project)
+	if err != nil {
+		log.Fatalf("Error resolving the naming convention: %v", err)
+	}
+	return convention
+}
+
+// Name the name of 'resource' for 'ctx'
+func (c *Convention) Name(resource string, ctx Context) string {
+	// templates were run with every field on creation, they can't fail
+	name, _ := c.execute(resource, ctx)
+	return name
+}
+
+// Template the template of 'resource'
+func (c *Convention) Template(resource string) string {
+	return c.sources[resource]
+}
+
+func (c *Convention) execute(resource string, ctx Context) (string, error) {
+	tpl, ok := c.templates[resource]
+	if !ok {
+		return "", fmt.Errorf("unknown resource %s", resource)
+	}
+	var name strings.Builder
+	if err := tpl.Execute(&name, ctx); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(name.String()), nil
+}
+
+// ClusterName the cluster of an environment. Without 'prefix' the one of the project manifest is used
+func (c *Convention) ClusterName(project string, env string, prefix string) string {
+	if prefix == "" && c.prefixFor != nil {
+		prefix = c.prefixFor(env)
+	}
+	return c.Name(ResourceCluster, Context{Project: project, Environment: env, Prefix: prefix})
+}
+
+// StateBucketName the bucket with the terraform state of an environment
+func (c *Convention) StateBucketName(project string, env string) string {
+	return c.Name(ResourceStateBucket, Context{Project: project, Environment: env})
+}
+
+// ProjectBucketName the bucket myiac keeps its own per-project state in (locks, history...)
+func (c *Convention) ProjectBucketName(project string) string {
+	return c.Name(ResourceProjectBucket, Context{Project: project})
+}
+
+// KeyRingName the KMS key ring of the project secrets
+func (c *Convention) KeyRingName(project string) string {
+	return c.Name(ResourceKeyRing, Context{Project: project})
+}
+
+// KeyName the KMS key of the project secrets
+func (c *Convention) KeyName(project string) string {
+	return c.Name(ResourceKey, Context{Project: project})
+}
+
+// ReleaseName the helm release a new install of an app gets
+func (c *Convention) ReleaseName(project string, env string, app string) string {
+	return c.Name(ResourceRelease, Context{Project: project, Environment: env, App: app})
+}
+
+// NamespaceName the namespace myiac creates its own resources in
+func (c *Convention) NamespaceName(project string, env string) string {
+	return c.Name(ResourceNamespace, Context{Project: project, Environment: env})
+}
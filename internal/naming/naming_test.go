@@ -0,0 +1,74 @@
+package naming
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultNames(t *testing.T) {
+	convention := Default()
+
+	assert.Equal(t, "moneycol-dev", convention.ClusterName("moneycol", "dev", ""))
+	assert.Equal(t, "blue-moneycol-dev", convention.ClusterName("moneycol", "dev", "blue"))
+	assert.Equal(t, "moneycol-tfstate-dev", convention.StateBucketName("moneycol", "dev"))
+	assert.Equal(t, "moneycol-myiac", convention.ProjectBucketName("moneycol"))
+	assert.Equal(t, "moneycol-keyring", convention.KeyRingName("moneycol"))
+	assert.Equal(t, "moneycol-infra-key", convention.KeyName("moneycol"))
+	assert.Equal(t, "moneycol-server", convention.ReleaseName("moneycol", "dev", "moneycol-server"))
+	assert.Equal(t, "default", convention.NamespaceName("moneycol", "dev"))
+}
+
+func TestOverridesReplaceDefaultTemplates(t *testing.T) {
+	convention, err := New(map[string]string{
+		ResourceCluster: "{{ .Project }}-{{ .Environment }}-gke",
+		ResourceRelease: "{{ .App }}-{{ .Environment }}",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "moneycol-prod-gke", convention.ClusterName("moneycol", "prod", ""))
+	assert.Equal(t, "moneycol-server-prod", convention.ReleaseName("moneycol", "prod", "moneycol-server"))
+	assert.Equal(t, "moneycol-tfstate-prod", convention.StateBucketName("moneycol", "prod"))
+}
+
+func TestInvalidConventionsAreRejected(t *testing.T) {
+	for name, overrides := range map[string]map[string]string{
+		"unknown resource": {"database": "{{ .Project }}-db"},
+		"invalid template": {ResourceCluster: "{{ .Project "},
+		"unknown field":    {ResourceCluster: "{{ .Zone }}"},
+		"invalid name":     {ResourceKey: "{{ .Project }}_Key!"},
+		"empty name":       {ResourceNamespace: "{{ if .App }}{{ end }}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(overrides)
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestProjectConventionUsesManifestTemplatesAndPrefix(t *testing.T) {
+	projectsPath, _ := ioutil.TempDir("", "projects")
+	defer os.RemoveAll(projectsPath)
+	manifest := `naming:
+  stateBucket: "{{ .Project }}-{{ .Environment }}-state"
+environments:
+  prod:
+    cluster:
+      prefix: blue
+`
+	_ = ioutil.WriteFile(filepath.Join(projectsPath, "naming-test.yaml"), []byte(manifest), 0644)
+	_ = os.Setenv("PROJECTS_PATH", projectsPath)
+	defer os.Unsetenv("PROJECTS_PATH")
+
+	convention, err := For("naming-test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "naming-test-prod-state", convention.StateBucketName("naming-test", "prod"))
+	assert.Equal(t, "blue-naming-test-prod", convention.ClusterName("naming-test", "prod", ""))
+	assert.Equal(t, "green-naming-test-prod", convention.ClusterName("naming-test", "prod", "green"))
+	assert.Equal(t, "naming-test-dev", convention.ClusterName("naming-test", "dev", ""))
+}
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron a standard 5 fields cron expression (minute hour day-of-month month day-of-week), the format
+// of the CronJob schedules. Fields accept *, values, ranges (1-5), lists (1,3) and steps (*/15)
+type Cron struct {
+	expression string
+	minutes    map[int]bool
+	hours      map[int]bool
+	days       map[int]bool
+	months     map[int]bool
+	weekdays   map[int]bool
+	// the cron rule: when both days and weekdays are restricted, either of them matches
+	anyDay, anyWeekday bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a cron expression
+func ParseCron(expression string) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron '%s': expected %d fields, got %d", expression, len(cronFields), len(fields))
+	}
+
+	values := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		fieldValues, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron '%s': %v", expression, err)
+		}
+		values[i] = fieldValues
+	}
+
+	// sunday is both 0 and 7
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &Cron{
+		expression: expression,
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   values[4],
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func (c *Cron) String() string {
+	return c.expression
+}
+
+func parseCronField(field string, spec cronField) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %s '%s'", spec.name, part)
+			}
+		}
+
+		from, to := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid %s '%s'", spec.name, part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid %s '%s'", spec.name, part)
+				}
+			} else if step > 1 {
+				// 5/15 means from 5 to the end every 15
+				to = spec.max
+			}
+		}
+
+		if from < spec.min || to > spec.max || from > to {
+			return nil, fmt.Errorf("%s '%s' out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// Next the first time after 't' matching the expression, in the location of 't'
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// any expression matches within a few years (i.e. 29th of February), past that it never does
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayMatches := c.days[t.Day()]
+	weekdayMatches := c.weekdays[int(t.Weekday())]
+	if !c.anyDay && !c.anyWeekday {
+		return dayMatches || weekdayMatches
+	}
+	return dayMatches && weekdayMatches
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(value string) time.Time {
+	t, _ := time.Parse("2006-01-02 15:04", value)
+	return t
+}
+
+func TestNextOfWeekdayEvenings(t *testing.T) {
+	cron, err := ParseCron("0 20 * * 1-5")
+	assert.Nil(t, err)
+
+	// friday 2021-01-08 after 20:00 goes to monday
+	assert.Equal(t, at("2021-01-08 20:00"), cron.Next(at("2021-01-08 10:30")))
+	assert.Equal(t, at("2021-01-11 20:00"), cron.Next(at("2021-01-08 20:00")))
+}
+
+func TestNextWithStepsAndLists(t *testing.T) {
+	cron, err := ParseCron("*/15 8,18 * * *")
+	assert.Nil(t, err)
+
+	assert.Equal(t, at("2021-01-08 08:45"), cron.Next(at("2021-01-08 08:31")))
+	assert.Equal(t, at("2021-01-08 18:00"), cron.Next(at("2021-01-08 08:45")))
+}
+
+func TestNextWithSundayAsSeven(t *testing.T) {
+	cron, err := ParseCron("0 6 * * 7")
+	assert.Nil(t, err)
+
+	assert.Equal(t, at("2021-01-10 06:00"), cron.Next(at("2021-01-08 00:00")))
+}
+
+func TestNextMatchesEitherDayOfMonthOrWeekday(t *testing.T) {
+	cron, err := ParseCron("0 0 15 * 1")
+	assert.Nil(t, err)
+
+	// monday 11th comes before the 15th
+	assert.Equal(t, at("2021-01-11 00:00"), cron.Next(at("2021-01-08 00:00")))
+	assert.Equal(t, at("2021-01-15 00:00"), cron.Next(at("2021-01-11 00:00")))
+}
+
+func TestParseInvalidCron(t *testing.T) {
+	for _, expression := range []string{"", "0 20 * *", "60 20 * * *", "0 20 * * 1-8", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseCron(expression)
+		assert.NotNil(t, err, expression)
+	}
+}
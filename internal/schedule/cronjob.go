@@ -0,0 +1,214 @@
+package schedule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/manifest"
+)
+
+const (
+	// Namespace where the CronJobs and the key secret live, unless the target has one
+	Namespace = "default"
+	// KeySecretName the secret with the service account key the CronJobs authenticate with
+	KeySecretName = "myiac-schedule-key"
+	// ScheduleLabel marks the CronJobs of an environment, so the ones of removed windows are pruned
+	ScheduleLabel = "myiac.io/schedule"
+
+	keyMountPath = "/secrets"
+	// maxCronJobNameLength Kubernetes appends 11 characters to the CronJob name for the names of its jobs
+	maxCronJobNameLength  = 52
+	cronJobNameHashLength = 8
+)
+
+// cronJobTemplate the jobs run myiac from its image. batch/v1 is the only CronJob version served from
+// Kubernetes 1.25. No time zone is set, so the schedules are in UTC (the time of the controller)
+var cronJobTemplate = template.Must(template.New("cronJob").Funcs(template.FuncMap{
+	"quote": func(value string) string {
+		// a JSON string is a valid YAML double quoted scalar
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	},
+}).Parse(`apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/managed-by: myiac
+    myiac.io/schedule: {{ .Target.Environment }}
+spec:
+  schedule: {{ quote .Schedule }}
+  concurrencyPolicy: Forbid
+  successfulJobsHistoryLimit: 1
+  failedJobsHistoryLimit: 3
+  jobTemplate:
+    spec:
+      backoffLimit: 3
+      template:
+        spec:
+          restartPolicy: OnFailure
+          nodeSelector:
+            cloud.google.com/gke-nodepool: {{ .RunnerPool }}
+          containers:
+          - name: myiac
+            image: {{ .Image }}
+            command: ["myiac"]
+            args:
+            {{- range .Args }}
+            - {{ quote . }}
+            {{- end }}
+            volumeMounts:
+            - name: key
+              mountPath: {{ .KeyMountPath }}
+              readOnly: true
+          volumes:
+          - name: key
+            secret:
+              secretName: {{ .KeySecretName }}
+`))
+
+// CronJobOptions how the CronJobs run
+type CronJobOptions struct {
+	Image string
+	// RunnerPool the node pool the jobs run on, it must stay up for the scale ups to run
+	RunnerPool string
+}
+
+type cronJob struct {
+	Name          string
+	Namespace     string
+	Schedule      string
+	Target        Target
+	Image         string
+	RunnerPool    string
+	Args          []string
+	KeyMountPath  string
+	KeySecretName string
+}
+
+// RunnerPoolFor the first of 'pools' that is never scaled down to zero, the scale up jobs can't run
+// on a pool without nodes
+func RunnerPoolFor(windows []manifest.ScheduleWindow, pools []string) (string, error) {
+	scaledToZero := make(map[string]bool)
+	for _, window := range windows {
+		if window.Size == 0 {
+			scaledToZero[window.NodePool] = true
+		}
+	}
+
+	for _, pool := range pools {
+		if !scaledToZero[pool] {
+			return pool, nil
+		}
+	}
+	return "", fmt.Errorf("every node pool is scaled down to zero, the scale up jobs need one that stays up")
+}
+
+// CronJobManifests the scale down and scale up CronJobs of every window
+func CronJobManifests(target Target, windows []manifest.ScheduleWindow, options CronJobOptions) (string, error) {
+	if err := ValidateWindows(windows); err != nil {
+		return "", err
+	}
+
+	var manifests []string
+	for _, window := range windows {
+		for _, action := range []string{ActionScaleDown, ActionScaleUp} {
+			job := cronJob{
+				Name:          cronJobName(target.Environment, action, window.NodePool),
+				Namespace:     target.namespace(),
+				Schedule:      window.ScaleUp,
+				Target:        target,
+				Image:         options.Image,
+				RunnerPool:    options.RunnerPool,
+				Args:          runArgs(target, window, action),
+				KeyMountPath:  keyMountPath,
+				KeySecretName: KeySecretName,
+			}
+			if action == ActionScaleDown {
+				job.Schedule = window.ScaleDown
+			}
+
+			var rendered strings.Builder
+			if err := cronJobTemplate.Execute(&rendered, job); err != nil {
+				return "", fmt.Errorf("error rendering CronJob %s: %v", job.Name, err)
+			}
+			manifests = append(manifests, rendered.String())
+		}
+	}
+	return strings.Join(manifests, "---\n"), nil
+}
+
+// cronJobName the name of the CronJob of an action on a node pool. Names over the CronJob limit are
+// truncated and end with a hash of the full name, so they are still unique
+func cronJobName(environment string, action string, nodePool string) string {
+	name := fmt.Sprintf("myiac-%s-%s-%s", environment, action, nodePool)
+	if len(name) <= maxCronJobNameLength {
+		return name
+	}
+
+	nameHash := sha256.Sum256([]byte(name))
+	truncated := strings.TrimRight(name[:maxCronJobNameLength-cronJobNameHashLength-1], "-")
+	return truncated + "-" + hex.EncodeToString(nameHash[:])[:cronJobNameHashLength]
+}
+
+// runArgs the arguments of 'myiac schedule run' for an action of a window
+func runArgs(target Target, window manifest.ScheduleWindow, action string) []string {
+	args := []string{"--skip-preflight", "schedule", "run",
+		"--project", target.Project,
+		"--env", target.Environment,
+		"--cluster", target.ClusterName,
+	}
+	args = append(args, target.Location.Args()...)
+	args = append(args,
+		"--node-pool", window.NodePool,
+		"--action", action,
+		"--keyPath", fmt.Sprintf("%s/%s.json", keyMountPath, KeySecretName))
+	if action == ActionScaleDown {
+		args = append(args, "--size", fmt.Sprintf("%d", window.Size))
+	}
+	return args
+}
+
+// ApplyCronJobs applies the CronJobs of the windows, pruning the ones of windows no longer in the
+// manifest. Without windows every CronJob of the environment is deleted
+func ApplyCronJobs(cmdRunner commandline.CommandRunner, target Target, windows []manifest.ScheduleWindow,
+	options CronJobOptions) error {
+	selector := fmt.Sprintf("%s=%s", ScheduleLabel, target.Environment)
+	if len(windows) == 0 {
+		cmdRunner.Setup("kubectl", []string{"delete", "cronjob", "-n", target.namespace(), "-l", selector})
+		if _, err := cmdRunner.RunWithError(); err != nil {
+			return fmt.Errorf("error deleting schedule CronJobs: %v", err)
+		}
+		return nil
+	}
+
+	manifests, err := CronJobManifests(target, windows, options)
+	if err != nil {
+		return err
+	}
+
+	manifestFile, err := ioutil.TempFile("", "myiac-schedule-*.yaml")
+	if err != nil {
+		return fmt.Errorf("error creating CronJobs manifest file: %v", err)
+	}
+	defer os.Remove(manifestFile.Name())
+
+	if _, err := manifestFile.WriteString(manifests); err != nil {
+		return fmt.Errorf("error writing CronJobs manifest file: %v", err)
+	}
+	_ = manifestFile.Close()
+
+	cmdRunner.Setup("kubectl", []string{"apply", "-n", target.namespace(), "-f", manifestFile.Name(), "--prune", "-l", selector})
+	if _, err := cmdRunner.RunWithError(); err != nil {
+		return fmt.Errorf("error applying schedule CronJobs: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerPoolSkipsPoolsScaledToZero(t *testing.T) {
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", Size: 0}, {NodePool: "elasticsearch-pool", Size: 1}}
+
+	runnerPool, err := RunnerPoolFor(windows, []string{"default-pool", "elasticsearch-pool"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "elasticsearch-pool", runnerPool)
+}
+
+func TestRunnerPoolFailsWhenEveryPoolIsScaledToZero(t *testing.T) {
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", Size: 0}}
+
+	_, err := RunnerPoolFor(windows, []string{"default-pool"})
+
+	assert.NotNil(t, err)
+}
+
+func TestCronJobManifestsOfWindow(t *testing.T) {
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5", Size: 0}}
+	options := CronJobOptions{Image: "eu.gcr.io/moneycol/myiac:latest", RunnerPool: "system-pool"}
+
+	manifests, err := CronJobManifests(target, windows, options)
+
+	assert.Nil(t, err)
+	assert.Contains(t, manifests, "apiVersion: batch/v1\n")
+	assert.Contains(t, manifests, "name: myiac-dev-scale-down-default-pool")
+	assert.Contains(t, manifests, "name: myiac-dev-scale-up-default-pool")
+	assert.Contains(t, manifests, `schedule: "0 20 * * 1-5"`)
+	assert.Contains(t, manifests, `schedule: "0 7 * * 1-5"`)
+	assert.Contains(t, manifests, "myiac.io/schedule: dev")
+	assert.Contains(t, manifests, "cloud.google.com/gke-nodepool: system-pool")
+	assert.Contains(t, manifests, "image: eu.gcr.io/moneycol/myiac:latest")
+	assert.Contains(t, manifests, "- \"--size\"\n            - \"0\"")
+	assert.Contains(t, manifests, "secretName: myiac-schedule-key")
+}
+
+func TestApplyCronJobsWithoutWindowsDeletesThem(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+
+	err := ApplyCronJobs(runner, target, nil, CronJobOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"kubectl delete cronjob -n default -l myiac.io/schedule=dev"}, runner.GetCmdLines())
+}
+
+func TestCronJobsOfRegionalClusterRunWithRegion(t *testing.T) {
+	regional := Target{Project: "moneycol", Environment: "prod", ClusterName: "moneycol-prod", Location: "europe-west2"}
+	window := manifest.ScheduleWindow{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5"}
+
+	args := runArgs(regional, window, ActionScaleUp)
+
+	assert.Contains(t, strings.Join(args, " "), "--cluster moneycol-prod --region europe-west2 --node-pool default-pool")
+}
+
+func TestCronJobsGoToTheNamespaceOfTheTarget(t *testing.T) {
+	namespaced := target
+	namespaced.Namespace = "moneycol-dev"
+	windows := []manifest.ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5"}}
+
+	manifests, err := CronJobManifests(namespaced, windows, CronJobOptions{RunnerPool: "system-pool"})
+
+	assert.Nil(t, err)
+	assert.Contains(t, manifests, "namespace: moneycol-dev")
+	assert.NotContains(t, manifests, "namespace: default")
+}
+
+func TestLongCronJobNamesAreTruncatedWithHash(t *testing.T) {
+	name := cronJobName("staging", ActionScaleDown, "elasticsearch-highmem-preemptible-pool")
+	otherName := cronJobName("staging", ActionScaleDown, "elasticsearch-highmem-preemptible-pool-2")
+
+	assert.Len(t, name, maxCronJobNameLength)
+	assert.True(t, strings.HasPrefix(name, "myiac-staging-scale-down-elasticsearch-"))
+	assert.NotEqual(t, name, otherName)
+	assert.Equal(t, "myiac-dev-scale-up-default-pool", cronJobName("dev", ActionScaleUp, "default-pool"))
+}
@@ -0,0 +1,227 @@
+// Package schedule scales node pools down during off-hours and back up afterwards, following the
+// windows of the project manifest. The resizes run from CronJobs inside the cluster
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+)
+
+const (
+	ActionScaleDown = "scale-down"
+	ActionScaleUp   = "scale-up"
+
+	statePrefix = "schedule"
+)
+
+// Action a resize of a node pool due at a time
+type Action struct {
+	NodePool string
+	Action   string
+	Size     int // the size scaled down to, scale ups restore the original one
+	Time     time.Time
+}
+
+func (a Action) String() string {
+	if a.Action == ActionScaleDown {
+		return fmt.Sprintf("%s to %d nodes", a.Action, a.Size)
+	}
+	return fmt.Sprintf("%s to the original size", a.Action)
+}
+
+// ValidateWindows checks the node pool and cron expressions of every window
+func ValidateWindows(windows []manifest.ScheduleWindow) error {
+	pools := make(map[string]bool)
+	for _, window := range windows {
+		if window.NodePool == "" {
+			return fmt.Errorf("schedule window without nodePool")
+		}
+		if pools[window.NodePool] {
+			return fmt.Errorf("node pool %s has more than one schedule window", window.NodePool)
+		}
+		pools[window.NodePool] = true
+
+		if window.Size < 0 {
+			return fmt.Errorf("invalid size %d for node pool %s", window.Size, window.NodePool)
+		}
+		for _, expression := range []string{window.ScaleDown, window.ScaleUp} {
+			if _, err := ParseCron(expression); err != nil {
+				return fmt.Errorf("node pool %s: %v", window.NodePool, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NextActions the next scale down and scale up of every window after 'now', sorted by time
+func NextActions(windows []manifest.ScheduleWindow, now time.Time) ([]Action, error) {
+	if err := ValidateWindows(windows); err != nil {
+		return nil, err
+	}
+
+	var actions []Action
+	for _, window := range windows {
+		scaleDown, _ := ParseCron(window.ScaleDown)
+		scaleUp, _ := ParseCron(window.ScaleUp)
+		actions = append(actions,
+			Action{NodePool: window.NodePool, Action: ActionScaleDown, Size: window.Size, Time: scaleDown.Next(now)},
+			Action{NodePool: window.NodePool, Action: ActionScaleUp, Time: scaleUp.Next(now)})
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Time.Before(actions[j].Time)
+	})
+	return actions, nil
+}
+
+// ScaledDownHours the hours a week the node pool of 'window' spends scaled down
+func ScaledDownHours(window manifest.ScheduleWindow) (float64, error) {
+	if err := ValidateWindows([]manifest.ScheduleWindow{window}); err != nil {
+		return 0, err
+	}
+	scaleDown, _ := ParseCron(window.ScaleDown)
+	scaleUp, _ := ParseCron(window.ScaleUp)
+
+	// any week works, a week after the first action the state only depends on the expressions
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	var scaledDown time.Duration
+	down := false
+	for t := start.AddDate(0, 0, -7); t.Before(end); {
+		next, nextDown := scaleDown.Next(t), true
+		if up := scaleUp.Next(t); !up.IsZero() && (next.IsZero() || !up.After(next)) {
+			next, nextDown = up, false
+		}
+		if next.IsZero() || next.After(end) {
+			next = end
+		}
+		if down && next.After(start) {
+			from := t
+			if from.Before(start) {
+				from = start
+			}
+			scaledDown += next.Sub(from)
+		}
+		t, down = next, nextDown
+	}
+	return scaledDown.Hours(), nil
+}
+
+// ScaledDownPool the original size (nodes per zone) of a node pool, stored when it's scaled down and removed once restored
+type ScaledDownPool struct {
+	NodePool     string    `json:"nodePool"`
+	OriginalSize int       `json:"originalSize"`
+	ScaledDownTo int       `json:"scaledDownTo"`
+	ScaledDownAt time.Time `json:"scaledDownAt"`
+}
+
+// Target the cluster whose node pools are resized
+type Target struct {
+	Project     string
+	Environment string
+	ClusterName string
+	Location    gcp.Location
+	// Namespace of the CronJobs, the default one when empty
+	Namespace string
+}
+
+func (t Target) namespace() string {
+	if t.Namespace == "" {
+		return Namespace
+	}
+	return t.Namespace
+}
+
+// Scaler resizes the node pools of a cluster, keeping their original sizes in object storage
+type Scaler struct {
+	target     Target
+	storage    gcp.ObjectStorageCache
+	bucketName string
+	cmdRunner  commandline.CommandRunner
+	now        func() time.Time
+}
+
+// NewScaler a Scaler keeping the original sizes in 'bucketName'
+func NewScaler(target Target, storage gcp.ObjectStorageCache, bucketName string,
+	cmdRunner commandline.CommandRunner) *Scaler {
+	return &Scaler{target: target, storage: storage, bucketName: bucketName, cmdRunner: cmdRunner, now: time.Now}
+}
+
+// NewDefaultScaler a Scaler keeping the original sizes in the project bucket
+func NewDefaultScaler(target Target) *Scaler {
+	return NewScaler(target, gcp.NewDefaultObjectStorageCache(), gcp.ProjectBucketName(target.Project),
+		commandline.NewEmpty())
+}
+
+// ScaleDown stores the current size of the pool and resizes it to 'size'. A pool already at or below
+// 'size' is left as it is, so running it twice never overwrites the original size
+func (s *Scaler) ScaleDown(nodePool string, size int) error {
+	t := s.target
+	currentSize, err := gcp.NodePoolSize(s.cmdRunner, t.Project, t.ClusterName, t.Location, nodePool)
+	if err != nil {
+		return err
+	}
+	if currentSize <= size {
+		log.Printf("Node pool %s already has %d nodes, not scaling it down\n", nodePool, currentSize)
+		return nil
+	}
+
+	scaledDown := ScaledDownPool{NodePool: nodePool, OriginalSize: currentSize, ScaledDownTo: size, ScaledDownAt: s.now()}
+	scaledDownJson, err := json.Marshal(scaledDown)
+	if err != nil {
+		return fmt.Errorf("error serializing original size of %s: %v", nodePool, err)
+	}
+	if err := s.storage.Write(nil, s.bucketName, s.stateKey(nodePool), string(scaledDownJson)); err != nil {
+		return fmt.Errorf("error saving original size of %s: %v", nodePool, err)
+	}
+
+	return gcp.ResizeNodePool(s.cmdRunner, t.Project, t.ClusterName, t.Location, nodePool, size)
+}
+
+// ScaleUp restores the size the pool had before being scaled down. Nothing is done if it wasn't
+func (s *Scaler) ScaleUp(nodePool string) error {
+	scaledDown, err := s.ScaledDown(nodePool)
+	if err != nil {
+		return err
+	}
+	if scaledDown == nil {
+		log.Printf("Node pool %s wasn't scaled down, nothing to restore\n", nodePool)
+		return nil
+	}
+
+	t := s.target
+	err = gcp.ResizeNodePool(s.cmdRunner, t.Project, t.ClusterName, t.Location, nodePool, scaledDown.OriginalSize)
+	if err != nil {
+		return err
+	}
+	return s.storage.Delete(nil, s.bucketName, s.stateKey(nodePool))
+}
+
+// ScaledDown the original size of a pool currently scaled down, nil if it isn't
+func (s *Scaler) ScaledDown(nodePool string) (*ScaledDownPool, error) {
+	content, err := s.storage.Read(nil, s.bucketName, s.stateKey(nodePool))
+	if errors.Is(err, gcp.ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading original size of %s: %v", nodePool, err)
+	}
+
+	var scaledDown ScaledDownPool
+	if err := json.Unmarshal([]byte(fmt.Sprintf("%v", content)), &scaledDown); err != nil {
+		return nil, fmt.Errorf("error parsing original size of %s: %v", nodePool, err)
+	}
+	return &scaledDown, nil
+}
+
+func (s *Scaler) stateKey(nodePool string) string {
+	return fmt.Sprintf("%s/%s/%s.json", statePrefix, s.target.Environment, nodePool)
+}
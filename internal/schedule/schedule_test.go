@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/gcp"
+	"github.com/iac-io/myiac/internal/manifest"
+	"github.com/iac-io/myiac/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	describeCmd = "gcloud container node-pools describe default-pool --cluster moneycol-dev --zone europe-west2-b " +
+		"--project moneycol --format json"
+	instanceGroupUrl = "https://www.googleapis.com/compute/v1/projects/moneycol/zones/europe-west2-b/instanceGroupManagers/gke-grp"
+	targetSizeCmd    = "gcloud compute instance-groups managed describe " + instanceGroupUrl + " --format value(targetSize)"
+)
+
+var target = Target{Project: "moneycol", Environment: "dev", ClusterName: "moneycol-dev", Location: "europe-west2-b"}
+
+type fakeCommands interface {
+	commandline.CommandRunner
+	FakeCommand(cmdLine string, output string)
+}
+
+// newTestScaler a scaler of default-pool with 'currentSize' nodes, storing the state in a temp dir
+func newTestScaler(t *testing.T, runner fakeCommands, currentSize string) *Scaler {
+	dir, err := ioutil.TempDir("", "schedule")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	runner.FakeCommand(describeCmd, `{"name": "default-pool", "instanceGroupUrls": ["`+instanceGroupUrl+`"]}`)
+	runner.FakeCommand(targetSizeCmd, currentSize)
+
+	scaler := NewScaler(target, gcp.NewFileObjectStorageCache(dir), "moneycol-bucket", runner)
+	scaler.now = func() time.Time { return at("2021-01-08 20:00") }
+	return scaler
+}
+
+func TestNextActionsSortedByTime(t *testing.T) {
+	windows := []manifest.ScheduleWindow{
+		{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5", Size: 0},
+		{NodePool: "elasticsearch-pool", ScaleDown: "0 19 * * 1-5", ScaleUp: "30 7 * * 1-5", Size: 1},
+	}
+
+	actions, err := NextActions(windows, at("2021-01-08 10:00"))
+
+	assert.Nil(t, err)
+	assert.Len(t, actions, 4)
+	assert.Equal(t, Action{NodePool: "elasticsearch-pool", Action: ActionScaleDown, Size: 1, Time: at("2021-01-08 19:00")}, actions[0])
+	assert.Equal(t, Action{NodePool: "default-pool", Action: ActionScaleDown, Size: 0, Time: at("2021-01-08 20:00")}, actions[1])
+	assert.Equal(t, Action{NodePool: "default-pool", Action: ActionScaleUp, Time: at("2021-01-11 07:00")}, actions[2])
+	assert.Equal(t, Action{NodePool: "elasticsearch-pool", Action: ActionScaleUp, Time: at("2021-01-11 07:30")}, actions[3])
+}
+
+func TestValidateWindows(t *testing.T) {
+	assert.NotNil(t, ValidateWindows([]manifest.ScheduleWindow{{ScaleDown: "0 20 * * *", ScaleUp: "0 7 * * *"}}))
+	assert.NotNil(t, ValidateWindows([]manifest.ScheduleWindow{{NodePool: "default-pool", ScaleDown: "0 20 * *", ScaleUp: "0 7 * * *"}}))
+	assert.NotNil(t, ValidateWindows([]manifest.ScheduleWindow{
+		{NodePool: "default-pool", ScaleDown: "0 20 * * *", ScaleUp: "0 7 * * *"},
+		{NodePool: "default-pool", ScaleDown: "0 21 * * *", ScaleUp: "0 6 * * *"},
+	}))
+}
+
+func TestScaledDownHoursOfAWeek(t *testing.T) {
+	// weeknights and the weekend, from Friday 20:00 to Monday 07:00
+	weeknights := manifest.ScheduleWindow{NodePool: "default-pool", ScaleDown: "0 20 * * 1-5", ScaleUp: "0 7 * * 1-5"}
+	hours, err := ScaledDownHours(weeknights)
+	assert.Nil(t, err)
+	assert.Equal(t, 4*11+59.0, hours)
+
+	nightly := manifest.ScheduleWindow{NodePool: "default-pool", ScaleDown: "30 19 * * *", ScaleUp: "30 7 * * *"}
+	hours, err = ScaledDownHours(nightly)
+	assert.Nil(t, err)
+	assert.Equal(t, 7*12.0, hours)
+
+	_, err = ScaledDownHours(manifest.ScheduleWindow{NodePool: "default-pool", ScaleDown: "0 25 * * *", ScaleUp: "0 7 * * *"})
+	assert.NotNil(t, err)
+}
+
+func TestScaleDownStoresOriginalSize(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	scaler := newTestScaler(t, runner, "3")
+
+	err := scaler.ScaleDown("default-pool", 0)
+
+	assert.Nil(t, err)
+	assert.Contains(t, runner.GetCmdLines(), "gcloud container clusters resize moneycol-dev --node-pool default-pool "+
+		"--num-nodes 0 --zone europe-west2-b --project moneycol -q")
+	scaledDown, err := scaler.ScaledDown("default-pool")
+	assert.Nil(t, err)
+	assert.Equal(t, &ScaledDownPool{NodePool: "default-pool", OriginalSize: 3, ScaledDownTo: 0,
+		ScaledDownAt: at("2021-01-08 20:00")}, scaledDown)
+}
+
+func TestScaleDownTwiceKeepsOriginalSize(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	scaler := newTestScaler(t, runner, "3")
+	assert.Nil(t, scaler.ScaleDown("default-pool", 0))
+
+	// the pool is already at 0 when the job runs again
+	runner.FakeCommand(targetSizeCmd, "0")
+	assert.Nil(t, scaler.ScaleDown("default-pool", 0))
+
+	scaledDown, err := scaler.ScaledDown("default-pool")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, scaledDown.OriginalSize)
+}
+
+func TestScaleUpRestoresOriginalSize(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	scaler := newTestScaler(t, runner, "3")
+	assert.Nil(t, scaler.ScaleDown("default-pool", 1))
+
+	err := scaler.ScaleUp("default-pool")
+
+	assert.Nil(t, err)
+	cmdLines := runner.GetCmdLines()
+	assert.Equal(t, "gcloud container clusters resize moneycol-dev --node-pool default-pool --num-nodes 3 "+
+		"--zone europe-west2-b --project moneycol -q", cmdLines[len(cmdLines)-1])
+	scaledDown, err := scaler.ScaledDown("default-pool")
+	assert.Nil(t, err)
+	assert.Nil(t, scaledDown)
+}
+
+func TestScaleUpWithoutScaleDownDoesNothing(t *testing.T) {
+	runner := testutil.FakeCommandRunner("")
+	scaler := newTestScaler(t, runner, "3")
+
+	err := scaler.ScaleUp("default-pool")
+
+	assert.Nil(t, err)
+	assert.Empty(t, runner.GetCmdLines())
+}
@@ -1,11 +1,14 @@
 package secret
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
-	"strings"
 
-	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type KubernetesSecretRunner interface {
@@ -16,74 +19,75 @@ type KubernetesSecretRunner interface {
 }
 
 type kubernetesRunner struct {
-	cmdRunner commandline.CommandRunner
+	client *kubernetes.Client
 }
 
-// NewKubernetesRunner creates a command runner for kubernetes secret-related operations
-func NewKubernetesRunner(commandRunner commandline.CommandRunner) KubernetesSecretRunner {
-	return &kubernetesRunner{cmdRunner: commandRunner}
+// NewKubernetesRunner creates a runner for kubernetes secret-related operations
+func NewKubernetesRunner(client *kubernetes.Client) KubernetesSecretRunner {
+	return &kubernetesRunner{client: client}
 }
 
 // CreateTlsSecret create a TLS secret in Kubernetes, used to store SSL certificates from its cert and key files
-// note: it deletes any existing secret with the same name in the same namespace
+// note: it replaces any existing secret with the same name in the same namespace
 func (kr kubernetesRunner) CreateTlsSecret(name string, namespace string, keyFile string, certFile string) {
-	deleteSecret(kr.cmdRunner, name, namespace)
-	keysArg := ""
-
 	fmt.Printf("Adding key file: %s -> %s", keyFile, "*****\n")
-	keyArg := fmt.Sprintf("--key=%s", keyFile)
 	fmt.Printf("Adding cert file: %s -> %s", certFile, "*****\n")
-	certArg := fmt.Sprintf("--cert=%s", certFile)
-
-	keysArg = strings.TrimSpace(keysArg)
-	argsArray := []string{"-n", namespace, "create", "secret", "tls", name, keyArg, certArg}
-
-	kr.cmdRunner.SetupWithoutOutput("kubectl", argsArray)
-	kr.cmdRunner.Run()
+	kr.applySecret(name, namespace, corev1.SecretTypeTLS, map[string][]byte{
+		corev1.TLSPrivateKeyKey: readSecretFile(keyFile),
+		corev1.TLSCertKey:       readSecretFile(certFile),
+	})
 }
 
-// CreateSecret creates a generic secret in a Kubernetes namespace from an existing
-// service account key JSON file
+// CreateFileSecret creates a generic secret in a Kubernetes namespace from an existing
+// service account key JSON file, as <name>.json
 //
 // See: https://stackoverflow.com/questions/45879498/how-can-i-update-a-secret-on-kubernetes-when-it-is-generated-from-a-file
-// Example: kubectl create secret generic firestore-key --from-file=key.json=/path/to/moneycol-firestore-collections-api.json
+// Same as: kubectl create secret generic firestore-key --from-file=firestore-key.json=/path/to/moneycol-firestore-collections-api.json
 func (kr kubernetesRunner) CreateFileSecret(name string, namespace string, jsonKeyPath string) {
-	deleteSecret(kr.cmdRunner, name, namespace)
-	fromFileArg := fmt.Sprintf("--from-file=%s.json=%s", name, jsonKeyPath)
-	cmdLine := fmt.Sprintf("kubectl create secret generic %s %s -n %s", name, fromFileArg, namespace)
-	kr.cmdRunner.SetupCmdLine(cmdLine)
-	kr.cmdRunner.Run()
+	kr.applySecret(name, namespace, corev1.SecretTypeOpaque, map[string][]byte{
+		name + ".json": readSecretFile(jsonKeyPath),
+	})
 }
 
+// FindSecret the name of the secret, empty when it doesn't exist
 func (kr kubernetesRunner) FindSecret(name string, namespace string) string {
-	argsArray := []string{"get", "secret", name, "-n", namespace}
-	kr.cmdRunner.SetupWithoutOutput("kubectl", argsArray)
-	cmdOutput := kr.cmdRunner.Run()
-	return cmdOutput.Output
+	secret, err := kr.client.GetSecret(context.Background(), namespace, name)
+	if err != nil {
+		log.Fatalf("kubernetes secret: %v", err)
+	}
+	if secret == nil {
+		return ""
+	}
+	return secret.Name
 }
 
-// kubectl create secret generic dev-db-secret --from-literal=username=devuser --from-literal=password='S!B\*d$zDsb='
+// Same as: kubectl create secret generic dev-db-secret --from-literal=username=devuser --from-literal=password='S!B\*d$zDsb='
 func (kr kubernetesRunner) CreateLiteralSecret(name string, namespace string, literalsMap map[string]string) {
-	log.Printf("kubernetes secret: clearing any existing secret with name %s in namespace %s first...", name, namespace)
-	deleteSecret(kr.cmdRunner, name, namespace)
-	fromLiteralArg := ""
+	data := make(map[string][]byte)
 	for k, v := range literalsMap {
 		fmt.Printf("Adding secret literal: %s -> %s", k, "*****\n")
-		fromLiteralArg += fmt.Sprintf("--from-literal=%s=%s ", k, v)
+		data[k] = []byte(v)
 	}
+	kr.applySecret(name, namespace, corev1.SecretTypeOpaque, data)
+}
 
-	fromLiteralArg = strings.TrimSpace(fromLiteralArg)
-
-	cmdLine := fmt.Sprintf("kubectl create secret generic %s %s -n %s", name, fromLiteralArg, namespace)
-	kr.cmdRunner.SetupCmdLine(cmdLine)
-	kr.cmdRunner.SetSuppressOutput(true)
-	kr.cmdRunner.Run()
+func (kr kubernetesRunner) applySecret(name string, namespace string, secretType corev1.SecretType,
+	data map[string][]byte) {
+	log.Printf("kubernetes secret: saving secret %s in namespace %s, replacing any existing one", name, namespace)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       secretType,
+		Data:       data,
+	}
+	if err := kr.client.ApplySecret(context.Background(), secret); err != nil {
+		log.Fatalf("kubernetes secret: %v", err)
+	}
 }
 
-func deleteSecret(cmdRunner commandline.CommandRunner, name string, namespace string) {
-	cmdLine := fmt.Sprintf("kubectl delete secret %s -n %s", name, namespace)
-	cmdRunner.SetupCmdLine(cmdLine)
-	cmdRunner.SetSuppressOutput(true)
-	cmdRunner.IgnoreError(true)
-	cmdRunner.Run()
+func readSecretFile(path string) []byte {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("kubernetes secret: error reading %s: %v", path, err)
+	}
+	return content
 }
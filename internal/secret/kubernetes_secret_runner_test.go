@@ -1,58 +1,59 @@
 package secret
 
 import (
-	"fmt"
+	"context"
+	"io/ioutil"
+	"os"
 	"testing"
 
-	"github.com/iac-io/myiac/testutil"
+	"github.com/iac-io/myiac/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCreateFileSecret(t *testing.T) {
 	// setup
-	cmdLine := testutil.FakeCommandRunner("secret default/test-secret created")
-	kubernetesRunner := NewKubernetesRunner(cmdLine)
+	client := kubernetes.NewClientFromClientset(fake.NewSimpleClientset())
+	kubernetesRunner := NewKubernetesRunner(client)
 
 	// given
-	filePath := "/tmp/filepath"
-	secretName := "test-Secret-Name"
+	keyFile, _ := ioutil.TempFile("", "key-*.json")
+	defer os.Remove(keyFile.Name())
+	_, _ = keyFile.WriteString(`{"type": "service_account"}`)
+	secretName := "test-secret-name"
 
 	// when
-	kubernetesRunner.CreateFileSecret(secretName, "default", filePath)
+	kubernetesRunner.CreateFileSecret(secretName, "default", keyFile.Name())
 
 	// then
-	expectedDeleteSecretCmdLine := "kubectl delete secret test-Secret-Name -n default"
-	expectedCreateSecretCmdLine := fmt.Sprintf("kubectl create secret generic %s "+
-		"--from-file=%s.json=%s -n default", secretName, secretName, filePath)
-	actualDeleteSecretCmdLine := cmdLine.CmdLines[0]
-	actualCreateSecretCmdLine := cmdLine.CmdLines[1]
-
-	assert.Equal(t, expectedCreateSecretCmdLine, actualCreateSecretCmdLine)
-	assert.Equal(t, expectedDeleteSecretCmdLine, actualDeleteSecretCmdLine)
+	secret, err := client.GetSecret(context.Background(), "default", secretName)
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.SecretTypeOpaque, secret.Type)
+	assert.Equal(t, map[string][]byte{"test-secret-name.json": []byte(`{"type": "service_account"}`)}, secret.Data)
 }
 
-func TestCreateLiteralSecret(t *testing.T) {
+func TestCreateLiteralSecretReplacesExistingOne(t *testing.T) {
 	// setup
-	cmdLine := testutil.FakeCommandRunner("output")
-	kubernetesRunner := NewKubernetesRunner(cmdLine)
-
-	// given
-	secretName := "key-value"
-	key := "testkey"
-	value := "testValue"
-	literalMap := make(map[string]string)
-	literalMap[key] = value
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "key-value", Namespace: "default"},
+		Type: corev1.SecretTypeOpaque, Data: map[string][]byte{"oldkey": []byte("oldValue")}}
+	client := kubernetes.NewClientFromClientset(fake.NewSimpleClientset(existing))
+	kubernetesRunner := NewKubernetesRunner(client)
 
 	// when
-	kubernetesRunner.CreateLiteralSecret(secretName, "default", literalMap)
+	kubernetesRunner.CreateLiteralSecret("key-value", "default", map[string]string{"testkey": "testValue"})
 
 	// then
-	expectedDeleteSecretCmdLine := "kubectl delete secret key-value -n default"
-	expectedCreateSecretCmdLine := fmt.Sprintf("kubectl create secret generic %s "+
-		"--from-literal=%s=%s -n default", secretName, key, value)
-	actualDeleteSecretCmdLine := cmdLine.CmdLines[0]
-	actualCreateSecretCmdLine := cmdLine.CmdLines[1]
-
-	assert.Equal(t, expectedCreateSecretCmdLine, actualCreateSecretCmdLine)
-	assert.Equal(t, expectedDeleteSecretCmdLine, actualDeleteSecretCmdLine)
+	secret, err := client.GetSecret(context.Background(), "default", "key-value")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"testkey": []byte("testValue")}, secret.Data)
+}
+
+func TestFindSecret(t *testing.T) {
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	kubernetesRunner := NewKubernetesRunner(kubernetes.NewClientFromClientset(fake.NewSimpleClientset(existing)))
+
+	assert.Equal(t, "db", kubernetesRunner.FindSecret("db", "default"))
+	assert.Equal(t, "", kubernetesRunner.FindSecret("db", "other"))
 }
@@ -1,9 +1,10 @@
 package secret
 
 import (
+	"log"
 	"os"
 
-	"github.com/iac-io/myiac/internal/commandline"
+	"github.com/iac-io/myiac/internal/kubernetes"
 )
 
 const tlsKeyPathTmp = "/tmp/tls.key"
@@ -41,8 +42,13 @@ func NewKubernetesSecretManager(namespace string, kubernetesRunner KubernetesSec
 	}
 }
 
+// CreateKubernetesSecretManager a SecretManager for the cluster of the current kubeconfig context
 func CreateKubernetesSecretManager(namespace string) SecretManager {
-	return NewKubernetesSecretManager(namespace, NewKubernetesRunner(commandline.NewEmpty()))
+	client, err := kubernetes.NewDefaultClient()
+	if err != nil {
+		log.Fatalf("kubernetes secret: %v", err)
+	}
+	return NewKubernetesSecretManager(namespace, NewKubernetesRunner(client))
 }
 
 func (ksm kubernetesSecretManager) CreateTlsSecret(secret TlsSecret) {
@@ -1,20 +1,20 @@
 package secret
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"os"
 	"testing"
 
-	"github.com/iac-io/myiac/testutil"
+	"github.com/iac-io/myiac/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCreateSecret(t *testing.T) {
 	// setup
-	cmdLine := testutil.FakeCommandRunner("test-output")
-	kubernetesRunner := NewKubernetesRunner(cmdLine)
-	secretManager := NewKubernetesSecretManager("default", kubernetesRunner)
+	client := kubernetes.NewClientFromClientset(fake.NewSimpleClientset())
+	secretManager := NewKubernetesSecretManager("default", NewKubernetesRunner(client))
 
 	// given
 	filePath := "/tmp/filepath"
@@ -30,13 +30,7 @@ func TestCreateSecret(t *testing.T) {
 
 	// then
 	//TODO: should validate snake case in the secret name (camelCase failures)
-	expectedDeleteSecretCmdLine := "kubectl delete secret test-secret-name -n default"
-	expectedCreateSecretCmdLine :=
-		fmt.Sprintf("kubectl create secret generic %s "+
-			"--from-file=%s.json=%s -n default", secretName, secretName, filePath)
-	actualDeleteSecretCmdLine := cmdLine.CmdLines[0]
-	actualCreateSecretCmdLine := cmdLine.CmdLines[1]
-
-	assert.Equal(t, expectedCreateSecretCmdLine, actualCreateSecretCmdLine)
-	assert.Equal(t, expectedDeleteSecretCmdLine, actualDeleteSecretCmdLine)
+	secret, err := client.GetSecret(context.Background(), "default", secretName)
+	assert.Nil(t, err)
+	assert.Contains(t, secret.Data, "test-secret-name.json")
 }
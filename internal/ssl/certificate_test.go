@@ -1,19 +1,22 @@
 package ssl
 
 import (
+	"context"
 	"testing"
 
+	"github.com/iac-io/myiac/internal/kubernetes"
 	"github.com/iac-io/myiac/internal/secret"
 	"github.com/iac-io/myiac/internal/util"
-	"github.com/iac-io/myiac/testutil"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCreateTlsCertificate(t *testing.T) {
 	// setup
 	domain := "test-domain"
-	cmdLine := testutil.FakeCommandRunner(domain)
-	kubernetesRunner := secret.NewKubernetesRunner(cmdLine)
+	client := kubernetes.NewClientFromClientset(fake.NewSimpleClientset())
+	kubernetesRunner := secret.NewKubernetesRunner(client)
 	secretManager := secret.NewKubernetesSecretManager("default", kubernetesRunner)
 
 	// given
@@ -29,14 +32,11 @@ func TestCreateTlsCertificate(t *testing.T) {
 	certStore.Register(certificate)
 
 	// then
-	expectedDeleteSecretCmdLine := "kubectl delete secret test-domain -n default"
-	expectedCreateSecretCmdLine :=
-		"kubectl -n default create secret tls test-domain --key=/tmp/tls.key --cert=/tmp/tls.crt"
-	actualDeleteSecretCmdLine := cmdLine.CmdLines[0]
-	actualCreateSecretCmdLine := cmdLine.CmdLines[1]
-
 	createdSecretName := kubernetesRunner.FindSecret(domain, "default")
-	assert.Contains(t, createdSecretName, domain)
-	assert.Equal(t, expectedCreateSecretCmdLine, actualCreateSecretCmdLine)
-	assert.Equal(t, expectedDeleteSecretCmdLine, actualDeleteSecretCmdLine)
+	assert.Equal(t, domain, createdSecretName)
+
+	tlsSecret, _ := client.GetSecret(context.Background(), "default", domain)
+	assert.Equal(t, corev1.SecretTypeTLS, tlsSecret.Type)
+	assert.Equal(t, []byte("testCert"), tlsSecret.Data[corev1.TLSCertKey])
+	assert.Equal(t, []byte("testKey"), tlsSecret.Data[corev1.TLSPrivateKeyKey])
 }
@@ -3,21 +3,22 @@ locals {
   firewall_source_ranges  = "81.144.154.0/24"
   network_cidr            = "10.254.0.0/16"
 
-  cluster_name            = "${var.project}-${var.environment}"
+  default_cluster_name    = var.cluster_prefix == "" ? "${var.project}-${var.environment}" : "${var.cluster_prefix}-${var.project}-${var.environment}"
+  cluster_name            = var.cluster_name == "" ? local.default_cluster_name : var.cluster_name
+  # cluster_zone is a region for regional clusters (europe-west2) or a zone (europe-west2-b)
+  location_parts          = split("-", var.cluster_zone)
+  region                  = join("-", slice(local.location_parts, 0, 2))
   applications_pool_name  = "default-pool"
 }
 
 provider "google" {
   project     = var.project
-  region      = var.cluster_zone
+  region      = local.region
 }
 
-//TODO: Find a way to pass those on run.
+// Partial configuration: the bucket and prefix of the environment are passed by myiac on init
 terraform {
-  backend "gcs" {
-    bucket = "ozzy-playground-tfstate-dev"
-    prefix = "terraform/state/cluster"
-  }
+  backend "gcs" {}
 }
 
 data "terraform_remote_state" "state" {
@@ -58,11 +59,11 @@ resource "google_container_node_pool" "applications_node_pool" {
   name               = local.applications_pool_name
   location           = google_container_cluster.cluster.location
   cluster            = google_container_cluster.cluster.name
-  initial_node_count = 2
+  initial_node_count = var.applications_node_count
 
   autoscaling {
     # Minimum number of nodes in the NodePool. Must be >=0 and <= max_node_count.
-    min_node_count = 2
+    min_node_count = var.applications_node_count
 
     # Maximum number of nodes in the NodePool. Must be >= min_node_count.
     max_node_count = var.applications_max_node_count
@@ -74,7 +75,7 @@ resource "google_container_node_pool" "applications_node_pool" {
   }
 
   node_config {
-    preemptible  = true
+    preemptible  = var.applications_preemptible
     machine_type = var.applications_machine_type
     disk_size_gb = 10
 
@@ -96,7 +97,7 @@ resource "google_compute_subnetwork" "gke_subnet" {
   project       = var.project
   name          = "${var.project}-${var.environment}-gke-subnet"
   ip_cidr_range = local.network_cidr
-  region        = "europe-west2"
+  region        = local.region
   network       = google_compute_network.gke_network.self_link
 }
 
@@ -0,0 +1,15 @@
+output "cluster_name" {
+  value = google_container_cluster.cluster.name
+}
+
+output "cluster_zone" {
+  value = google_container_cluster.cluster.location
+}
+
+output "cluster_endpoint" {
+  value = google_container_cluster.cluster.endpoint
+}
+
+output "node_pools" {
+  value = [google_container_node_pool.applications_node_pool.name]
+}
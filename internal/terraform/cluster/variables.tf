@@ -6,6 +6,7 @@ variable "environment" {
   type = string
 }
 
+# zone of a zonal cluster or region of a regional one, node counts are per zone
 variable "cluster_zone" {
   type = string
 }
@@ -32,4 +33,24 @@ variable "k8s_master_version" {
 
 variable "k8s_node_pool_version" {
   type = string
-}
\ No newline at end of file
+}
+variable "cluster_prefix" {
+  type    = string
+  default = ""
+}
+
+# set by myiac from its naming convention, [cluster_prefix-]project-environment when empty
+variable "cluster_name" {
+  type    = string
+  default = ""
+}
+
+variable "applications_node_count" {
+  type    = number
+  default = 2
+}
+
+variable "applications_preemptible" {
+  type    = bool
+  default = true
+}
@@ -0,0 +1,110 @@
+// Package terraform ships the default terraform modules inside the binary, so they're available
+// wherever myiac runs without a checkout of this repository
+package terraform
+
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+const clusterModule = "cluster"
+
+// modules only the config files, generated ones (variables, plans, .terraform) never get embedded
+//
+//go:embed cluster/*.tf cluster/terraform.tfvars
+var modules embed.FS
+
+// ClusterModuleVersion identifies the content of the embedded cluster module, it changes whenever
+// any of its files does
+func ClusterModuleVersion() string {
+	hash := sha256.New()
+	files, _ := moduleFiles(clusterModule)
+	for _, file := range files {
+		content, _ := modules.ReadFile(path.Join(clusterModule, file))
+		_, _ = fmt.Fprintf(hash, "%s\n%d\n", file, len(content))
+		_, _ = hash.Write(content)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:12]
+}
+
+// ExtractClusterModule the dir of the embedded cluster module in 'cacheDir', versioned so upgrading
+// myiac never runs a stale copy. An existing copy is reused, keeping its initialized .terraform
+func ExtractClusterModule(cacheDir string) (string, error) {
+	moduleDir := filepath.Join(cacheDir, "terraform", clusterModule, ClusterModuleVersion())
+	if _, err := os.Stat(moduleDir); err == nil {
+		return moduleDir, nil
+	}
+
+	// extracted to a temporary dir first, so an interrupted extraction never leaves a partial module
+	if err := os.MkdirAll(filepath.Dir(moduleDir), 0755); err != nil {
+		return "", fmt.Errorf("error creating terraform modules cache: %v", err)
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(moduleDir), ".extract-")
+	if err != nil {
+		return "", fmt.Errorf("error creating terraform modules cache: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := WriteClusterModule(tmpDir, true); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, moduleDir); err != nil {
+		return "", fmt.Errorf("error extracting cluster module to %s: %v", moduleDir, err)
+	}
+	return moduleDir, nil
+}
+
+// WriteClusterModule writes the files of the embedded cluster module into 'dir'. Existing files
+// are only replaced with 'overwrite'
+func WriteClusterModule(dir string, overwrite bool) error {
+	files, err := moduleFiles(clusterModule)
+	if err != nil {
+		return err
+	}
+
+	if !overwrite {
+		for _, file := range files {
+			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				return fmt.Errorf("%s already exists in %s", file, dir)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating dir %s: %v", dir, err)
+	}
+	for _, file := range files {
+		content, err := modules.ReadFile(path.Join(clusterModule, file))
+		if err != nil {
+			return fmt.Errorf("error reading embedded %s: %v", file, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), content, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// moduleFiles the names of the files of an embedded module, sorted
+func moduleFiles(module string) ([]string, error) {
+	entries, err := fs.ReadDir(modules, module)
+	if err != nil {
+		return nil, fmt.Errorf("error reading embedded module %s: %v", module, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,52 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterModuleIsExtractedToVersionedDir(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "cache")
+	defer os.RemoveAll(cacheDir)
+
+	moduleDir, err := ExtractClusterModule(cacheDir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "terraform", "cluster", ClusterModuleVersion()), moduleDir)
+	for _, file := range []string{"cluster.tf", "outputs.tf", "variables.tf", "terraform.tfvars"} {
+		assert.FileExists(t, filepath.Join(moduleDir, file))
+	}
+}
+
+func TestExtractedClusterModuleIsReused(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "cache")
+	defer os.RemoveAll(cacheDir)
+	moduleDir, _ := ExtractClusterModule(cacheDir)
+	_ = os.Mkdir(filepath.Join(moduleDir, ".terraform"), 0755)
+
+	reusedDir, err := ExtractClusterModule(cacheDir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, moduleDir, reusedDir)
+	assert.DirExists(t, filepath.Join(reusedDir, ".terraform"))
+}
+
+func TestScaffoldDoesNotOverwriteExistingConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "project")
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, "cluster.tf"), []byte("# edited"), 0644)
+
+	err := WriteClusterModule(dir, false)
+	assert.NotNil(t, err)
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "cluster.tf"))
+	assert.Equal(t, "# edited", string(content))
+
+	err = WriteClusterModule(dir, true)
+	assert.Nil(t, err)
+	content, _ = ioutil.ReadFile(filepath.Join(dir, "cluster.tf"))
+	assert.Contains(t, string(content), "google_container_cluster")
+}
@@ -37,10 +37,25 @@ func CurrentExecutableDir() string {
 		log.Fatalf("Error getting the directory of this executable %s", err)
 	}
 
-	fmt.Printf("This executable full path directory is: %s\n", location)
+	log.Printf("This executable full path directory is: %s\n", location)
 	return location
 }
 
+// CurrentUserIdentity identifies who runs myiac as user@host, used to record ownership of actions
+func CurrentUserIdentity() string {
+	username := "unknown"
+	if current, err := user.Current(); err == nil {
+		username = current.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return username + "@" + hostname
+}
+
 func GetHomeDir() string {
 	usr, err := user.Current()
 	if err != nil {
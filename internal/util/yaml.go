@@ -35,3 +35,48 @@ func check(e error) {
 		panic(e)
 	}
 }
+
+// NormalizeYaml converts the yaml.v2 nested maps (map[interface{}]interface{}) into map[string]interface{}
+// so the values can be used as template data and handled as JSON
+func NormalizeYaml(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			result[fmt.Sprintf("%v", k)] = NormalizeYaml(v)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			result[k] = NormalizeYaml(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, v := range typed {
+			result[i] = NormalizeYaml(v)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// MergeValues deep merges 'src' into 'dst', values in 'src' take precedence as in helm. Maps of 'src'
+// are copied so merging into 'dst' later doesn't modify them
+func MergeValues(dst map[string]interface{}, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			MergeValues(dstMap, srcMap)
+		} else if srcIsMap {
+			copied := make(map[string]interface{})
+			MergeValues(copied, srcMap)
+			dst[key] = copied
+		} else {
+			dst[key] = srcValue
+		}
+	}
+}
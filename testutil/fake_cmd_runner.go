@@ -13,6 +13,7 @@ type fakeRunner struct {
 	CmdLines         []string
 	output           string
 	cmdLineToOutput  map[string]string
+	cmdLineToError   map[string]error
 	currentCmdLine   string
 	isSuppressOutput bool
 }
@@ -36,6 +37,14 @@ func (fk *fakeRunner) Run() commandline.CommandOutput {
 	return commandline.CommandOutput{Output: fk.output}
 }
 
+func (fk *fakeRunner) RunWithError() (commandline.CommandOutput, error) {
+	output := fk.Run()
+	if err, ok := fk.cmdLineToError[fk.currentCmdLine]; ok {
+		return output, err
+	}
+	return output, nil
+}
+
 func (fk fakeRunner) RunVoid() {
 }
 
@@ -43,7 +52,8 @@ func (fk fakeRunner) Output() string {
 	return fk.output
 }
 
-func (fk fakeRunner) Setup(cmd string, args []string) {
+func (fk *fakeRunner) Setup(cmd string, args []string) {
+	fk.SetupWithoutOutput(cmd, args)
 }
 
 func (fk fakeRunner) IgnoreError(ignoreError bool) {
@@ -80,6 +90,18 @@ func (fk *fakeRunner) FakeCommand(cmdLine string, output string) {
 	fk.cmdLineToOutput[cmdLine] = output
 }
 
+// FakeCommandError makes RunWithError fail for 'cmdLine' with the given exit code
+func (fk *fakeRunner) FakeCommandError(cmdLine string, exitCode int) {
+	if fk.cmdLineToError == nil {
+		fk.cmdLineToError = make(map[string]error)
+	}
+	fk.cmdLineToError[cmdLine] = &commandline.CommandError{
+		CommandLine: cmdLine,
+		ExitCode:    exitCode,
+		Err:         fmt.Errorf("exit status %d", exitCode),
+	}
+}
+
 func FakeCommandRunner(output string) *fakeRunner {
 	fakeRunner := new(fakeRunner)
 	fakeRunner.output = output